@@ -0,0 +1,75 @@
+// Package client is the Go SDK for matspinner. It bundles the services' own
+// client endpoints behind one constructor, so a program talking to the
+// gateway or the all-in-one binary doesn't dial each service itself.
+//
+//	c, err := client.New("http://store:8080", auth.ForwardCredentials(key))
+//	players, err := c.Players.GetAll(ctx, playersvc.ListOptions{})
+//
+// Failed calls return the services' sentinel errors, so errors.Is(err,
+// playersvc.ErrPlayerDoesNotExist) works as it would in-process.
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/gatewaysvc"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+// Client has an endpoint set per service. Users only works against a
+// server that has usersvc behind it.
+type Client struct {
+	Players playersvc.EndpointSet
+	Tickets ticketsvc.EndpointSet
+	Spins   spinsvc.EndpointSet
+	Users   usersvc.EndpointSet
+	Gateway gatewaysvc.EndpointSet
+}
+
+// New makes a client for server, the gateway's URL or the all-in-one
+// binary's /api. The services' clients only take a host, so a base path in
+// server is put back in front of each request path.
+func New(server string, options ...httptransport.ClientOption) (*Client, error) {
+	if !strings.HasPrefix(server, "http") {
+		server = "http://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	if prefix := strings.TrimSuffix(u.Path, "/"); prefix != "" {
+		options = append(options, httptransport.ClientBefore(func(ctx context.Context, r *http.Request) context.Context {
+			r.URL.Path = prefix + r.URL.Path
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = prefix + r.URL.RawPath
+			}
+			return ctx
+		}))
+	}
+
+	var c Client
+	if c.Players, err = playersvc.MakeClientEndpoints(server, options...); err != nil {
+		return nil, err
+	}
+	if c.Tickets, err = ticketsvc.MakeClientEndpoints(server, options...); err != nil {
+		return nil, err
+	}
+	if c.Spins, err = spinsvc.MakeClientEndpoints(server, options...); err != nil {
+		return nil, err
+	}
+	if c.Users, err = usersvc.MakeClientEndpoints(server, options...); err != nil {
+		return nil, err
+	}
+	if c.Gateway, err = gatewaysvc.MakeClientEndpoints(server, options...); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,419 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/client"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/events"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/gatewaysvc"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+const adminKey = "admin-key"
+
+// stores is one of each service, in memory, as cmd/matspinner wires them.
+type stores struct {
+	tickets ticketsvc.Service
+	spins   spinsvc.Service
+	players playersvc.Service
+	users   usersvc.Service
+	authn   auth.Authenticator
+}
+
+func newStores(t *testing.T) *stores {
+	t.Helper()
+	logger := log.NewNopLogger()
+	ledger, err := ticketsvc.OpenLedger(ticketsvc.DefaultStorage(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s stores
+	s.tickets = ticketsvc.NewService(logger, ledger)
+	bus := events.NewLocal(logger)
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	history := spinsvc.NewHistory()
+	if err := spinsvc.RecordHistory(bus, history); err != nil {
+		t.Fatal(err)
+	}
+	s.spins = spinsvc.NewService(logger, s.tickets, spinsvc.DefaultPolicy(), bus, history)
+	s.players = playersvc.NewService(logger, s.tickets, s.spins)
+	if s.users, err = usersvc.NewService(logger, auth.NewSigner("secret"), time.Hour, usersvc.Storage{}); err != nil {
+		t.Fatal(err)
+	}
+	authn, err := auth.NewAuthenticator("root:admin:"+adminKey, "secret", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.authn = auth.Revocable(usersvc.SessionEnded(s.users), authn)
+	return &s
+}
+
+// serve starts h and returns a client for it that calls with credential.
+func serve(t *testing.T, h http.Handler, path, credential string) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := client.New(srv.URL+path, auth.ForwardCredentials(credential))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func equal(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestTickets(t *testing.T) {
+	s := newStores(t)
+	h := ticketsvc.MakeHTTPHandler(ticketsvc.MakeServerEndpoints(s.tickets), s.authn, cors.Policy{}, limit.Settings{}, log.NewNopLogger())
+	c := serve(t, h, "", adminKey).Tickets
+	ctx := context.Background()
+
+	set, err := c.Set(ctx, ticketsvc.Tickets{Id: 1, Tickets: 3}, ticketsvc.Tickets{Id: 2, Tickets: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Set", set, []ticketsvc.Tickets{{Id: 1, Tickets: 3}, {Id: 2, Tickets: 5}})
+
+	// only the ids asked for come back, in the order asked
+	got, err := c.Get(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Get(2)", got, []ticketsvc.Tickets{{Id: 2, Tickets: 5}})
+	if got, err = c.Get(ctx, 2, 1, 9); err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Get(2, 1, 9)", got, []ticketsvc.Tickets{{Id: 2, Tickets: 5}, {Id: 1, Tickets: 3}, {Id: 9, Tickets: 0}})
+
+	inc, err := c.Increment(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Increment", inc, []ticketsvc.Tickets{{Id: 1, Tickets: 4}})
+
+	adj, err := c.Adjust(ctx, 2, -10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Adjust", adj, ticketsvc.Tickets{Id: 2, Tickets: 0})
+
+	all, err := c.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "GetAll", all, []ticketsvc.Tickets{{Id: 1, Tickets: 4}})
+
+	entries, err := c.GetLog(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Kind != ticketsvc.ChangeIncremented {
+		t.Fatalf("GetLog(1) = %v, want the set and the increment", entries)
+	}
+
+	corrected, err := c.Correct(ctx, entries[1].Seq, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Correct", corrected, []ticketsvc.Tickets{{Id: 1, Tickets: 3}})
+	if _, err := c.Correct(ctx, 999, nil); !errors.Is(err, ticketsvc.ErrEntryNotFound) {
+		t.Errorf("Correct(999) err = %v, want %v", err, ticketsvc.ErrEntryNotFound)
+	}
+
+	reset, err := c.Reset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Reset", reset, []ticketsvc.Tickets{{Id: 1, Tickets: 0}})
+}
+
+func TestSpins(t *testing.T) {
+	s := newStores(t)
+	h := spinsvc.MakeHTTPHandler(spinsvc.MakeServerEndpoints(s.spins), s.authn, cors.Policy{}, limit.Settings{}, log.NewNopLogger())
+	c := serve(t, h, "", adminKey).Spins
+	ctx := context.Background()
+
+	if _, err := c.GetLast(ctx); !errors.Is(err, spinsvc.ErrNoSpin) {
+		t.Errorf("GetLast before any spin err = %v, want %v", err, spinsvc.ErrNoSpin)
+	}
+	if _, err := c.Spin(ctx, nil); !errors.Is(err, spinsvc.ErrNoParticipants) {
+		t.Errorf("Spin with nobody err = %v, want %v", err, spinsvc.ErrNoParticipants)
+	}
+
+	first, err := c.Spin(ctx, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.WinnerId != 1 || first.WinnerTickets != 1 {
+		t.Errorf("Spin = %v, want 1 to win with the ticket for turning up", first)
+	}
+	second, err := c.SpinUnweighted(ctx, []int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := c.GetLast(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "GetLast", last.Id, second.Id)
+	got, err := c.Get(ctx, first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Get", got.ParticipantIds, first.ParticipantIds)
+	history, err := c.GetHistory(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Id != first.Id {
+		t.Errorf("GetHistory(1) = %v, want only the first spin", history)
+	}
+	if _, err := c.Get(ctx, 999); !errors.Is(err, spinsvc.ErrSpinNotFound) {
+		t.Errorf("Get(999) err = %v, want %v", err, spinsvc.ErrSpinNotFound)
+	}
+
+	// voiding gives the winner back what the spin took from them
+	voided, err := c.Void(ctx, second.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !voided.Voided {
+		t.Errorf("Void = %v, want it voided", voided)
+	}
+	if _, err := c.Void(ctx, second.Id); !errors.Is(err, spinsvc.ErrSpinVoided) {
+		t.Errorf("Void twice err = %v, want %v", err, spinsvc.ErrSpinVoided)
+	}
+	if _, err := c.Void(ctx, 999); !errors.Is(err, spinsvc.ErrSpinNotFound) {
+		t.Errorf("Void(999) err = %v, want %v", err, spinsvc.ErrSpinNotFound)
+	}
+	tickets, err := s.tickets.Get(ctx, second.WinnerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "winner's tickets after Void", tickets[0].Tickets, second.WinnerTickets)
+	got, err = c.Get(ctx, second.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Get after Void voided", got.Voided, true)
+}
+
+func TestPlayers(t *testing.T) {
+	s := newStores(t)
+	h := playersvc.MakeHTTPHandler(playersvc.MakeServerEndpoints(s.players), s.authn, cors.Policy{}, limit.Settings{}, log.NewNopLogger())
+	c := serve(t, h, "", adminKey).Players
+	ctx := context.Background()
+
+	bea, err := c.Add(ctx, "Bea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := c.Add(ctx, "Ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Get(ctx, ann.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Get", got.Name, "Ann")
+	if _, err := c.Get(ctx, 999); !errors.Is(err, playersvc.ErrPlayerDoesNotExist) {
+		t.Errorf("Get(999) err = %v, want %v", err, playersvc.ErrPlayerDoesNotExist)
+	}
+
+	players, missing, err := c.GetByIds(ctx, bea.Id, 999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 1 || players[0].Id != bea.Id {
+		t.Errorf("GetByIds players = %v, want Bea", players)
+	}
+	equal(t, "GetByIds missing", missing, []int{999})
+
+	bea.Name = "Beatrice"
+	if _, err := c.Update(ctx, bea); err != nil {
+		t.Fatal(err)
+	}
+	all, err := c.GetAll(ctx, playersvc.ListOptions{SortBy: playersvc.SortByName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Name != "Ann" || all[1].Name != "Beatrice" {
+		t.Errorf("GetAll by name = %v, want Ann, Beatrice", all)
+	}
+	// the API document lists the sort fields, so the request is refused
+	// before it reaches the service
+	if _, err := c.GetAll(ctx, playersvc.ListOptions{SortBy: "age"}); !errors.Is(err, openapi.ErrInvalidRequest) {
+		t.Errorf("GetAll by age err = %v, want %v", err, openapi.ErrInvalidRequest)
+	}
+
+	report, err := c.Import(ctx, []playersvc.RosterEntry{{Name: "Ann"}, {Name: "Cat"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Matched) != 1 || len(report.Created) != 1 {
+		t.Errorf("Import = %v, want a dry run matching Ann and creating Cat", report)
+	}
+
+	if _, err := s.tickets.Set(ctx, ticketsvc.Tickets{Id: ann.Id, Tickets: 4}); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := c.GetProfile(ctx, ann.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "GetProfile tickets", profile.Tickets, 4)
+}
+
+func TestUsers(t *testing.T) {
+	s := newStores(t)
+	h := usersvc.MakeHTTPHandler(usersvc.MakeServerEndpoints(s.users), s.authn, cors.Policy{}, limit.Settings{}, log.NewNopLogger())
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	admin, err := client.New(srv.URL, auth.ForwardCredentials(adminKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := admin.Users.Create(ctx, "pat", "password1", map[string]auth.Role{"north": auth.RoleStaff}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Users.Create(ctx, "pat", "password1", nil); !errors.Is(err, usersvc.ErrUserExists) {
+		t.Errorf("Create twice err = %v, want %v", err, usersvc.ErrUserExists)
+	}
+	users, err := admin.Users.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Username != "pat" {
+		t.Errorf("GetAll = %v, want pat", users)
+	}
+
+	if _, err := admin.Users.Login(ctx, "pat", "wrong password", ""); !errors.Is(err, usersvc.ErrInvalidLogin) {
+		t.Errorf("Login with a wrong password err = %v, want %v", err, usersvc.ErrInvalidLogin)
+	}
+	session, err := admin.Users.Login(ctx, "pat", "password1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Login identity", session.As.Store, "north")
+
+	pat, err := client.New(srv.URL, auth.ForwardCredentials(session.Token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, id, err := pat.Users.Me(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "Me", user.Username, "pat")
+	equal(t, "Me role", id.Role, auth.RoleStaff)
+	if _, err := pat.Users.GetAll(ctx); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("GetAll as staff err = %v, want %v", err, auth.ErrForbidden)
+	}
+
+	if err := pat.Users.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ended, err := admin.Users.Ended(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ended[session.As.Session]; !ok {
+		t.Errorf("Ended = %v, want the logged out session", ended)
+	}
+	if _, _, err := pat.Users.Me(ctx); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("Me after logout err = %v, want %v", err, auth.ErrInvalidCredentials)
+	}
+
+	roles := map[string]auth.Role{usersvc.AllStores: auth.RoleDisplay}
+	updated, err := admin.Users.SetRoles(ctx, "pat", roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "SetRoles", updated.Roles, roles)
+	if err := admin.Users.ResetPassword(ctx, "pat", "password2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Users.ResetPassword(ctx, "nobody", "password2"); !errors.Is(err, usersvc.ErrUserDoesNotExist) {
+		t.Errorf("ResetPassword for nobody err = %v, want %v", err, usersvc.ErrUserDoesNotExist)
+	}
+	if _, err := admin.Users.Login(ctx, "pat", "password2", ""); err != nil {
+		t.Errorf("Login with the new password: %v", err)
+	}
+}
+
+// TestGateway calls through the gateway mounted under /api, as
+// cmd/matspinner serves it, so the client has to put the prefix back on
+// every path.
+func TestGateway(t *testing.T) {
+	s := newStores(t)
+	logger := log.NewNopLogger()
+	upstreams := gatewaysvc.Upstreams{
+		Players: playersvc.MakeServerEndpoints(s.players),
+		Tickets: ticketsvc.MakeServerEndpoints(s.tickets),
+		Spins:   spinsvc.MakeServerEndpoints(s.spins),
+		Users:   usersvc.MakeServerEndpoints(s.users),
+	}
+	gateway := gatewaysvc.NewService(logger, s.players, s.tickets, s.spins)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", gatewaysvc.MakeHTTPHandler(gatewaysvc.MakeServerEndpoints(gateway), upstreams, s.authn, cors.Policy{}, limit.Settings{}, logger)))
+	c := serve(t, mux, "/api", adminKey)
+	ctx := context.Background()
+
+	ann, err := c.Players.Add(ctx, "Ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Tickets.Set(ctx, ticketsvc.Tickets{Id: ann.Id, Tickets: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	players, err := c.Gateway.PlayersWithTickets(ctx, playersvc.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 1 || players[0].Name != "Ann" || players[0].Tickets != 2 {
+		t.Errorf("PlayersWithTickets = %v, want Ann with 2", players)
+	}
+
+	spin, err := c.Gateway.SpinWithWinner(ctx, []int{ann.Id}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "SpinWithWinner winner", spin.Winner.Name, "Ann")
+	if _, err := c.Gateway.SpinWithWinner(ctx, nil, true); !errors.Is(err, spinsvc.ErrNoParticipants) {
+		t.Errorf("SpinWithWinner with nobody err = %v, want %v", err, spinsvc.ErrNoParticipants)
+	}
+
+	if _, err := c.Users.Create(ctx, "a b", "password1", nil); err != nil {
+		t.Fatal(err)
+	}
+	// an escaped path keeps its escaping behind the prefix
+	if err := c.Users.ResetPassword(ctx, "a b", "password2"); err != nil {
+		t.Errorf("ResetPassword through the gateway: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Command matspin is a command-line client for organizers, for fixing things
+// quickly without the UI. It talks to the gateway, or to the all-in-one
+// binary's /api, through the services' own client endpoints.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jlthompson3259/matspinner/client"
+	"github.com/jlthompson3259/matspinner/common/auth"
+)
+
+const (
+	defaultServer = "http://localhost:8080"
+
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+const usage = `usage: matspin [flags] <command> [args]
+
+commands:
+  players add <name>
+  players list [-sort id|name|created] [-desc] [-all]
+  players search <text>
+  players rename <id> <name>
+  tickets list
+  tickets get <id>...
+  tickets set <id> <tickets>
+  tickets adjust <id> <+n|-n>
+  tickets reset
+  tickets log [<id>...]
+  tickets correct <seq> void|reset|increment <id>|set <id> <tickets>|adjust <id> <+n|-n>
+  spin [-unweighted] [-roster file] [<id>...]
+  history [<player id>...]
+  last
+  show <spin id>
+  profile list|set|use|remove
+
+flags:
+`
+
+var (
+	ErrUsage         = errors.New("usage")
+	ErrUnknownOutput = errors.New("unknown output format, should be table or json")
+)
+
+// globals are the flags that come before the command.
+type globals struct {
+	output  string
+	profile profile
+}
+
+func main() {
+	fs := flag.NewFlagSet("matspin", flag.ExitOnError)
+	var (
+		profileName = fs.String("profile", os.Getenv("MATSPIN_PROFILE"), "profile to use instead of the current one (env MATSPIN_PROFILE)")
+		server      = fs.String("server", os.Getenv("MATSPIN_SERVER"), "server base URL, overriding the profile's (env MATSPIN_SERVER)")
+		apiKey      = fs.String("api-key", os.Getenv("MATSPIN_API_KEY"), "API key or token, overriding the profile's (env MATSPIN_API_KEY)")
+		output      = fs.String("o", outputTable, "output format: table or json")
+	)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(fs.Args(), *profileName, *server, *apiKey, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "matspin:", err)
+		if errors.Is(err, ErrUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, profileName, server, apiKey, output string) error {
+	if output != outputTable && output != outputJSON {
+		return ErrUnknownOutput
+	}
+
+	ps, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	p, err := ps.resolve(profileName, server, apiKey)
+	if err != nil {
+		return err
+	}
+	g := globals{output: output, profile: p}
+
+	command, args := args[0], args[1:]
+	if command == "profile" {
+		return runProfile(g, args)
+	}
+
+	c, err := client.New(p.Server, auth.ForwardCredentials(p.APIKey))
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "players":
+		return runPlayers(ctx, g, c, args)
+	case "tickets":
+		return runTickets(ctx, g, c, args)
+	case "spin":
+		return runSpin(ctx, g, c, args)
+	case "history":
+		return runHistory(ctx, g, c, args)
+	case "last":
+		return runLast(ctx, g, c, args)
+	case "show":
+		return runShow(ctx, g, c, args)
+	}
+	return usageError(fmt.Sprintf("unknown command %q, run matspin -h for the list", command))
+}
+
+func usageError(msg string) error {
+	return fmt.Errorf("%w: %s", ErrUsage, msg)
+}
+
+// parseWithArgs parses fs and returns the positional arguments, failing with
+// usage if there are fewer than n.
+func parseWithArgs(fs *flag.FlagSet, args []string, n int, usage string) ([]string, error) {
+	fs.Parse(args)
+	if fs.NArg() < n {
+		return nil, usageError(usage)
+	}
+	return fs.Args(), nil
+}
+
+func parseIds(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+	for _, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a player id", a)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(header ...string) table {
+	t := table{w: tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)}
+	t.row(header...)
+	return t
+}
+
+func (t table) row(cells ...string) {
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t table) flush() error {
+	return t.w.Flush()
+}
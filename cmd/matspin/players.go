@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/client"
+	"github.com/jlthompson3259/matspinner/playersvc"
+)
+
+func runPlayers(ctx context.Context, g globals, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return usageError("players add|list|search|rename")
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return usageError("players add <name>")
+		}
+		player, err := c.Players.Add(ctx, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		return printPlayers(g, player)
+
+	case "list":
+		fs := flag.NewFlagSet("players list", flag.ExitOnError)
+		sortBy := fs.String("sort", playersvc.SortById, "sort by id, name or created")
+		desc := fs.Bool("desc", false, "sort in descending order")
+		all := fs.Bool("all", false, "include inactive players")
+		if _, err := parseWithArgs(fs, args[1:], 0, "players list [-sort field] [-desc] [-all]"); err != nil {
+			return err
+		}
+		players, err := c.Players.GetAll(ctx, playersvc.ListOptions{SortBy: *sortBy, Descending: *desc, IncludeInactive: *all})
+		if err != nil {
+			return err
+		}
+		return printPlayers(g, players...)
+
+	case "search":
+		if len(args) < 2 {
+			return usageError("players search <text>")
+		}
+		players, err := c.Players.GetAll(ctx, playersvc.ListOptions{SortBy: playersvc.SortByName, IncludeInactive: true})
+		if err != nil {
+			return err
+		}
+		text := strings.ToLower(strings.Join(args[1:], " "))
+		found := []playersvc.Player{}
+		for _, p := range players {
+			if strings.Contains(strings.ToLower(p.Name), text) || strings.EqualFold(p.ExternalId, text) {
+				found = append(found, p)
+			}
+		}
+		return printPlayers(g, found...)
+
+	case "rename":
+		if len(args) < 3 {
+			return usageError("players rename <id> <name>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usageError("players rename <id> <name>")
+		}
+		player, err := c.Players.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		player.Name = strings.Join(args[2:], " ")
+		if player, err = c.Players.Update(ctx, player); err != nil {
+			return err
+		}
+		return printPlayers(g, player)
+	}
+	return usageError("players add|list|search|rename")
+}
+
+func printPlayers(g globals, players ...playersvc.Player) error {
+	if g.output == outputJSON {
+		return printJSON(players)
+	}
+	t := newTable("ID", "NAME", "EXTERNAL ID", "ACTIVE", "CREATED")
+	for _, p := range players {
+		active := "yes"
+		if p.Inactive {
+			active = "no"
+		}
+		t.row(strconv.Itoa(p.Id), p.Name, p.ExternalId, active, p.Created.Local().Format("2006-01-02 15:04"))
+	}
+	return t.flush()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// profile is a server to talk to and the credential to use there, so that
+// switching between the store's server and a local one is one flag.
+type profile struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"apiKey,omitempty"`
+}
+
+type profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+// profilesPath is where profiles are kept, $XDG_CONFIG_HOME/matspin/profiles.yaml
+// or the platform equivalent.
+func profilesPath() (string, error) {
+	if path := os.Getenv("MATSPIN_PROFILES"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "matspin", "profiles.yaml"), nil
+}
+
+func loadProfiles() (profiles, error) {
+	ps := profiles{Profiles: map[string]profile{}}
+	path, err := profilesPath()
+	if err != nil {
+		return ps, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ps, nil
+	}
+	if err != nil {
+		return ps, err
+	}
+	if err := yaml.Unmarshal(b, &ps); err != nil {
+		return ps, fmt.Errorf("%s: %w", path, err)
+	}
+	if ps.Profiles == nil {
+		ps.Profiles = map[string]profile{}
+	}
+	return ps, nil
+}
+
+func (ps profiles) save() error {
+	path, err := profilesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// profiles hold API keys
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(2)
+	if err := enc.Encode(ps); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// resolve picks the profile named by name, or the current one, and applies
+// the server and API key overrides on top.
+func (ps profiles) resolve(name, server, apiKey string) (profile, error) {
+	if name == "" {
+		name = ps.Current
+	}
+	p := profile{Server: defaultServer}
+	if name != "" {
+		var ok bool
+		if p, ok = ps.Profiles[name]; !ok {
+			return p, fmt.Errorf("%w %q", ErrUnknownProfile, name)
+		}
+	}
+	if server != "" {
+		p.Server = server
+	}
+	if apiKey != "" {
+		p.APIKey = apiKey
+	}
+	return p, nil
+}
+
+func runProfile(g globals, args []string) error {
+	if len(args) == 0 {
+		return usageError("profile list|set|use|remove")
+	}
+	ps, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		names := make([]string, 0, len(ps.Profiles))
+		for name := range ps.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if g.output == outputJSON {
+			return printJSON(ps)
+		}
+		t := newTable("", "NAME", "SERVER", "API KEY")
+		for _, name := range names {
+			current, key := "", ""
+			if name == ps.Current {
+				current = "*"
+			}
+			if ps.Profiles[name].APIKey != "" {
+				key = "set"
+			}
+			t.row(current, name, ps.Profiles[name].Server, key)
+		}
+		return t.flush()
+
+	case "set":
+		fs := flag.NewFlagSet("profile set", flag.ExitOnError)
+		server := fs.String("server", "", "server base URL, e.g. http://store:8080 or http://store:8080/api")
+		apiKey := fs.String("api-key", "", "API key or token to send")
+		name, err := parseWithArgs(fs, args[1:], 1, "profile set [-server url] [-api-key key] <name>")
+		if err != nil {
+			return err
+		}
+		p := ps.Profiles[name[0]]
+		if *server != "" {
+			p.Server = *server
+		}
+		if *apiKey != "" {
+			p.APIKey = *apiKey
+		}
+		if p.Server == "" {
+			return usageError("profile set -server url <name>")
+		}
+		ps.Profiles[name[0]] = p
+		if ps.Current == "" {
+			ps.Current = name[0]
+		}
+		return ps.save()
+
+	case "use":
+		if len(args) != 2 {
+			return usageError("profile use <name>")
+		}
+		if _, ok := ps.Profiles[args[1]]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownProfile, args[1])
+		}
+		ps.Current = args[1]
+		return ps.save()
+
+	case "remove":
+		if len(args) != 2 {
+			return usageError("profile remove <name>")
+		}
+		if _, ok := ps.Profiles[args[1]]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownProfile, args[1])
+		}
+		delete(ps.Profiles, args[1])
+		if ps.Current == args[1] {
+			ps.Current = ""
+		}
+		return ps.save()
+	}
+	return usageError("profile list|set|use|remove")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/client"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+)
+
+var ErrUnmatchedRoster = errors.New("roster entries don't match exactly one player")
+
+func runSpin(ctx context.Context, g globals, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("spin", flag.ExitOnError)
+	unweighted := fs.Bool("unweighted", false, "give every participant the same odds")
+	roster := fs.String("roster", "", "CSV or JSON roster export naming the participants")
+	rest, err := parseWithArgs(fs, args, 0, "spin [-unweighted] [-roster file] [<id>...]")
+	if err != nil {
+		return err
+	}
+
+	ids, err := parseIds(rest)
+	if err != nil {
+		return err
+	}
+	if *roster != "" {
+		fromRoster, err := rosterIds(ctx, c, *roster)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, fromRoster...)
+	}
+	if len(ids) == 0 {
+		return usageError("spin needs participant ids or -roster")
+	}
+
+	var result spinsvc.SpinResult
+	if *unweighted {
+		result, err = c.Spins.SpinUnweighted(ctx, ids)
+	} else {
+		result, err = c.Spins.Spin(ctx, ids)
+	}
+	if err != nil {
+		return err
+	}
+	return printSpins(ctx, g, c, result)
+}
+
+func runHistory(ctx context.Context, g globals, c *client.Client, args []string) error {
+	ids, err := parseIds(args)
+	if err != nil {
+		return err
+	}
+	history, err := c.Spins.GetHistory(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	return printSpins(ctx, g, c, history...)
+}
+
+func runLast(ctx context.Context, g globals, c *client.Client, args []string) error {
+	if len(args) != 0 {
+		return usageError("last")
+	}
+	last, err := c.Spins.GetLast(ctx)
+	if err != nil {
+		return err
+	}
+	return printSpins(ctx, g, c, last)
+}
+
+func runShow(ctx context.Context, g globals, c *client.Client, args []string) error {
+	if len(args) != 1 {
+		return usageError("show <spin id>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return usageError(fmt.Sprintf("spin id %q is not a number", args[0]))
+	}
+	spin, err := c.Spins.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printSpins(ctx, g, c, spin)
+}
+
+// rosterIds resolves a roster export to player ids the same way an import
+// matches players, by external id and then by name, but without creating
+// anyone: every entry has to match exactly one existing player.
+func rosterIds(ctx context.Context, c *client.Client, path string) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []playersvc.RosterEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		entries, err = playersvc.ParseRosterJSON(f)
+	default:
+		entries, err = playersvc.ParseRosterCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	players, err := c.Players.GetAll(ctx, playersvc.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ids       []int
+		unmatched []string
+	)
+	for _, entry := range entries {
+		var matches []playersvc.Player
+		for _, p := range players {
+			if entry.ExternalId != "" && p.ExternalId == entry.ExternalId {
+				matches = append(matches, p)
+			}
+		}
+		if len(matches) == 0 {
+			for _, p := range players {
+				if strings.EqualFold(strings.TrimSpace(p.Name), strings.TrimSpace(entry.Name)) {
+					matches = append(matches, p)
+				}
+			}
+		}
+		if len(matches) != 1 {
+			unmatched = append(unmatched, fmt.Sprintf("line %d %q (%d matches)", entry.Line, entry.Name, len(matches)))
+			continue
+		}
+		ids = append(ids, matches[0].Id)
+	}
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("%w: %s; import the roster first", ErrUnmatchedRoster, strings.Join(unmatched, ", "))
+	}
+	return ids, nil
+}
+
+func printSpins(ctx context.Context, g globals, c *client.Client, spins ...spinsvc.SpinResult) error {
+	if g.output == outputJSON {
+		return printJSON(spins)
+	}
+
+	// names make the table readable, but aren't worth failing over
+	names := map[int]string{}
+	winners := make([]int, 0, len(spins))
+	for _, s := range spins {
+		winners = append(winners, s.WinnerId)
+	}
+	if players, _, err := c.Players.GetByIds(ctx, winners...); err == nil {
+		for _, p := range players {
+			names[p.Id] = p.Name
+		}
+	}
+
+	t := newTable("ID", "TIME", "WINNER", "NAME", "TICKETS AT WIN", "PARTICIPANTS")
+	for _, s := range spins {
+		t.row(strconv.Itoa(s.Id), s.Time.Local().Format("2006-01-02 15:04:05"), strconv.Itoa(s.WinnerId),
+			names[s.WinnerId], strconv.Itoa(s.WinnerTickets), strconv.Itoa(len(s.ParticipantIds)))
+	}
+	return t.flush()
+}
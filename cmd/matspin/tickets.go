@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jlthompson3259/matspinner/client"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+func runTickets(ctx context.Context, g globals, c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return usageError("tickets list|get|set|adjust|reset|log|correct")
+	}
+
+	switch args[0] {
+	case "list":
+		tickets, err := c.Tickets.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets...)
+
+	case "get":
+		ids, err := parseIds(args[1:])
+		if err != nil || len(ids) == 0 {
+			return usageError("tickets get <id>...")
+		}
+		tickets, err := c.Tickets.Get(ctx, ids...)
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets...)
+
+	case "set":
+		if len(args) != 3 {
+			return usageError("tickets set <id> <tickets>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usageError("tickets set <id> <tickets>")
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return usageError("tickets set <id> <tickets>, tickets can't be negative")
+		}
+		tickets, err := c.Tickets.Set(ctx, ticketsvc.Tickets{Id: id, Tickets: n})
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets...)
+
+	case "adjust":
+		if len(args) != 3 {
+			return usageError("tickets adjust <id> <+n|-n>")
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return usageError("tickets adjust <id> <+n|-n>")
+		}
+		delta, err := strconv.Atoi(args[2])
+		if err != nil {
+			return usageError("tickets adjust <id> <+n|-n>")
+		}
+		tickets, err := c.Tickets.Adjust(ctx, id, delta)
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets)
+
+	case "reset":
+		if len(args) != 1 {
+			return usageError("tickets reset")
+		}
+		tickets, err := c.Tickets.Reset(ctx)
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets...)
+
+	case "log":
+		ids, err := parseIds(args[1:])
+		if err != nil {
+			return usageError("tickets log [<id>...]")
+		}
+		entries, err := c.Tickets.GetLog(ctx, ids...)
+		if err != nil {
+			return err
+		}
+		return printLog(g, entries)
+
+	case "correct":
+		const correctUsage = "tickets correct <seq> void|reset|increment <id>|set <id> <tickets>|adjust <id> <+n|-n>"
+		if len(args) < 3 {
+			return usageError(correctUsage)
+		}
+		seq, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return usageError(correctUsage)
+		}
+		replacement, err := parseChange(args[2:])
+		if err != nil {
+			return usageError(correctUsage)
+		}
+		tickets, err := c.Tickets.Correct(ctx, seq, replacement)
+		if err != nil {
+			return err
+		}
+		return printTickets(g, tickets...)
+	}
+	return usageError("tickets list|get|set|adjust|reset|log|correct")
+}
+
+// parseChange parses the replacement given to tickets correct, which is nil
+// for void.
+func parseChange(args []string) (*ticketsvc.Change, error) {
+	switch {
+	case len(args) == 1 && args[0] == "void":
+		return nil, nil
+	case len(args) == 1 && args[0] == "reset":
+		return &ticketsvc.Change{Kind: ticketsvc.ChangeReset}, nil
+	case len(args) == 2 && args[0] == "increment":
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return &ticketsvc.Change{Kind: ticketsvc.ChangeIncremented, Id: id, Amount: 1}, nil
+	case len(args) == 3 && (args[0] == "set" || args[0] == "adjust"):
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if args[0] == "set" {
+			if n < 0 {
+				return nil, ErrUsage
+			}
+			return &ticketsvc.Change{Kind: ticketsvc.ChangeSet, Id: id, Amount: n}, nil
+		}
+		return &ticketsvc.Change{Kind: ticketsvc.ChangeAdjusted, Id: id, Amount: n}, nil
+	}
+	return nil, ErrUsage
+}
+
+func printLog(g globals, entries []ticketsvc.Entry) error {
+	if g.output == outputJSON {
+		return printJSON(entries)
+	}
+	t := newTable("SEQ", "TIME", "CHANGE")
+	for _, e := range entries {
+		change := describeChange(e.Change)
+		if e.Kind == ticketsvc.ChangeCorrected {
+			change = fmt.Sprintf("correct %d to void", e.Corrects)
+			if e.Replacement != nil {
+				change = fmt.Sprintf("correct %d to %s", e.Corrects, describeChange(*e.Replacement))
+			}
+		}
+		t.row(strconv.FormatInt(e.Seq, 10), e.Time.Local().Format("2006-01-02 15:04:05"), change)
+	}
+	return t.flush()
+}
+
+func describeChange(c ticketsvc.Change) string {
+	switch c.Kind {
+	case ticketsvc.ChangeIncremented:
+		return fmt.Sprintf("increment %d by %d", c.Id, c.Amount)
+	case ticketsvc.ChangeSet:
+		return fmt.Sprintf("set %d to %d", c.Id, c.Amount)
+	case ticketsvc.ChangeAdjusted:
+		return fmt.Sprintf("adjust %d by %+d", c.Id, c.Amount)
+	case ticketsvc.ChangeReset:
+		return "reset"
+	}
+	return string(c.Kind)
+}
+
+func printTickets(g globals, tickets ...ticketsvc.Tickets) error {
+	if g.output == outputJSON {
+		return printJSON(tickets)
+	}
+	t := newTable("ID", "TICKETS")
+	for _, tk := range tickets {
+		t.row(strconv.Itoa(tk.Id), strconv.Itoa(tk.Tickets))
+	}
+	return t.flush()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"time"
+
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	Spin           spinsvc.Policy    `yaml:"spin"`
+	Storage        ticketsvc.Storage `yaml:"storage"`
+	Users          usersvc.Storage   `yaml:"users"`
+	UIDir          string            `yaml:"uiDir" env:"UI_DIR" flag:"ui-dir" help:"directory of built UI assets to serve at /, if any"`
+	SessionTTL     time.Duration     `yaml:"sessionTtl" env:"SESSION_TTL" flag:"session-ttl" help:"how long a login session lasts"`
+	Admin          admin             `yaml:"admin"`
+}
+
+// admin is the staff account created at startup. Staff accounts are only
+// enabled when auth.secret is set, since sessions are signed with it.
+type admin struct {
+	Username string `yaml:"username" env:"ADMIN_USERNAME" flag:"admin-username" help:"admin account to create at startup if it doesn't exist"`
+	Password string `yaml:"password" env:"ADMIN_PASSWORD" flag:"admin-password" help:"password for the admin account" secret:"true"`
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:    config.DefaultService(":8080"),
+		Spin:       spinsvc.DefaultPolicy(),
+		Storage:    ticketsvc.DefaultStorage(),
+		SessionTTL: 12 * time.Hour,
+	}
+}
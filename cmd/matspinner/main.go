@@ -0,0 +1,169 @@
+// Command matspinner runs playersvc, ticketsvc and spinsvc in one process,
+// for stores that don't want to run a container per service. The services
+// call each other directly instead of over HTTP, and their routes are served
+// on one port under /api, the same way the gateway serves them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/gatewaysvc"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+func main() {
+	cfg := defaultSettings()
+	if err := config.Load("matspinner", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("matspinner", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	bus, err := cfg.Events.NewBus("matspinner", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	ledger, err := ticketsvc.OpenLedger(cfg.Storage, log.With(logger, "component", "ticketsvc", "layer", "ledger"))
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	var (
+		tickets   ticketsvc.Service
+		spins     spinsvc.Service
+		players   playersvc.Service
+		users     usersvc.Service
+		drainer   = spinsvc.NewDrainer()
+		readiness []health.Check
+	)
+	{
+		base := ticketsvc.NewService(log.With(logger, "component", "ticketsvc"), ledger)
+		readiness = append(readiness, health.Check{Name: "ticketsvc", Check: func(ctx context.Context) error {
+			_, err := base.GetAll(ctx)
+			return err
+		}})
+		tickets = ticketsvc.EventsMiddleware(bus, log.With(logger, "component", "ticketsvc", "layer", "eventsMiddleware"))(base)
+		tickets = ticketsvc.LoggingMiddleware(log.With(logger, "component", "ticketsvc", "layer", "loggingMiddleware"))(tickets)
+	}
+	{
+		history := spinsvc.NewHistory()
+		if err := spinsvc.RecordHistory(bus, history); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		base := spinsvc.NewService(log.With(logger, "component", "spinsvc"), tickets, cfg.Spin, bus, history)
+		readiness = append(readiness, health.Check{Name: "spinsvc", Check: func(ctx context.Context) error {
+			_, err := base.GetHistory(ctx)
+			return err
+		}})
+		spins = drainer.Middleware(base)
+		spins = spinsvc.LoggingMiddleware(log.With(logger, "component", "spinsvc", "layer", "loggingMiddleware"))(spins)
+	}
+	{
+		base := playersvc.NewService(log.With(logger, "component", "playersvc"), tickets, spins)
+		readiness = append(readiness, health.Check{Name: "playersvc", Check: func(ctx context.Context) error {
+			_, err := base.GetAll(ctx, playersvc.ListOptions{})
+			return err
+		}})
+		players = playersvc.EventsMiddleware(bus, log.With(logger, "component", "playersvc", "layer", "eventsMiddleware"))(base)
+		players = playersvc.LoggingMiddleware(log.With(logger, "component", "playersvc", "layer", "loggingMiddleware"))(players)
+	}
+	if cfg.Auth.Secret != "" {
+		base, err := usersvc.NewService(log.With(logger, "component", "usersvc"), auth.NewSigner(cfg.Auth.Secret), cfg.SessionTTL, cfg.Users)
+		if err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		readiness = append(readiness, health.Check{Name: "usersvc", Check: func(ctx context.Context) error {
+			_, err := base.GetAll(ctx)
+			return err
+		}})
+		users = usersvc.LoggingMiddleware(log.With(logger, "component", "usersvc", "layer", "loggingMiddleware"))(base)
+		authenticator = auth.Revocable(usersvc.SessionEnded(base), authenticator)
+
+		if username := cfg.Admin.Username; username != "" {
+			_, err := users.Create(context.Background(), username, cfg.Admin.Password, map[string]auth.Role{usersvc.AllStores: auth.RoleAdmin})
+			if err != nil && !errors.Is(err, usersvc.ErrUserExists) {
+				level.Error(logger).Log("error", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	var service gatewaysvc.Service
+	{
+		service = gatewaysvc.NewService(log.With(logger, "component", "gatewaysvc"), players, tickets, spins)
+		service = gatewaysvc.LoggingMiddleware(log.With(logger, "component", "gatewaysvc", "layer", "loggingMiddleware"))(service)
+	}
+
+	// the gateway serves every service's routes from their endpoint sets,
+	// which here are server endpoints rather than clients
+	upstreams := gatewaysvc.Upstreams{
+		Players: playersvc.MakeServerEndpoints(players),
+		Tickets: ticketsvc.MakeServerEndpoints(tickets),
+		Spins:   spinsvc.MakeServerEndpoints(spins),
+	}
+	if users != nil {
+		upstreams.Users = usersvc.MakeServerEndpoints(users)
+	}
+
+	var (
+		endpoints  = gatewaysvc.MakeServerEndpoints(service)
+		apiHandler = gatewaysvc.MakeHTTPHandler(endpoints, upstreams, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/api/", http.StripPrefix("/api", apiHandler))
+	if cfg.UIDir != "" {
+		mux.Handle("/", uiHandler(cfg.UIDir))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnDrain:         []server.Hook{drainer.Close},
+		OnClose:         []server.Hook{bus.Close, ledger.Close, shutdownTracing},
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("exit", "stopped")
+}
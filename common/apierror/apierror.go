@@ -0,0 +1,147 @@
+// Package apierror is the error envelope every service answers a failed
+// request with, and the registry that turns an envelope back into the
+// sentinel error it was made from, so callers on the far side of an HTTP call
+// can still use errors.Is.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// CodeInternal is the code of errors that weren't made with New, which are
+// usually failures the caller can do nothing about.
+const CodeInternal = "internal"
+
+// Error is a failed request as it goes over the wire:
+//
+//	{"code": "players.not_found", "message": "player does not exist", "details": {"id": "7"}}
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+
+	// Status is the HTTP status a decoded error came back with, so that a
+	// service passing an upstream's error on can answer with the same one.
+	Status int `json:"-"`
+
+	err error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Unwrap returns the sentinel error registered for the code, if any.
+func (e *Error) Unwrap() error { return e.err }
+
+var (
+	mu     sync.RWMutex
+	byCode = map[string]error{}
+	byErr  = map[error]string{}
+)
+
+// New makes a sentinel error with a stable code, like errors.New. Codes are
+// namespaced by service, "tickets.missing_ids", and making two errors with
+// the same code panics: it's a programming error, like registering a flag
+// twice.
+func New(code, message string) error {
+	err := errors.New(message)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byCode[code]; ok {
+		panic("apierror: duplicate code " + code)
+	}
+	byCode[code] = err
+	byErr[err] = code
+	return err
+}
+
+// WithDetails attaches details, such as the offending value, to a sentinel
+// error. errors.Is and Sentinel still see the sentinel.
+func WithDetails(err error, details map[string]string) error {
+	e := From(err)
+	e.Details = details
+	return e
+}
+
+// Sentinel returns the registered sentinel err is or wraps, or err itself if
+// there is none, so codeFrom can keep switching on the sentinel errors.
+func Sentinel(err error) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if _, ok := byErr[e]; ok {
+			return e
+		}
+	}
+	return err
+}
+
+// StatusCode is the status an upstream answered with if err came from
+// Decode, and otherwise fallback.
+func StatusCode(err error, fallback int) int {
+	var e *Error
+	if errors.As(err, &e) && e.Status != 0 {
+		return e.Status
+	}
+	return fallback
+}
+
+// From makes the envelope for err. An error decoded from an upstream is
+// passed on as it came.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return &Error{Code: e.Code, Message: err.Error(), Details: e.Details, Status: e.Status, err: e.err}
+	}
+
+	sentinel := Sentinel(err)
+	mu.RLock()
+	code, ok := byErr[sentinel]
+	mu.RUnlock()
+	if !ok {
+		return &Error{Code: CodeInternal, Message: err.Error()}
+	}
+	return &Error{Code: code, Message: err.Error(), err: sentinel}
+}
+
+// Encode writes err's envelope with the given status.
+func Encode(w http.ResponseWriter, status int, err error) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(From(err))
+}
+
+// Decode returns nil for a successful response, and otherwise the error in
+// its envelope. Known codes unwrap to their sentinel error; responses that
+// aren't an envelope, a proxy's 502 say, come back as CodeInternal with the
+// status and body as the message.
+func Decode(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	var e Error
+	if err := json.Unmarshal(body, &e); err != nil || e.Code == "" {
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		return &Error{Code: CodeInternal, Message: fmt.Sprintf("%d: %s", resp.StatusCode, msg), Status: resp.StatusCode}
+	}
+	e.Status = resp.StatusCode
+
+	mu.RLock()
+	e.err = byCode[e.Code]
+	mu.RUnlock()
+	return &e
+}
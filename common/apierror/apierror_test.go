@@ -0,0 +1,117 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errNotFound = New("test.not_found", "player does not exist")
+
+// roundTrip encodes err as a service would answer it, and decodes the
+// response as a client would.
+func roundTrip(status int, err error) error {
+	rec := httptest.NewRecorder()
+	if err := Encode(rec, status, err); err != nil {
+		panic(err)
+	}
+	return Decode(rec.Result())
+}
+
+func TestRoundTrip(t *testing.T) {
+	sent := fmt.Errorf("get player 7: %w", WithDetails(errNotFound, map[string]string{"id": "7"}))
+	got := roundTrip(http.StatusNotFound, sent)
+
+	if !errors.Is(got, errNotFound) || Sentinel(got) != errNotFound {
+		t.Errorf("decoded %v, want it to be %v", got, errNotFound)
+	}
+	var e *Error
+	if !errors.As(got, &e) {
+		t.Fatalf("decoded %T, want *Error", got)
+	}
+	want := Error{Code: "test.not_found", Message: "get player 7: player does not exist", Details: map[string]string{"id": "7"}, Status: http.StatusNotFound}
+	if e.Code != want.Code || e.Message != want.Message || !reflect.DeepEqual(e.Details, want.Details) || e.Status != want.Status {
+		t.Errorf("decoded %+v, want %+v", *e, want)
+	}
+	if StatusCode(got, http.StatusInternalServerError) != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want the upstream's 404", StatusCode(got, 0))
+	}
+
+	// passed on by a second service, it's still the same error
+	again := roundTrip(StatusCode(got, http.StatusInternalServerError), fmt.Errorf("spin: %w", got))
+	if !errors.Is(again, errNotFound) || StatusCode(again, 0) != http.StatusNotFound || !reflect.DeepEqual(From(again).Details, want.Details) {
+		t.Errorf("passed on = %+v, want the same code, status and details", From(again))
+	}
+}
+
+func TestRoundTripUnknown(t *testing.T) {
+	got := roundTrip(http.StatusInternalServerError, errors.New("disk full"))
+	if e := From(got); e.Code != CodeInternal || e.Message != "disk full" || e.Unwrap() != nil {
+		t.Errorf("decoded %+v, want an internal error with the message", e)
+	}
+	if StatusCode(errors.New("local"), http.StatusTeapot) != http.StatusTeapot {
+		t.Error("StatusCode of an error that wasn't decoded isn't the fallback")
+	}
+}
+
+func TestDecodeNotAnEnvelope(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusBadGateway, "<html>bad gateway</html>\n", "502: <html>bad gateway</html>"},
+		{http.StatusServiceUnavailable, "", "503: Service Unavailable"},
+		{http.StatusBadRequest, `{"error": "no code"}`, `400: {"error": "no code"}`},
+	} {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(tc.status)
+		rec.WriteString(tc.body)
+		var e *Error
+		if err := Decode(rec.Result()); !errors.As(err, &e) || e.Code != CodeInternal || e.Message != tc.want || e.Status != tc.status {
+			t.Errorf("Decode(%d %q) = %+v, want an internal error %q", tc.status, tc.body, err, tc.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(http.StatusCreated)
+	if err := Decode(rec.Result()); err != nil {
+		t.Errorf("Decode(201) = %v, want nil", err)
+	}
+}
+
+func TestNewRejectsDuplicateCodes(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "test.not_found") {
+			t.Errorf("New with a taken code recovered %v, want a panic naming the code", r)
+		}
+	}()
+	New("test.not_found", "again")
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	sent := WithDetails(errNotFound, map[string]string{"id": "7"})
+	got := FromGRPC(GRPCStatus(http.StatusNotFound, sent))
+	e := From(got)
+	if !errors.Is(got, errNotFound) || e.Status != http.StatusNotFound || !reflect.DeepEqual(e.Details, map[string]string{"id": "7"}) {
+		t.Errorf("decoded %+v, want %v with its details and a 404", e, errNotFound)
+	}
+
+	for status, want := range map[int]int{
+		http.StatusConflict:            http.StatusConflict,
+		http.StatusUnprocessableEntity: http.StatusBadRequest,
+		http.StatusTeapot:              http.StatusBadRequest,
+		http.StatusBadGateway:          http.StatusInternalServerError,
+	} {
+		if got := StatusCode(FromGRPC(GRPCStatus(status, errors.New("x"))), 0); got != want {
+			t.Errorf("%d over gRPC came back %d, want %d", status, got, want)
+		}
+	}
+	if err := errors.New("encode failed"); FromGRPC(err) != err {
+		t.Error("FromGRPC changed an error that isn't a status")
+	}
+}
@@ -0,0 +1,100 @@
+package apierror
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain names the envelope's code in a gRPC status's ErrorInfo.
+const Domain = "matspinner"
+
+// GRPCStatus is Encode for gRPC: it turns err into a status whose code
+// matches the HTTP status the service would have answered with, carrying the
+// envelope's code and details in an ErrorInfo.
+func GRPCStatus(httpStatus int, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e := From(err)
+	st := status.New(grpcCode(httpStatus), e.Message)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: e.Code, Domain: Domain, Metadata: e.Details}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// FromGRPC is Decode for gRPC: it turns a status back into the service's
+// error, with Status set to the matching HTTP status. Errors that aren't a
+// status, such as a failed encode, are returned as they are.
+func FromGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	e := &Error{Code: CodeInternal, Message: st.Message(), Status: httpStatus(st.Code())}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			e.Code, e.Details = info.Reason, info.Metadata
+		}
+	}
+
+	mu.RLock()
+	e.err = byCode[e.Code]
+	mu.RUnlock()
+	return e
+}
+
+// GRPCClient makes a gRPC client endpoint return errors the way the HTTP
+// clients do, so errors.Is works the same whichever transport is used.
+func GRPCClient(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		return response, FromGRPC(err)
+	}
+}
+
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	if c, ok := grpcCodes[httpStatus]; ok {
+		return c
+	}
+	if httpStatus >= 400 && httpStatus < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Unknown
+}
+
+func httpStatus(c codes.Code) int {
+	for status, code := range grpcCodes {
+		if code == c {
+			return status
+		}
+	}
+	switch c {
+	case codes.Canceled:
+		return 499 // client closed request, as nginx has it
+	case codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
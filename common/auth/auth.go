@@ -0,0 +1,188 @@
+// Package auth authenticates callers of the matspinner services and
+// authorizes them by role. Callers present either a static API key or a
+// signed token; both resolve to an Identity that is stored in the request
+// context so endpoints can be gated by role and service middlewares can
+// record who did what.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var (
+	ErrUnauthenticated    = apierror.New("auth.unauthenticated", "authentication required")
+	ErrInvalidCredentials = apierror.New("auth.invalid_credentials", "invalid or expired credentials")
+	ErrForbidden          = apierror.New("auth.forbidden", "not allowed for this role")
+	ErrUnknownRole        = apierror.New("auth.unknown_role", "unknown role, should be one of admin, staff, display")
+	ErrWrongStore         = apierror.New("auth.wrong_store", "credentials are for a different store")
+
+	ErrNoCredentials = errors.New("auth is enabled but neither API keys nor a token secret are set, so every request would be refused; set AUTH_API_KEYS or AUTH_SECRET, or AUTH_DISABLED=true for local development")
+)
+
+// Role is what a caller is allowed to do. Roles are ordered: admin can do
+// everything staff can, and staff everything display can.
+type Role string
+
+const (
+	RoleDisplay Role = "display"
+	RoleStaff   Role = "staff"
+	RoleAdmin   Role = "admin"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleDisplay:
+		return 1
+	case RoleStaff:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Allows reports whether r grants at least the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if r.rank() == 0 {
+		return "", ErrUnknownRole
+	}
+	return r, nil
+}
+
+// Identity is an authenticated caller. Store and Session are only set for
+// staff who logged in to usersvc.
+type Identity struct {
+	Subject string `json:"sub"`
+	Role    Role   `json:"role"`
+	Store   string `json:"store,omitempty"`
+	Session string `json:"sid,omitempty"`
+}
+
+func (i Identity) String() string {
+	return fmt.Sprintf("%v(%v)", i.Subject, i.Role)
+}
+
+// Authenticator resolves a presented credential, an API key or a signed
+// token, to an Identity.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (Identity, error)
+}
+
+// Chain tries each Authenticator in turn and returns the first identity
+// found.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(ctx, credential)
+		if err == nil {
+			return id, nil
+		}
+		if err != ErrInvalidCredentials {
+			return Identity{}, err
+		}
+	}
+	return Identity{}, ErrInvalidCredentials
+}
+
+// StoreScoped rejects identities issued for a store other than store.
+// Identities without a store, such as API keys, are accepted.
+func StoreScoped(store string, next Authenticator) Authenticator {
+	return storeScoped{store: store, next: next}
+}
+
+type storeScoped struct {
+	store string
+	next  Authenticator
+}
+
+func (s storeScoped) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	id, err := s.next.Authenticate(ctx, credential)
+	if err != nil {
+		return Identity{}, err
+	}
+	if s.store != "" && id.Store != "" && id.Store != s.store {
+		return Identity{}, ErrWrongStore
+	}
+	return id, nil
+}
+
+// AllowAll authenticates every request, with or without a credential, as the
+// given identity. It exists for local development only.
+type AllowAll Identity
+
+func (a AllowAll) Authenticate(context.Context, string) (Identity, error) {
+	return Identity(a), nil
+}
+
+// APIKeys maps static API keys to the identity they stand for.
+type APIKeys map[string]Identity
+
+// ParseAPIKeys reads keys in the form "name:role:key", separated by commas.
+func ParseAPIKeys(spec string) (APIKeys, error) {
+	keys := APIKeys{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("api key %q should be name:role:key", entry)
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %w", parts[0], err)
+		}
+		keys[parts[2]] = Identity{Subject: parts[0], Role: role}
+	}
+	return keys, nil
+}
+
+func (k APIKeys) Authenticate(_ context.Context, credential string) (Identity, error) {
+	if id, ok := k[credential]; ok {
+		return id, nil
+	}
+	return Identity{}, ErrInvalidCredentials
+}
+
+// NewAuthenticator builds the authenticator every service uses from its
+// configuration: a list of API keys and a secret for verifying signed
+// tokens, either of which may be empty but not both, and the id of the store
+// the service runs for, if it should only accept staff logged in to that
+// store. If disabled is set every caller is treated as an anonymous admin.
+func NewAuthenticator(apiKeys, secret, store string, disabled bool) (Authenticator, error) {
+	if disabled {
+		return AllowAll{Subject: "anonymous", Role: RoleAdmin}, nil
+	}
+
+	var chain Chain
+	keys, err := ParseAPIKeys(apiKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		chain = append(chain, keys)
+	}
+	if secret != "" {
+		chain = append(chain, NewSigner(secret))
+	}
+	if len(chain) == 0 {
+		return nil, ErrNoCredentials
+	}
+	if store != "" {
+		return StoreScoped(store, chain), nil
+	}
+	return chain, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoleAllows(t *testing.T) {
+	roles := []Role{RoleDisplay, RoleStaff, RoleAdmin}
+	for i, have := range roles {
+		for j, required := range roles {
+			if got, want := have.Allows(required), i >= j; got != want {
+				t.Errorf("%s.Allows(%s) = %v, want %v", have, required, got, want)
+			}
+		}
+	}
+	if Role("owner").Allows(RoleDisplay) {
+		t.Error("an unknown role allows display")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	ok := func(context.Context, interface{}) (interface{}, error) { return "ok", nil }
+	staffOnly := Require(RoleStaff)(ok)
+	keys := APIKeys{"kd": {Subject: "tv", Role: RoleDisplay}, "ks": {Subject: "bob", Role: RoleStaff}, "ka": {Subject: "alice", Role: RoleAdmin}}
+
+	for _, tc := range []struct {
+		credential string
+		err        error
+	}{
+		{"", ErrUnauthenticated},
+		{"wrong", ErrInvalidCredentials},
+		{"kd", ErrForbidden},
+		{"ks", nil},
+		{"ka", nil},
+	} {
+		ctx := authenticate(context.Background(), keys, tc.credential)
+		if _, err := staffOnly(ctx, nil); !errors.Is(err, tc.err) {
+			t.Errorf("credential %q: err = %v, want %v", tc.credential, err, tc.err)
+		}
+	}
+}
+
+// fixed is an Authenticator giving the same answer to everything.
+type fixed struct {
+	id  Identity
+	err error
+}
+
+func (f fixed) Authenticate(context.Context, string) (Identity, error) { return f.id, f.err }
+
+func TestChain(t *testing.T) {
+	ann := fixed{id: Identity{Subject: "ann", Role: RoleStaff}}
+	bob := fixed{id: Identity{Subject: "bob", Role: RoleAdmin}}
+	invalid := fixed{err: ErrInvalidCredentials}
+	down := fixed{err: errors.New("sessions unavailable")}
+
+	for _, tc := range []struct {
+		name    string
+		chain   Chain
+		subject string
+		err     error
+	}{
+		{"first match wins", Chain{ann, bob}, "ann", nil},
+		{"invalid goes on to the next", Chain{invalid, bob}, "bob", nil},
+		{"other errors stop the chain", Chain{down, bob}, "", down.err},
+		{"nothing matches", Chain{invalid, invalid}, "", ErrInvalidCredentials},
+		{"empty", Chain{}, "", ErrInvalidCredentials},
+	} {
+		id, err := tc.chain.Authenticate(context.Background(), "credential")
+		if id.Subject != tc.subject || !errors.Is(err, tc.err) {
+			t.Errorf("%s: Authenticate = %v, %v, want %q, %v", tc.name, id, err, tc.subject, tc.err)
+		}
+	}
+}
+
+func TestStoreScoped(t *testing.T) {
+	a := StoreScoped("downtown", Chain{
+		APIKeys{"key": {Subject: "tv", Role: RoleDisplay}},
+		fixed{id: Identity{Subject: "ann", Role: RoleStaff, Store: "uptown"}},
+	})
+	if _, err := a.Authenticate(context.Background(), "key"); err != nil {
+		t.Errorf("API key without a store: %v", err)
+	}
+	if _, err := a.Authenticate(context.Background(), "token"); !errors.Is(err, ErrWrongStore) {
+		t.Errorf("another store's token err = %v, want %v", err, ErrWrongStore)
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	if _, err := NewAuthenticator("", "", "", false); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("no keys or secret err = %v, want %v", err, ErrNoCredentials)
+	}
+	for _, keys := range []string{"alice:admin", "alice:owner:ka", ":admin:ka"} {
+		if _, err := NewAuthenticator(keys, "", "", false); err == nil {
+			t.Errorf("keys %q accepted, want an error", keys)
+		}
+	}
+
+	a, err := NewAuthenticator("", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := a.Authenticate(context.Background(), ""); err != nil || id.Role != RoleAdmin {
+		t.Errorf("disabled auth = %v, %v, want an admin", id, err)
+	}
+
+	a, err = NewAuthenticator(" alice:admin:ka, bob:STAFF:ks ,", "secret", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := NewSigner("secret").Sign(Identity{Subject: "ann", Role: RoleDisplay}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for credential, want := range map[string]Identity{
+		"ka":  {Subject: "alice", Role: RoleAdmin},
+		"ks":  {Subject: "bob", Role: RoleStaff},
+		token: {Subject: "ann", Role: RoleDisplay},
+	} {
+		if got, err := a.Authenticate(context.Background(), credential); err != nil || got != want {
+			t.Errorf("Authenticate(%.8s) = %v, %v, want %v", credential, got, err, want)
+		}
+	}
+}
+
+func TestHTTPCredential(t *testing.T) {
+	for _, tc := range []struct {
+		authorization, apiKey, want string
+	}{
+		{"Bearer token", "", "token"},
+		{"bearer  token ", "key", "token"},
+		{"", " key ", "key"},
+		{"Basic dXNlcg==", "key", "key"},
+		{"", "", ""},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", tc.authorization)
+		r.Header.Set(APIKeyHeader, tc.apiKey)
+		if got := HTTPCredential(r); got != tc.want {
+			t.Errorf("HTTPCredential(%q, %q) = %q, want %q", tc.authorization, tc.apiKey, got, tc.want)
+		}
+	}
+}
+
+// counting is an Authenticator that counts the credentials it's given.
+type counting struct {
+	APIKeys
+	calls int
+}
+
+func (c *counting) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	c.calls++
+	return c.APIKeys.Authenticate(ctx, credential)
+}
+
+func TestAuthenticateHTTPOnce(t *testing.T) {
+	for _, credential := range []string{"ks", "wrong"} {
+		a := &counting{APIKeys: APIKeys{"ks": {Subject: "bob", Role: RoleStaff}}}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(APIKeyHeader, credential)
+
+		r = AuthenticateHTTP(a, r)
+		r = AuthenticateHTTP(a, r)
+		ctx := HTTPToContext(a)(r.Context(), r)
+		if a.calls != 1 {
+			t.Errorf("credential %q authenticated %d times, want once", credential, a.calls)
+		}
+		_, err := Require(RoleStaff)(func(context.Context, interface{}) (interface{}, error) { return nil, nil })(ctx, nil)
+		if want := map[string]error{"ks": nil, "wrong": ErrInvalidCredentials}[credential]; !errors.Is(err, want) {
+			t.Errorf("credential %q: Require err = %v, want %v", credential, err, want)
+		}
+	}
+}
+
+func TestContextToHTTP(t *testing.T) {
+	forward := ContextToHTTP("svc-key")
+	for _, tc := range []struct {
+		name, credential, want string
+	}{
+		{"on behalf of a caller", "ks", "Bearer ks"},
+		{"on its own behalf", "", "Bearer svc-key"},
+	} {
+		ctx := context.Background()
+		if tc.credential != "" {
+			ctx = authenticate(ctx, APIKeys{"ks": {Subject: "bob", Role: RoleStaff}}, tc.credential)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		forward(ctx, r)
+		if got := r.Header.Get("Authorization"); got != tc.want {
+			t.Errorf("%s: Authorization = %q, want %q", tc.name, got, tc.want)
+		}
+		if got := r.Header.Get(ServiceKeyHeader); got != "svc-key" {
+			t.Errorf("%s: %s = %q, want the service's key", tc.name, ServiceKeyHeader, got)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	ContextToHTTP("")(context.Background(), r)
+	if len(r.Header) != 0 {
+		t.Errorf("without a service key, headers = %v, want none", r.Header)
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var ErrSessionsUnknown = apierror.New("auth.sessions_unknown", "ended sessions haven't been fetched from usersvc yet, try again shortly")
+
+// SessionEnded reports whether the login session sid ended, by logging out
+// or by an admin changing the user's password or roles, before its token
+// expired.
+type SessionEnded func(ctx context.Context, sid string) (bool, error)
+
+// EndedSessions returns the sessions that ended before their tokens expired,
+// with the time each token expires.
+type EndedSessions func(ctx context.Context) (map[string]time.Time, error)
+
+// Revocable refuses signed tokens whose session has ended. API keys, which
+// have no session, are passed through.
+func Revocable(ended SessionEnded, next Authenticator) Authenticator {
+	return revocable{ended: ended, next: next}
+}
+
+type revocable struct {
+	ended SessionEnded
+	next  Authenticator
+}
+
+func (r revocable) Authenticate(ctx context.Context, credential string) (Identity, error) {
+	id, err := r.next.Authenticate(ctx, credential)
+	if err != nil || id.Session == "" {
+		return id, err
+	}
+	ended, err := r.ended(ctx, id.Session)
+	if err != nil {
+		return Identity{}, err
+	}
+	if ended {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return id, nil
+}
+
+// Revocations keeps a copy of the ended sessions for services that take
+// tokens but don't run usersvc, fetching it again every so often. Until the
+// first fetch succeeds every token is refused with ErrSessionsUnknown; after
+// that a failed fetch keeps the last copy, so a session ended while usersvc
+// is unreachable stays usable until the next successful fetch.
+type Revocations struct {
+	fetch  EndedSessions
+	every  time.Duration
+	logger log.Logger
+	quit   chan struct{}
+	done   chan struct{}
+
+	mtx    sync.RWMutex
+	ended  map[string]time.Time
+	loaded bool
+}
+
+// WatchRevocations fetches the ended sessions now and every interval after,
+// until Close.
+func WatchRevocations(fetch EndedSessions, every time.Duration, logger log.Logger) *Revocations {
+	r := &Revocations{
+		fetch:  fetch,
+		every:  every,
+		logger: logger,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+func (r *Revocations) loop() {
+	defer close(r.done)
+	t := time.NewTicker(r.every)
+	defer t.Stop()
+	for {
+		r.refresh()
+		select {
+		case <-t.C:
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *Revocations) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.every)
+	defer cancel()
+	ended, err := r.fetch(ctx)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "fetching ended sessions", "err", err)
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if !r.loaded {
+		level.Info(r.logger).Log("msg", "fetched ended sessions", "sessions", len(ended))
+	}
+	r.ended, r.loaded = ended, true
+}
+
+// Ended is the SessionEnded for Revocable.
+func (r *Revocations) Ended(_ context.Context, sid string) (bool, error) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if !r.loaded {
+		return false, ErrSessionsUnknown
+	}
+	_, ok := r.ended[sid]
+	return ok, nil
+}
+
+// Close stops fetching. It is a server.Hook.
+func (r *Revocations) Close(ctx context.Context) error {
+	close(r.quit)
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
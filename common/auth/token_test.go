@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSigner("secret")
+	s.now = func() time.Time { return now }
+	id := Identity{Subject: "ann", Role: RoleStaff, Store: "downtown", Session: "s1"}
+
+	token, err := s.Sign(id, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Authenticate(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("Authenticate = %v, want %v", got, id)
+	}
+
+	body, sig, _ := strings.Cut(token, ".")
+	other := NewSigner("other secret")
+	other.now = s.now
+	forged, err := other.Sign(Identity{Subject: "ann", Role: RoleAdmin}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedBody, _, _ := strings.Cut(forged, ".")
+	for name, credential := range map[string]string{
+		"another secret's":      forged,
+		"with its body swapped": forgedBody + "." + sig,
+		"with a bit flipped":    body + "." + flip(sig),
+		"without a signature":   body,
+		"empty":                 "",
+	} {
+		if _, err := s.Authenticate(context.Background(), credential); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("token %s: err = %v, want %v", name, err, ErrInvalidCredentials)
+		}
+	}
+
+	// a token is good until the second it expires
+	now = now.Add(time.Hour - time.Second)
+	if _, err := s.Authenticate(context.Background(), token); err != nil {
+		t.Errorf("token a second before expiry: %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := s.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expired token err = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestSignerRejectsUnknownRole(t *testing.T) {
+	s := NewSigner("secret")
+	token, err := s.Sign(Identity{Subject: "ann", Role: "owner"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("token with an unknown role err = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+// flip changes the first character of a base64 string to another valid one.
+func flip(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
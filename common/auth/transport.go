@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"google.golang.org/grpc/metadata"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	credentialKey
+	authErrKey
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// ServiceKeyHeader carries the calling service's own key on every call it
+// makes to another service, alongside the credential it forwards, so that
+// the callee can tell calls from services apart from calls from clients.
+const ServiceKeyHeader = "X-Service-Key"
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// FromContext returns the identity of the caller, if there is one.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey).(Identity)
+	return id, ok
+}
+
+// Subject names the caller for logs, "anonymous" if there is none.
+func Subject(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id.String()
+	}
+	return "anonymous"
+}
+
+// HTTPToContext authenticates the credential in the Authorization bearer or
+// X-API-Key header and stores the resulting identity in the context. A bad
+// credential is remembered rather than rejected here, so that Require can
+// report it through the normal error encoder. A request AuthenticateHTTP
+// already authenticated isn't authenticated again.
+func HTTPToContext(a Authenticator) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if authenticated(ctx) {
+			return ctx
+		}
+		return authenticate(ctx, a, HTTPCredential(r))
+	}
+}
+
+// AuthenticateHTTP is HTTPToContext for middleware that needs the caller
+// before the transport does, such as rate limiting. It returns r with the
+// outcome in its context, for HTTPToContext to reuse.
+func AuthenticateHTTP(a Authenticator, r *http.Request) *http.Request {
+	if authenticated(r.Context()) {
+		return r
+	}
+	return r.WithContext(authenticate(r.Context(), a, HTTPCredential(r)))
+}
+
+// HTTPCredential is the credential r was made with, from its Authorization
+// bearer or X-API-Key header, or "" if it has none.
+func HTTPCredential(r *http.Request) string {
+	return credentialFrom(r.Header.Get("Authorization"), r.Header.Get(APIKeyHeader))
+}
+
+// GRPCToContext is HTTPToContext for gRPC, reading the same credentials
+// from the request metadata.
+func GRPCToContext(a Authenticator) grpctransport.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		first := func(key string) string {
+			if v := md.Get(key); len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		}
+		return authenticate(ctx, a, credentialFrom(first("Authorization"), first(APIKeyHeader)))
+	}
+}
+
+// authenticated reports whether ctx holds the outcome of authenticating,
+// successful or not.
+func authenticated(ctx context.Context) bool {
+	_, ok := FromContext(ctx)
+	_, failed := ctx.Value(authErrKey).(error)
+	return ok || failed
+}
+
+func authenticate(ctx context.Context, a Authenticator, credential string) context.Context {
+	if credential != "" {
+		ctx = context.WithValue(ctx, credentialKey, credential)
+	}
+	id, err := a.Authenticate(ctx, credential)
+	if err != nil {
+		if credential == "" {
+			err = ErrUnauthenticated
+		}
+		return context.WithValue(ctx, authErrKey, err)
+	}
+	return NewContext(ctx, id)
+}
+
+// Require rejects calls from callers whose role doesn't allow at least role.
+func Require(role Role) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			id, ok := FromContext(ctx)
+			if !ok {
+				if err, ok := ctx.Value(authErrKey).(error); ok {
+					return nil, err
+				}
+				return nil, ErrUnauthenticated
+			}
+			if !id.Role.Allows(role) {
+				return nil, ErrForbidden
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// ContextToHTTP forwards the credential the current request was made with
+// to an upstream service, falling back to the calling service's own
+// credential, so that upstream audit logs record the original caller. The
+// service's own credential is also sent in the X-Service-Key header.
+func ContextToHTTP(fallback string) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		credential, _ := ctx.Value(credentialKey).(string)
+		if credential == "" {
+			credential = fallback
+		}
+		if credential != "" {
+			r.Header.Set("Authorization", "Bearer "+credential)
+		}
+		if fallback != "" {
+			r.Header.Set(ServiceKeyHeader, fallback)
+		}
+		return ctx
+	}
+}
+
+// credentialFrom picks the credential out of the Authorization and X-API-Key
+// header values, preferring a bearer token.
+func credentialFrom(authorization, apiKey string) string {
+	if authorization != "" {
+		if scheme, token, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(apiKey)
+}
+
+// ForwardCredentials is the client option every inter-service client should
+// use; see ContextToHTTP.
+func ForwardCredentials(fallback string) httptransport.ClientOption {
+	return httptransport.ClientBefore(ContextToHTTP(fallback))
+}
+
+// ContextToGRPC is ContextToHTTP for gRPC.
+func ContextToGRPC(fallback string) grpctransport.ClientRequestFunc {
+	return func(ctx context.Context, md *metadata.MD) context.Context {
+		credential, _ := ctx.Value(credentialKey).(string)
+		if credential == "" {
+			credential = fallback
+		}
+		if credential != "" {
+			md.Set("authorization", "Bearer "+credential)
+		}
+		return ctx
+	}
+}
+
+// ForwardGRPCCredentials is ForwardCredentials for gRPC clients.
+func ForwardGRPCCredentials(fallback string) grpctransport.ClientOption {
+	return grpctransport.ClientBefore(ContextToGRPC(fallback))
+}
@@ -0,0 +1,305 @@
+// Package config loads a binary's settings from defaults, a YAML file,
+// environment variables and command line flags, in increasing order of
+// precedence.
+//
+// Settings are plain structs. Each setting field may carry these tags:
+//
+//	yaml:"httpAddr"      key in the config file
+//	env:"HTTP_ADDR"      environment variable
+//	oldEnv:"HTTP_PORT"   former name of the variable, read with a warning
+//	                     when the current one isn't set
+//	flag:"http-addr"     command line flag
+//	help:"..."           flag usage
+//	secret:"true"        hide the value in -print-config output
+//
+// Nested structs are walked recursively, and any struct implementing
+// Validator is validated after loading. A Rest field takes the file's other
+// keys, for a command that only needs part of a service's settings.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const FileEnv = "CONFIG_FILE"
+
+var ErrUnsupportedField = errors.New("unsupported setting type, should be a string, bool, int, float or duration")
+
+// Rest collects the keys of a config file that aren't settings, instead of
+// them being an error. A command needing only some of a service's settings
+// inlines one alongside them, so it can be run with the service's file:
+//
+//	Rest config.Rest `yaml:",inline"`
+//
+// Print leaves it out, as nothing in it is known to be safe to show.
+type Rest map[string]interface{}
+
+var restType = reflect.TypeOf(Rest(nil))
+
+// Validator is implemented by settings that can check themselves once every
+// source has been applied.
+type Validator interface {
+	Validate() error
+}
+
+// Load fills cfg, a pointer to a settings struct holding the defaults, from
+// the file named by -config or CONFIG_FILE, then the environment, then args.
+// With -print-config it writes the result to stdout as YAML and exits, so
+// operators can see what a deployment actually resolved to.
+func Load(name string, cfg interface{}, args []string) error {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: %T is not a pointer to a struct", cfg)
+	}
+	settings, err := walk(root.Elem(), "")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	file := fs.String("config", os.Getenv(FileEnv), "YAML file to read settings from (env "+FileEnv+")")
+	print := fs.Bool("print-config", false, "print the resolved settings and exit")
+	flags := make([]*flagValue, 0, len(settings))
+	for _, s := range settings {
+		name := s.field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		usage := s.field.Tag.Get("help")
+		if env := s.field.Tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+		f := &flagValue{setting: s}
+		fs.Var(f, name, usage)
+		flags = append(flags, f)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		if err := loadFile(*file, cfg); err != nil {
+			return err
+		}
+	}
+	for _, s := range settings {
+		env := s.field.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		v, ok := os.LookupEnv(env)
+		if old := s.field.Tag.Get("oldEnv"); (!ok || v == "") && old != "" {
+			if v, ok = os.LookupEnv(old); ok && v != "" {
+				fmt.Fprintf(os.Stderr, "config: %s is deprecated, set %s instead\n", old, env)
+				env = old
+			}
+		}
+		if ok && v != "" {
+			if err := s.set(v); err != nil {
+				return fmt.Errorf("config: %s: %w", env, err)
+			}
+		}
+	}
+	for _, f := range flags {
+		if f.isSet {
+			// already parsed once by Set, so this can't fail
+			f.setting.set(f.raw)
+		}
+	}
+
+	if err := validate(root); err != nil {
+		return err
+	}
+
+	if *print {
+		if err := Print(os.Stdout, cfg); err != nil {
+			return err
+		}
+		os.Exit(0)
+	}
+	return nil
+}
+
+// Print writes cfg as YAML with secret settings masked.
+func Print(w io.Writer, cfg interface{}) error {
+	masked := reflect.New(reflect.TypeOf(cfg).Elem())
+	masked.Elem().Set(reflect.ValueOf(cfg).Elem())
+	settings, err := walk(masked.Elem(), "")
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.field.Tag.Get("secret") == "true" && !s.value.IsZero() {
+			s.value.SetString("********")
+		}
+	}
+	clearRest(masked.Elem())
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(masked.Interface()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func loadFile(path string, cfg interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// clearRest empties the Rest fields of v and the structs it holds.
+func clearRest(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i); {
+		case !v.Type().Field(i).IsExported():
+		case f.Type() == restType:
+			f.Set(reflect.Zero(restType))
+		case f.Kind() == reflect.Struct:
+			clearRest(f)
+		}
+	}
+}
+
+// validate checks nested settings before the structs that contain them.
+func validate(v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Struct && v.Type().Field(i).IsExported() {
+			if err := validate(f.Addr()); err != nil {
+				return err
+			}
+		}
+	}
+	if validator, ok := v.Addr().Interface().(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+type setting struct {
+	value reflect.Value
+	field reflect.StructField
+	path  string
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func walk(v reflect.Value, prefix string) ([]setting, error) {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type == restType {
+			continue
+		}
+		path := prefix + field.Name
+		if f := v.Field(i); f.Kind() == reflect.Struct {
+			if !field.Anonymous {
+				path += "."
+			} else {
+				path = prefix
+			}
+			nested, err := walk(f, path)
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, nested...)
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		default:
+			return nil, fmt.Errorf("config: %s: %w", path, ErrUnsupportedField)
+		}
+		settings = append(settings, setting{value: v.Field(i), field: field, path: path})
+	}
+	return settings, nil
+}
+
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int || s.value.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetInt(n)
+	case s.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		s.value.SetFloat(n)
+	}
+	return nil
+}
+
+// flagValue remembers a flag until the file and environment have been
+// applied, so that the flag wins over both.
+type flagValue struct {
+	setting setting
+	raw     string
+	isSet   bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil || !f.setting.value.IsValid() {
+		return ""
+	}
+	if f.setting.field.Tag.Get("secret") == "true" {
+		return ""
+	}
+	return fmt.Sprint(f.setting.value.Interface())
+}
+
+func (f *flagValue) Set(raw string) error {
+	// parse into a scratch value to report bad input now, with the flag name
+	scratch := setting{value: reflect.New(f.setting.value.Type()).Elem()}
+	if err := scratch.set(raw); err != nil {
+		return err
+	}
+	f.raw, f.isSet = raw, true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting.value.IsValid() && f.setting.value.Kind() == reflect.Bool
+}
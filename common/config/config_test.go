@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nested struct {
+	Timeout time.Duration `yaml:"timeout" env:"TEST_TIMEOUT" flag:"timeout"`
+	Rate    float64       `yaml:"rate" env:"TEST_RATE" flag:"rate"`
+}
+
+type testSettings struct {
+	Addr    string `yaml:"addr" env:"TEST_ADDR" oldEnv:"TEST_PORT" flag:"addr"`
+	Retries int    `yaml:"retries" env:"TEST_RETRIES" flag:"retries"`
+	Debug   bool   `yaml:"debug" env:"TEST_DEBUG" flag:"debug"`
+	Keys    string `yaml:"keys" env:"TEST_KEYS" flag:"keys" secret:"true"`
+	Nested  nested `yaml:"nested"`
+}
+
+func (s *testSettings) Validate() error {
+	if s.Retries < 0 {
+		return errors.New("retries can't be negative")
+	}
+	return nil
+}
+
+func defaults() testSettings {
+	return testSettings{Addr: ":8080", Retries: 1, Nested: nested{Timeout: time.Second, Rate: 10}}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "addr: :8081\nretries: 2\nnested:\n  timeout: 2s\n  rate: 20\n")
+	t.Setenv(FileEnv, file)
+	t.Setenv("TEST_RETRIES", "3")
+	t.Setenv("TEST_TIMEOUT", "3s")
+
+	cfg := defaults()
+	if err := Load("test", &cfg, []string{"-timeout", "4s"}); err != nil {
+		t.Fatal(err)
+	}
+	// each setting comes from the last of default, file, env and flag that
+	// sets it
+	want := testSettings{Addr: ":8081", Retries: 3, Nested: nested{Timeout: 4 * time.Second, Rate: 20}}
+	if cfg != want {
+		t.Errorf("settings = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadOldEnv(t *testing.T) {
+	t.Setenv("TEST_PORT", "9000")
+	cfg := defaults()
+	if err := Load("test", &cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "9000" {
+		t.Errorf("addr = %q, want it from the old variable", cfg.Addr)
+	}
+
+	// the current name wins when both are set
+	t.Setenv("TEST_ADDR", ":9001")
+	cfg = defaults()
+	if err := Load("test", &cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9001" {
+		t.Errorf("addr = %q, want it from the current variable", cfg.Addr)
+	}
+}
+
+func TestLoadParsesValues(t *testing.T) {
+	t.Setenv("TEST_TIMEOUT", "1m30s")
+	t.Setenv("TEST_RATE", "0.5")
+	t.Setenv("TEST_DEBUG", "true")
+	t.Setenv("TEST_KEYS", "a:admin:1, b:staff:2")
+	cfg := defaults()
+	if err := Load("test", &cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Nested.Timeout != 90*time.Second || cfg.Nested.Rate != 0.5 || !cfg.Debug {
+		t.Errorf("settings = %+v, want a 90s timeout, rate 0.5 and debug", cfg)
+	}
+	// lists are single settings, split by whatever reads them
+	if cfg.Keys != "a:admin:1, b:staff:2" {
+		t.Errorf("keys = %q, want the list as given", cfg.Keys)
+	}
+
+	for env, value := range map[string]string{
+		"TEST_TIMEOUT": "90",
+		"TEST_RETRIES": "two",
+		"TEST_DEBUG":   "yes please",
+		"TEST_RATE":    "fast",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			cfg := defaults()
+			if err := Load("test", &cfg, nil); err == nil || !strings.Contains(err.Error(), env) {
+				t.Errorf("Load with %s=%q err = %v, want one naming the variable", env, value, err)
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	cfg := defaults()
+	if err := Load("test", &cfg, []string{"-retries", "-1"}); err == nil {
+		t.Error("Load of invalid settings succeeded, want Validate's error")
+	}
+
+	cfg = defaults()
+	if err := Load("test", &cfg, []string{"-config", writeFile(t, "adress: :8081\n")}); err == nil {
+		t.Error("Load of a file with an unknown key succeeded, want an error")
+	}
+
+	var lists struct {
+		Hosts []string `env:"TEST_HOSTS"`
+	}
+	if err := Load("test", &lists, nil); !errors.Is(err, ErrUnsupportedField) {
+		t.Errorf("Load of a slice setting err = %v, want %v", err, ErrUnsupportedField)
+	}
+}
+
+func TestLoadRest(t *testing.T) {
+	// just the nested settings, from a file written for all of them
+	var part struct {
+		Nested nested `yaml:"nested"`
+		Rest   Rest   `yaml:",inline"`
+	}
+	file := writeFile(t, "addr: :8081\nkeys: a:admin:hunter2\nnested:\n  rate: 20\n")
+	if err := Load("test", &part, []string{"-config", file, "-timeout", "4s"}); err != nil {
+		t.Fatal(err)
+	}
+	if part.Nested != (nested{Timeout: 4 * time.Second, Rate: 20}) {
+		t.Errorf("nested = %+v, want it from the file and flags", part.Nested)
+	}
+
+	var out bytes.Buffer
+	if err := Print(&out, &part); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") || strings.Contains(out.String(), "addr") {
+		t.Errorf("printed\n%s\nwant only the settings", out.String())
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := defaults()
+	cfg.Keys = "a:admin:hunter2"
+	var out bytes.Buffer
+	if err := Print(&out, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), "keys: '********'") {
+		t.Errorf("printed\n%s\nwant the keys masked", out.String())
+	}
+	if !strings.Contains(out.String(), "addr: :8080") {
+		t.Errorf("printed\n%s\nwant the other settings as they are", out.String())
+	}
+	if cfg.Keys != "a:admin:hunter2" {
+		t.Errorf("Print changed the settings it printed, keys = %q", cfg.Keys)
+	}
+
+	// an unset secret prints as empty, so it's clear it isn't set
+	cfg.Keys = ""
+	out.Reset()
+	if err := Print(&out, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `keys: ""`) {
+		t.Errorf("printed\n%s\nwant the unset keys empty", out.String())
+	}
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/events"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+)
+
+var (
+	ErrMissingHTTPAddr  = errors.New("missing http address")
+	ErrUnknownLogLevel  = errors.New("unknown log level, should be debug, info, warn or error")
+	ErrUnknownLogFormat = errors.New("unknown log format, should be logfmt or json")
+	ErrMissingUpstream  = errors.New("missing upstream service url")
+	ErrInvalidTimeout   = errors.New("shutdown timeout must be positive")
+	ErrUnknownTransport = errors.New("unknown upstream transport, should be http or grpc")
+	ErrInvalidWebhook   = errors.New("webhook should be an http or https url")
+	ErrMissingSessions  = errors.New("auth secret is set but sessions url isn't, so tokens would keep working after their session ends; set SESSIONS_URL to usersvc")
+	ErrInvalidRefresh   = errors.New("sessions refresh must be positive")
+)
+
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// Service holds the settings every service binary shares. Embed it inline
+// in a binary's own settings struct.
+type Service struct {
+	HTTPAddr        string         `yaml:"httpAddr" env:"HTTP_ADDR" oldEnv:"HTTP_PORT" flag:"http-addr" help:"address to serve HTTP on, or just a port"`
+	ShutdownTimeout time.Duration  `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" help:"how long to wait for in-flight requests when stopping"`
+	Log             Log            `yaml:"log"`
+	Auth            Auth           `yaml:"auth"`
+	CORS            CORS           `yaml:"cors"`
+	Limits          limit.Settings `yaml:"limits"`
+	Events          Events         `yaml:"events"`
+	Tracing         Tracing        `yaml:"tracing"`
+}
+
+// DefaultService returns the shared defaults for a service listening on
+// httpAddr.
+func DefaultService(httpAddr string) Service {
+	return Service{
+		HTTPAddr:        httpAddr,
+		ShutdownTimeout: 8 * time.Second, // docker sends SIGKILL 10s after SIGTERM
+		Log:             Log{Level: "info", Format: "logfmt"},
+		CORS: CORS{
+			Methods: "GET, POST, PUT, DELETE",
+			Headers: "Authorization, Content-Type, X-API-Key",
+			MaxAge:  10 * time.Minute,
+		},
+		Limits:  limit.DefaultSettings(),
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+	}
+}
+
+func (s *Service) Validate() error {
+	if s.HTTPAddr == "" {
+		return ErrMissingHTTPAddr
+	}
+	if !strings.Contains(s.HTTPAddr, ":") {
+		// a bare port, as HTTP_PORT used to be
+		s.HTTPAddr = ":" + s.HTTPAddr
+	}
+	if s.ShutdownTimeout <= 0 {
+		return ErrInvalidTimeout
+	}
+	_, _, err := net.SplitHostPort(s.HTTPAddr)
+	return err
+}
+
+// RateLimits is the limits settings, with the key other services call this
+// one with so that their calls aren't limited per client.
+func (s Service) RateLimits() limit.Settings {
+	l := s.Limits
+	l.ServiceKey = s.Auth.ServiceAPIKey
+	return l
+}
+
+// GRPC holds the settings of services that also serve gRPC.
+type GRPC struct {
+	Addr string `yaml:"addr" env:"GRPC_ADDR" flag:"grpc-addr" help:"address to serve gRPC on, empty for none"`
+}
+
+func (g *GRPC) Validate() error {
+	if g.Addr == "" {
+		return nil
+	}
+	_, _, err := net.SplitHostPort(g.Addr)
+	return err
+}
+
+// ValidateTransport checks an upstream's transport setting.
+func ValidateTransport(transport string) error {
+	switch transport {
+	case TransportHTTP, TransportGRPC:
+		return nil
+	}
+	return ErrUnknownTransport
+}
+
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" help:"lowest level to log: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" help:"log line format: logfmt or json"`
+}
+
+func (l *Log) Validate() error {
+	if _, err := level.Parse(l.Level); err != nil {
+		return ErrUnknownLogLevel
+	}
+	if l.Format != "logfmt" && l.Format != "json" {
+		return ErrUnknownLogFormat
+	}
+	return nil
+}
+
+// NewLogger returns a logger writing to w in the configured format, with
+// lines below the configured level dropped.
+func (l Log) NewLogger(w io.Writer) log.Logger {
+	var logger log.Logger
+	if l.Format == "json" {
+		logger = log.NewJSONLogger(log.NewSyncWriter(w))
+	} else {
+		logger = log.NewLogfmtLogger(log.NewSyncWriter(w))
+	}
+	logger = level.NewFilter(logger, level.Allow(level.ParseDefault(l.Level, level.InfoValue())), level.SquelchNoLevel(true))
+	return log.With(logger, "ts", log.DefaultTimestampUTC)
+}
+
+type Auth struct {
+	APIKeys       string `yaml:"apiKeys" env:"AUTH_API_KEYS" flag:"auth-api-keys" help:"comma-separated name:role:key entries" secret:"true"`
+	Secret        string `yaml:"secret" env:"AUTH_SECRET" flag:"auth-secret" help:"secret used to verify signed tokens" secret:"true"`
+	Store         string `yaml:"store" env:"STORE_ID" flag:"store-id" help:"reject staff sessions issued for a different store"`
+	Disabled      bool   `yaml:"disabled" env:"AUTH_DISABLED" flag:"auth-disabled" help:"treat every caller as an admin, for local development only"`
+	ServiceAPIKey string `yaml:"serviceApiKey" env:"SERVICE_API_KEY" flag:"service-api-key" help:"key used when calling other services on this service's own behalf" secret:"true"`
+}
+
+func (a *Auth) Validate() error {
+	if a.APIKeys == "" {
+		return nil
+	}
+	_, err := auth.ParseAPIKeys(a.APIKeys)
+	return err
+}
+
+func (a Auth) NewAuthenticator() (auth.Authenticator, error) {
+	return auth.NewAuthenticator(a.APIKeys, a.Secret, a.Store, a.Disabled)
+}
+
+// Forward is the client option that passes credentials on to other
+// services.
+func (a Auth) Forward() httptransport.ClientOption {
+	return auth.ForwardCredentials(a.ServiceAPIKey)
+}
+
+// ForwardGRPC is Forward for gRPC clients.
+func (a Auth) ForwardGRPC() grpctransport.ClientOption {
+	return auth.ForwardGRPCCredentials(a.ServiceAPIKey)
+}
+
+// Sessions holds the settings of services that take the tokens usersvc
+// signs but don't run usersvc themselves. They fetch the sessions that ended
+// early from it, so that logging out takes effect everywhere.
+type Sessions struct {
+	URL     string        `yaml:"url" env:"SESSIONS_URL" flag:"sessions-url" help:"usersvc to fetch ended sessions from, required with auth.secret"`
+	Refresh time.Duration `yaml:"refresh" env:"SESSIONS_REFRESH" flag:"sessions-refresh" help:"how often to fetch ended sessions"`
+}
+
+func DefaultSessions() Sessions {
+	return Sessions{URL: "http://usersvc:8088", Refresh: 10 * time.Second}
+}
+
+func (s *Sessions) Validate() error {
+	if s.Refresh <= 0 {
+		return ErrInvalidRefresh
+	}
+	return nil
+}
+
+// Required checks that a service taking tokens under a knows where to fetch
+// ended sessions from.
+func (s Sessions) Required(a Auth) error {
+	if a.Secret != "" && !a.Disabled && s.URL == "" {
+		return ErrMissingSessions
+	}
+	return nil
+}
+
+// Watch wraps authn so that it refuses tokens whose session ended, as
+// fetched with fetch, if the service takes tokens under a. The returned func
+// stops fetching; run it on shutdown.
+func (s Sessions) Watch(a Auth, authn auth.Authenticator, fetch auth.EndedSessions, logger log.Logger) (auth.Authenticator, func(context.Context) error) {
+	if a.Secret == "" || a.Disabled || s.URL == "" {
+		return authn, func(context.Context) error { return nil }
+	}
+	r := auth.WatchRevocations(fetch, s.Refresh, log.With(logger, "component", "sessions"))
+	return auth.Revocable(r.Ended, authn), r.Close
+}
+
+// CORS is off until origins are given. It isn't needed when the UI is
+// served from the same origin as the API, as cmd/matspinner does.
+type CORS struct {
+	Origins     string        `yaml:"origins" env:"CORS_ORIGINS" flag:"cors-origins" help:"comma-separated origins browsers may call from, or * for any"`
+	Methods     string        `yaml:"methods" env:"CORS_METHODS" flag:"cors-methods" help:"comma-separated methods allowed cross-origin"`
+	Headers     string        `yaml:"headers" env:"CORS_HEADERS" flag:"cors-headers" help:"comma-separated request headers allowed cross-origin"`
+	Credentials bool          `yaml:"credentials" env:"CORS_CREDENTIALS" flag:"cors-credentials" help:"allow cross-origin requests with cookies"`
+	MaxAge      time.Duration `yaml:"maxAge" env:"CORS_MAX_AGE" flag:"cors-max-age" help:"how long browsers may cache a preflight answer"`
+}
+
+func (c *CORS) Validate() error {
+	return c.Policy().Validate()
+}
+
+func (c CORS) Policy() cors.Policy {
+	return cors.Policy{
+		Origins:     cors.Parse(c.Origins),
+		Methods:     cors.Parse(c.Methods),
+		Headers:     cors.Parse(c.Headers),
+		Credentials: c.Credentials,
+		MaxAge:      c.MaxAge,
+	}
+}
+
+// Events says where a binary's events go. Without a URL they stay in the
+// process, which is all cmd/matspinner needs.
+type Events struct {
+	URL      string `yaml:"url" env:"EVENTS_URL" flag:"events-url" help:"NATS server to share events through, empty to keep them in-process"`
+	Webhooks string `yaml:"webhooks" env:"EVENTS_WEBHOOKS" flag:"events-webhooks" help:"comma-separated urls to post every event to"`
+}
+
+func (e *Events) Validate() error {
+	for _, hook := range split(e.Webhooks) {
+		u, err := url.Parse(hook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidWebhook
+		}
+	}
+	return nil
+}
+
+// NewBus connects the binary called name to its bus and subscribes the
+// webhooks to it.
+func (e Events) NewBus(name string, logger log.Logger) (events.Bus, error) {
+	logger = log.With(logger, "component", "events")
+	var bus events.Bus = events.NewLocal(logger)
+	if e.URL != "" {
+		nats, err := events.NewNATS(e.URL, name, logger)
+		if err != nil {
+			return nil, err
+		}
+		bus = nats
+	}
+	if err := events.Webhooks(bus, split(e.Webhooks), logger); err != nil {
+		return nil, err
+	}
+	return bus, nil
+}
+
+func split(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" flag:"trace-exporter" help:"where to send spans: none, stdout or otlp"`
+}
+
+func (t *Tracing) Validate() error {
+	switch t.Exporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+		return nil
+	}
+	return tracing.ErrUnknownExporter
+}
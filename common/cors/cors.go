@@ -0,0 +1,121 @@
+// Package cors answers cross-origin requests from browsers, so the UI can
+// call the services from another origin than the one serving it. Every
+// service's HTTP handler is wrapped in the same policy, set by the cors
+// settings.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidOrigin       = errors.New("cors origin should be * or scheme://host[:port]")
+	ErrWildcardCredentials = errors.New("cors credentials can't be allowed for every origin")
+)
+
+// Policy says which origins may call a service from a browser, and how.
+// The zero Policy allows no origins, and adds no headers.
+type Policy struct {
+	// Origins are allowed origins such as https://display.example.com, or
+	// "*" for any.
+	Origins []string
+	Methods []string
+	Headers []string
+	// Credentials lets browsers send cookies and read responses to
+	// credentialed requests. It can't be combined with the "*" origin.
+	Credentials bool
+	// MaxAge is how long browsers may cache a preflight answer.
+	MaxAge time.Duration
+}
+
+// Parse splits a comma-separated list, as the settings give them.
+func Parse(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Validate checks that every origin is one a browser could send.
+func (p Policy) Validate() error {
+	for _, o := range p.Origins {
+		if o == "*" {
+			if p.Credentials {
+				return ErrWildcardCredentials
+			}
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return ErrInvalidOrigin
+		}
+	}
+	return nil
+}
+
+// exposed are the response headers, beyond the ones browsers always show,
+// that the UI reads: when to retry after a 429, and the successor of a
+// deprecated route.
+const exposed = "Retry-After, Deprecation, Link"
+
+func (p Policy) allows(origin string) bool {
+	for _, o := range p.Origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler adds p's headers to next's responses to allowed origins, and
+// answers their preflight requests itself. Requests from other origins are
+// served without the headers, so the browser withholds the response.
+func Handler(p Policy, next http.Handler) http.Handler {
+	if len(p.Origins) == 0 {
+		return next
+	}
+	var (
+		methods = strings.Join(p.Methods, ", ")
+		headers = strings.Join(p.Headers, ", ")
+		maxAge  = strconv.Itoa(int(p.MaxAge.Seconds()))
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if !p.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if p.Credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Allow-Headers", headers)
+		if p.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
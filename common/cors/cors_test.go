@@ -0,0 +1,110 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var policy = Policy{
+	Origins: []string{"https://display.example.com/"},
+	Methods: []string{"GET", "POST"},
+	Headers: []string{"Authorization", "Content-Type"},
+	MaxAge:  10 * time.Minute,
+}
+
+// serve sends r through policy's handler, and reports whether it reached
+// the service.
+func serve(p Policy, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	var served bool
+	rec := httptest.NewRecorder()
+	Handler(p, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		served = true
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(rec, r)
+	return rec, served
+}
+
+func preflight(origin string) *http.Request {
+	r := httptest.NewRequest("OPTIONS", "/spin", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "authorization")
+	return r
+}
+
+func TestPreflight(t *testing.T) {
+	rec, served := serve(policy, preflight("https://DISPLAY.example.com"))
+	if served || rec.Code != http.StatusNoContent {
+		t.Errorf("allowed preflight = %d, served %v, want 204 answered by the policy", rec.Code, served)
+	}
+	want := http.Header{
+		"Access-Control-Allow-Origin":  {"https://DISPLAY.example.com"},
+		"Access-Control-Allow-Methods": {"GET, POST"},
+		"Access-Control-Allow-Headers": {"Authorization, Content-Type"},
+		"Access-Control-Max-Age":       {"600"},
+		"Vary":                         {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+	}
+	if !reflect.DeepEqual(rec.Header(), want) {
+		t.Errorf("allowed preflight headers = %v, want %v", rec.Header(), want)
+	}
+
+	// a preflight from another origin gets no answer the browser accepts
+	rec, served = serve(policy, preflight("https://evil.example.com"))
+	if !served || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("denied preflight = %d %v, served %v, want it passed on without cors headers", rec.Code, rec.Header(), served)
+	}
+	if got := rec.Header().Values("Vary"); !reflect.DeepEqual(got, []string{"Origin"}) {
+		t.Errorf("denied preflight Vary = %v, want Origin", got)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	r := httptest.NewRequest("GET", "/tickets", nil)
+	r.Header.Set("Origin", "https://display.example.com")
+	rec, served := serve(Policy{Origins: []string{"*"}}, r)
+	if !served || rec.Header().Get("Access-Control-Allow-Origin") != "https://display.example.com" || rec.Header().Get("Access-Control-Expose-Headers") != exposed {
+		t.Errorf("allowed request = %v, served %v, want it served with cors headers", rec.Header(), served)
+	}
+
+	p := policy
+	p.Credentials = true
+	if rec, _ := serve(p, r); rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("credentialed request headers = %v, want credentials allowed", rec.Header())
+	}
+
+	// without an Origin it isn't a cross-origin request at all
+	rec, served = serve(policy, httptest.NewRequest("GET", "/tickets", nil))
+	if !served || len(rec.Header()) != 0 {
+		t.Errorf("same-origin request = %v, served %v, want it served untouched", rec.Header(), served)
+	}
+	// nor does the zero Policy touch anything
+	rec, served = serve(Policy{}, preflight("https://display.example.com"))
+	if !served || len(rec.Header()) != 0 {
+		t.Errorf("preflight with no policy = %v, served %v, want it served untouched", rec.Header(), served)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		policy Policy
+		want   error
+	}{
+		{Policy{Origins: []string{"*"}}, nil},
+		{Policy{Origins: []string{"http://localhost:3000", "https://display.example.com/"}, Credentials: true}, nil},
+		{Policy{Origins: []string{"*"}, Credentials: true}, ErrWildcardCredentials},
+		{Policy{Origins: []string{"display.example.com"}}, ErrInvalidOrigin},
+		{Policy{Origins: []string{"https://display.example.com/ui"}}, ErrInvalidOrigin},
+		{Policy{Origins: []string{"https://display.example.com?x=1"}}, ErrInvalidOrigin},
+	} {
+		if err := tc.policy.Validate(); !errors.Is(err, tc.want) {
+			t.Errorf("Validate(%v) = %v, want %v", tc.policy.Origins, err, tc.want)
+		}
+	}
+	if got := Parse(" https://a.example.com, ,http://b.example.com,"); !reflect.DeepEqual(got, []string{"https://a.example.com", "http://b.example.com"}) {
+		t.Errorf("Parse = %q", got)
+	}
+}
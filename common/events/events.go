@@ -0,0 +1,101 @@
+// Package events carries domain events, such as a completed spin, from the
+// service where they happen to whoever wants to react to them. Services
+// publish onto a Bus after changing state; subscribers such as spin history,
+// metrics and webhooks consume from it instead of being called inline.
+//
+// Delivery is at most once. The local bus loses no events, making publishers
+// wait while a subscriber is far behind, but over NATS an event published
+// while a subscriber is disconnected is lost to it.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// All subscribes to every event type.
+const All = "*"
+
+// Event is the envelope every event travels in. Type is namespaced by the
+// publishing service, as "spins.completed", and Data is the service's payload
+// for that type.
+type Event struct {
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// New wraps data as an event of the given type, happening now.
+func New(eventType string, data interface{}) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, Time: time.Now().UTC(), Data: raw}, nil
+}
+
+// Decode unmarshals the payload into v, which should be the publishing
+// service's type for e.Type.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Handler consumes one event. A returned error is logged by the bus; the
+// event isn't redelivered.
+type Handler func(ctx context.Context, e Event) error
+
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+type Bus interface {
+	Publisher
+
+	// Subscribe calls h for each event of eventType, or of every type for
+	// All. An eventType ending in ".*", such as "spins.*", matches every
+	// type under it, with one handler seeing them in the order they were
+	// published. Subscribers sharing a group split the events between them, so
+	// that a subscriber running in every instance of a service handles
+	// each event once. With no group every subscriber gets every event,
+	// for state each instance keeps for itself.
+	Subscribe(eventType, group string, h Handler) error
+
+	// Close stops delivering events once those already published have been
+	// handled, or ctx is done. It fits server.Server's OnClose hooks.
+	Close(ctx context.Context) error
+}
+
+// matches reports whether an event of type eventType is for a subscription
+// to pattern.
+func matches(pattern, eventType string) bool {
+	if pattern == All || pattern == eventType {
+		return true
+	}
+	if !strings.HasSuffix(pattern, ".*") {
+		return false
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+	return strings.HasPrefix(eventType, prefix) && !strings.Contains(eventType[len(prefix):], ".")
+}
+
+// Publish publishes data as an event of eventType on bus.
+func Publish(ctx context.Context, bus Publisher, eventType string, data interface{}) error {
+	e, err := New(eventType, data)
+	if err != nil {
+		return err
+	}
+	return bus.Publish(ctx, e)
+}
+
+// PublishOrLog is Publish for events about a change that stands whether or
+// not it is heard of: a failure is logged rather than returned.
+func PublishOrLog(ctx context.Context, bus Publisher, logger log.Logger, eventType string, data interface{}) {
+	if err := Publish(ctx, bus, eventType, data); err != nil {
+		level.Error(logger).Log("event", eventType, "err", err)
+	}
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+var ErrClosed = errors.New("event bus is closed")
+
+// queueSize is how many events a subscriber may fall behind by before
+// publishers wait for it.
+const queueSize = 256
+
+// Local is a Bus within one process, for a single binary or a service that
+// nothing outside it listens to. Each subscription has its own goroutine and
+// queue, so a slow subscriber delays neither publishers, until its queue is
+// full, nor the other subscribers.
+type Local struct {
+	logger log.Logger
+
+	mtx    sync.RWMutex
+	subs   []*localSub
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type localSub struct {
+	eventType string
+	group     string
+	h         Handler
+	queue     chan Event
+}
+
+func NewLocal(logger log.Logger) *Local {
+	return &Local{logger: logger}
+}
+
+// Publish queues e for every matching subscriber, waiting for room in a full
+// queue until ctx is done.
+func (b *Local) Publish(ctx context.Context, e Event) error {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	for _, s := range b.subs {
+		if !matches(s.eventType, e.Type) {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			level.Warn(b.logger).Log("msg", "subscriber is behind, waiting for it", "group", s.group, "type", e.Type)
+			select {
+			case s.queue <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe starts delivering to h. Within one process a group has nothing
+// to split, so it only names the subscription in logs.
+func (b *Local) Subscribe(eventType, group string, h Handler) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	s := &localSub{eventType: eventType, group: group, h: h, queue: make(chan Event, queueSize)}
+	b.subs = append(b.subs, s)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for e := range s.queue {
+			if err := s.h(context.Background(), e); err != nil {
+				level.Error(b.logger).Log("group", s.group, "type", e.Type, "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *Local) Close(ctx context.Context) error {
+	b.mtx.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subs {
+			close(s.queue)
+		}
+	}
+	b.mtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
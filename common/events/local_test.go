@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// recorder is a Handler keeping the types of the events it is given.
+type recorder struct {
+	mtx   sync.Mutex
+	types []string
+}
+
+func (r *recorder) handle(_ context.Context, e Event) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.types = append(r.types, e.Type)
+	return nil
+}
+
+func (r *recorder) got() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]string(nil), r.types...)
+}
+
+// waitFor waits for r to have been given want, in order.
+func (r *recorder) waitFor(t *testing.T, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.got()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := r.got(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func publish(t *testing.T, bus Publisher, types ...string) {
+	t.Helper()
+	for _, eventType := range types {
+		if err := Publish(context.Background(), bus, eventType, struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, eventType string
+		want               bool
+	}{
+		{All, "spins.completed", true},
+		{"spins.completed", "spins.completed", true},
+		{"spins.completed", "spins.voided", false},
+		{"spins.*", "spins.voided", true},
+		{"spins.*", "tickets.changed", false},
+		{"spins.*", "spins", false},
+		{"spins.*", "spins.voided.again", false},
+		{"spins", "spins.voided", false},
+	} {
+		if got := matches(tc.pattern, tc.eventType); got != tc.want {
+			t.Errorf("matches(%q, %q) = %v, want %v", tc.pattern, tc.eventType, got, tc.want)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	bus := NewLocal(log.NewNopLogger())
+	var exact, wildcard, all recorder
+	for _, sub := range []struct {
+		eventType string
+		r         *recorder
+	}{
+		{"spins.completed", &exact},
+		{"spins.*", &wildcard},
+		{All, &all},
+	} {
+		if err := bus.Subscribe(sub.eventType, "", sub.r.handle); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish(t, bus, "spins.completed", "tickets.changed", "spins.voided", "spins.completed")
+
+	exact.waitFor(t, "spins.completed", "spins.completed")
+	wildcard.waitFor(t, "spins.completed", "spins.voided", "spins.completed")
+	all.waitFor(t, "spins.completed", "tickets.changed", "spins.voided", "spins.completed")
+}
+
+func TestLocalDeliversEverythingBeforeClosing(t *testing.T) {
+	bus := NewLocal(log.NewNopLogger())
+	var r recorder
+	release := make(chan struct{})
+	err := bus.Subscribe(All, "slow", func(ctx context.Context, e Event) error {
+		<-release
+		return r.handle(ctx, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more than a queue's worth, so publishing waits for the subscriber
+	// instead of dropping events
+	const n = queueSize + 10
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < n; i++ {
+			if err := Publish(context.Background(), bus, "tickets.changed", nil); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-published:
+		t.Fatal("publishing didn't wait for the full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-published
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(r.got()); got != n {
+		t.Errorf("handled %d events before Close returned, want %d", got, n)
+	}
+	if err := Publish(context.Background(), bus, "tickets.changed", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish after Close err = %v, want %v", err, ErrClosed)
+	}
+	if err := bus.Subscribe(All, "", r.handle); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe after Close err = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestLocalPublishGivesUpWithContext(t *testing.T) {
+	bus := NewLocal(log.NewNopLogger())
+	release := make(chan struct{})
+	defer close(release)
+	if err := bus.Subscribe(All, "stuck", func(context.Context, Event) error { <-release; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var err error
+	for i := 0; i <= queueSize+1 && err == nil; i++ {
+		err = Publish(ctx, bus, "tickets.changed", nil)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish to a full queue err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
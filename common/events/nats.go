@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/nats-io/nats.go"
+)
+
+// subjectPrefix puts every event under one NATS subject tree, so the bus can
+// share a server with other applications.
+const subjectPrefix = "matspinner."
+
+// NATS is a Bus over a NATS server, for services running as separate
+// binaries. Groups are NATS queue groups.
+type NATS struct {
+	conn   *nats.Conn
+	closed chan struct{}
+	logger log.Logger
+}
+
+// NewNATS connects to the NATS server at url, naming the connection after
+// the service. It keeps reconnecting for as long as the service runs, and
+// events published while it is disconnected are buffered up to the client's
+// limit.
+func NewNATS(url, name string, logger log.Logger) (*NATS, error) {
+	b := &NATS{closed: make(chan struct{}), logger: logger}
+	conn, err := nats.Connect(url,
+		nats.Name(name),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				level.Warn(logger).Log("msg", "disconnected from event bus", "err", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			level.Info(logger).Log("msg", "reconnected to event bus", "url", c.ConnectedUrlRedacted())
+		}),
+		nats.ClosedHandler(func(*nats.Conn) { close(b.closed) }),
+	)
+	if err != nil {
+		return nil, err
+	}
+	b.conn = conn
+	return b, nil
+}
+
+func (b *NATS) Publish(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.conn.Publish(subject(e.Type), data)
+}
+
+func (b *NATS) Subscribe(eventType, group string, h Handler) error {
+	_, err := b.conn.QueueSubscribe(subject(eventType), group, func(m *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			level.Error(b.logger).Log("group", group, "subject", m.Subject, "err", err)
+			return
+		}
+		if err := h(context.Background(), e); err != nil {
+			level.Error(b.logger).Log("group", group, "type", e.Type, "err", err)
+		}
+	})
+	return err
+}
+
+// Close flushes events still being published and lets subscribers finish
+// the ones they have received before disconnecting.
+func (b *NATS) Close(ctx context.Context) error {
+	if err := b.conn.Drain(); err != nil {
+		return err
+	}
+	select {
+	case <-b.closed:
+		return nil
+	case <-ctx.Done():
+		b.conn.Close()
+		return ctx.Err()
+	}
+}
+
+func subject(eventType string) string {
+	if eventType == All {
+		return subjectPrefix + ">"
+	}
+	return subjectPrefix + eventType
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// runNATS starts a NATS server on a free local port for the test.
+func runNATS(t *testing.T) string {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server didn't start")
+	}
+	return srv.ClientURL()
+}
+
+// connect returns a bus over url, as one instance of a service would have,
+// closed when the test ends.
+func connect(t *testing.T, url string) *NATS {
+	t.Helper()
+	bus, err := NewNATS(url, t.Name(), log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	return bus
+}
+
+// subscribe subscribes r on bus and waits for the server to have the
+// subscription, so that events published from other connections reach it.
+func subscribe(t *testing.T, bus *NATS, eventType, group string, r *recorder) {
+	t.Helper()
+	if err := bus.Subscribe(eventType, group, r.handle); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNATS(t *testing.T) {
+	url := runNATS(t)
+	publisher, instance := connect(t, url), connect(t, url)
+	var exact, wildcard, all recorder
+	subscribe(t, instance, "spins.completed", "", &exact)
+	subscribe(t, instance, "spins.*", "", &wildcard)
+	subscribe(t, instance, All, "", &all)
+
+	publish(t, publisher, "spins.completed", "tickets.changed", "spins.voided", "spins.completed")
+
+	exact.waitFor(t, "spins.completed", "spins.completed")
+	wildcard.waitFor(t, "spins.completed", "spins.voided", "spins.completed")
+	all.waitFor(t, "spins.completed", "tickets.changed", "spins.voided", "spins.completed")
+}
+
+func TestNATSGroups(t *testing.T) {
+	url := runNATS(t)
+	publisher := connect(t, url)
+
+	// two instances of a service: the shared group's events are split
+	// between them, and each instance's own subscription gets every one
+	var shared, own [2]recorder
+	for i := range shared {
+		instance := connect(t, url)
+		subscribe(t, instance, "tickets.changed", "ticketsvc.metrics", &shared[i])
+		subscribe(t, instance, "tickets.changed", "", &own[i])
+	}
+
+	const n = 20
+	want := make([]string, n)
+	for i := range want {
+		want[i] = "tickets.changed"
+	}
+	publish(t, publisher, want...)
+
+	own[0].waitFor(t, want...)
+	own[1].waitFor(t, want...)
+	handled := func() int { return len(shared[0].got()) + len(shared[1].got()) }
+	deadline := time.Now().Add(2 * time.Second)
+	for handled() < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// give a duplicate time to arrive
+	time.Sleep(50 * time.Millisecond)
+	if got := handled(); got != n {
+		t.Errorf("the group handled %d events, want each of the %d once", got, n)
+	}
+}
+
+func TestNATSCloseDeliversReceived(t *testing.T) {
+	url := runNATS(t)
+	bus := connect(t, url)
+	var r recorder
+	release := make(chan struct{})
+	err := bus.Subscribe(All, "", func(ctx context.Context, e Event) error {
+		<-release
+		return r.handle(ctx, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, bus, "spins.completed", "spins.voided")
+	if err := bus.conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- bus.Close(context.Background()) }()
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if got := r.got(); len(got) != 2 {
+		t.Errorf("handled %v before Close returned, want both events", got)
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// webhookTimeout bounds each delivery, so an unreachable hook can't hold up
+// the ones after it for long.
+const webhookTimeout = 5 * time.Second
+
+// Webhooks posts every event on bus, as its JSON envelope, to each of urls.
+// A failed delivery is logged and not retried. The subscription's group is
+// "webhooks", so with a shared bus each event is posted once however many
+// binaries have hooks configured.
+func Webhooks(bus Bus, urls []string, logger log.Logger) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	return bus.Subscribe(All, "webhooks", func(ctx context.Context, e Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		for _, url := range urls {
+			if err := post(ctx, client, url, body); err != nil {
+				level.Warn(logger).Log("msg", "webhook failed", "url", url, "type", e.Type, "err", err)
+			}
+		}
+		return nil
+	})
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package health serves the /healthz and /readyz probes shared by every
+// service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout bounds how long a single readiness check may take.
+const DefaultTimeout = 2 * time.Second
+
+// Check is a named readiness dependency, such as storage or an upstream
+// service.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Optional checks are reported but don't make the service unready, for
+	// dependencies only some of its requests need.
+	Optional bool
+}
+
+// Optional returns c as a check that is only reported.
+func Optional(c Check) Check {
+	c.Optional = true
+	return c
+}
+
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Live reports that the process is running and serving HTTP. It never checks
+// dependencies, so a restart is only triggered by the service itself hanging.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encode(w, Report{Status: StatusUp})
+	})
+}
+
+// Ready runs every check concurrently and responds 503 with the failing
+// checks' errors if any required one fails or takes longer than timeout.
+func Ready(timeout time.Duration, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		encode(w, Run(ctx, checks...))
+	})
+}
+
+// Run runs checks concurrently and collects their results.
+func Run(ctx context.Context, checks ...Check) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+
+	var (
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			result := Result{Status: StatusUp}
+			if err := run(ctx, c); err != nil {
+				result = Result{Status: StatusDown, Error: err.Error()}
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			report.Checks[c.Name] = result
+			if result.Status == StatusDown && !c.Optional {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+// run returns when c does or when ctx expires, whichever comes first, so a
+// check blocked on a lock can't hang the probe.
+func run(ctx context.Context, c Check) error {
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reachable checks that the service at instance answers its own liveness
+// probe. Its readiness isn't consulted, so one failing dependency doesn't
+// take down every service that calls it.
+func Reachable(name, instance string) Check {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	url := strings.TrimSuffix(instance, "/") + "/healthz"
+	return Check{
+		Name: name,
+		Check: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("%s responded %s", url, resp.Status)
+			}
+			return nil
+		},
+	}
+}
+
+// ReachableGRPC is Reachable for an instance serving gRPC, asking the
+// standard gRPC health service about the server as a whole.
+func ReachableGRPC(name, instance string) Check {
+	return Check{
+		Name: name,
+		Check: func(ctx context.Context) error {
+			conn, err := grpc.DialContext(ctx, instance, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			if err != nil {
+				return err
+			}
+			if resp.Status != healthpb.HealthCheckResponse_SERVING {
+				return fmt.Errorf("%s is %s", instance, resp.Status)
+			}
+			return nil
+		},
+	}
+}
+
+func encode(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, h http.Handler) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, report
+}
+
+func check(name string, err error) Check {
+	return Check{Name: name, Check: func(context.Context) error { return err }}
+}
+
+func TestLive(t *testing.T) {
+	if code, report := get(t, Live()); code != http.StatusOK || report.Status != StatusUp {
+		t.Errorf("Live = %d %v, want 200 up", code, report)
+	}
+}
+
+func TestReady(t *testing.T) {
+	code, report := get(t, Ready(time.Second, check("storage", nil), check("ticketsvc", nil)))
+	if code != http.StatusOK || report.Status != StatusUp || len(report.Checks) != 2 {
+		t.Errorf("Ready with passing checks = %d %v, want 200 with both up", code, report)
+	}
+
+	code, report = get(t, Ready(time.Second, check("storage", nil), check("ticketsvc", errors.New("connection refused"))))
+	want := Report{Status: StatusDown, Checks: map[string]Result{
+		"storage":   {Status: StatusUp},
+		"ticketsvc": {Status: StatusDown, Error: "connection refused"},
+	}}
+	if code != http.StatusServiceUnavailable || !reflect.DeepEqual(report, want) {
+		t.Errorf("Ready with a failing check = %d %v, want 503 %v", code, report, want)
+	}
+}
+
+func TestReadyOptional(t *testing.T) {
+	code, report := get(t, Ready(time.Second, check("ticketsvc", nil), Optional(check("spinsvc", errors.New("connection refused")))))
+	want := Report{Status: StatusUp, Checks: map[string]Result{
+		"ticketsvc": {Status: StatusUp},
+		"spinsvc":   {Status: StatusDown, Error: "connection refused"},
+	}}
+	if code != http.StatusOK || !reflect.DeepEqual(report, want) {
+		t.Errorf("Ready with a failing optional check = %d %v, want 200 %v", code, report, want)
+	}
+}
+
+func TestReadyTimesOut(t *testing.T) {
+	stuck := make(chan struct{})
+	defer close(stuck)
+	hangs := Check{Name: "storage", Check: func(context.Context) error {
+		// ignores its context, like a check blocked on a lock
+		<-stuck
+		return nil
+	}}
+
+	start := time.Now()
+	code, report := get(t, Ready(50*time.Millisecond, hangs, check("ticketsvc", nil)))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Ready took %v, want it to give up after its timeout", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || report.Checks["storage"].Status != StatusDown || report.Checks["ticketsvc"].Status != StatusUp {
+		t.Errorf("Ready with a hanging check = %d %v, want 503 with only it down", code, report)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	up := httptest.NewServer(Live())
+	defer up.Close()
+	down := httptest.NewServer(Ready(time.Second, check("storage", errors.New("full"))))
+	defer down.Close()
+	gone := httptest.NewServer(Live())
+	gone.Close()
+
+	ctx := context.Background()
+	if err := Reachable("up", up.URL).Check(ctx); err != nil {
+		t.Errorf("Reachable(up) = %v, want nil", err)
+	}
+	// without a scheme, as instances from DNS are
+	if err := Reachable("up", up.Listener.Addr().String()).Check(ctx); err != nil {
+		t.Errorf("Reachable(host:port) = %v, want nil", err)
+	}
+	if err := Reachable("down", down.URL).Check(ctx); err == nil {
+		t.Error("Reachable of an instance answering 503 = nil, want an error")
+	}
+	if err := Reachable("gone", gone.URL).Check(ctx); err == nil {
+		t.Error("Reachable of a stopped instance = nil, want an error")
+	}
+}
@@ -0,0 +1,220 @@
+// Package limit protects a service's HTTP routes from being flooded: it rate
+// limits each client, and the service as a whole, and caps the size of
+// request bodies. A client is the identity its credential authenticates as
+// at one IP address, or just the address when it has no valid credential.
+// Calls from other services are only held to the limit on the whole
+// service.
+package limit
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+)
+
+var (
+	ErrRateLimited  = apierror.New("request.rate_limited", "too many requests, try again later")
+	ErrBodyTooLarge = apierror.New("request.too_large", "request body too large")
+
+	ErrInvalidRate    = errors.New("rate limits can't be negative")
+	ErrInvalidMaxBody = errors.New("max body size must be positive")
+)
+
+// Settings are the limits on requests a service serves. Include them in a
+// binary's settings as `limits`.
+type Settings struct {
+	Rate         float64 `yaml:"rate" env:"RATE_LIMIT" flag:"rate-limit" help:"reads per second from each client, 0 for no limit"`
+	WriteRate    float64 `yaml:"writeRate" env:"RATE_LIMIT_WRITE" flag:"rate-limit-write" help:"requests per second that change something, from each client, 0 for no limit"`
+	GlobalRate   float64 `yaml:"globalRate" env:"RATE_LIMIT_GLOBAL" flag:"rate-limit-global" help:"requests per second from all clients together, 0 for no limit"`
+	MaxBodyBytes int64   `yaml:"maxBodyBytes" env:"MAX_BODY_BYTES" flag:"max-body-bytes" help:"largest request body accepted, in bytes"`
+
+	// ServiceKey is the key services send each other in the X-Service-Key
+	// header. Their calls were limited by client where they came in, so
+	// they aren't again here. It's the SERVICE_API_KEY auth setting, which
+	// config.Service.RateLimits fills in.
+	ServiceKey string `yaml:"-"`
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		Rate:         20,
+		WriteRate:    10,
+		GlobalRate:   500,
+		MaxBodyBytes: 1 << 20,
+	}
+}
+
+func (s *Settings) Validate() error {
+	switch {
+	case s.Rate < 0 || s.WriteRate < 0 || s.GlobalRate < 0:
+		return ErrInvalidRate
+	case s.MaxBodyBytes <= 0:
+		return ErrInvalidMaxBody
+	}
+	return nil
+}
+
+// idle is how long a client's buckets are kept after its last request.
+const idle = 10 * time.Minute
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// clients holds a token bucket for each client, dropping the buckets of
+// clients that have gone quiet so that a scan from many addresses can't
+// grow it without bound.
+type clients struct {
+	limit rate.Limit
+	burst int
+
+	mtx   sync.Mutex
+	byKey map[string]*bucket
+	swept time.Time
+}
+
+func newClients(perSecond float64) *clients {
+	if perSecond == 0 {
+		return nil
+	}
+	return &clients{limit: rate.Limit(perSecond), burst: burst(perSecond), byKey: map[string]*bucket{}}
+}
+
+func (c *clients) reserve(key string, now time.Time) *rate.Reservation {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if now.Sub(c.swept) > idle {
+		for k, b := range c.byKey {
+			if now.Sub(b.seen) > idle {
+				delete(c.byKey, k)
+			}
+		}
+		c.swept = now
+	}
+	b := c.byKey[key]
+	if b == nil {
+		b = &bucket{limiter: rate.NewLimiter(c.limit, c.burst)}
+		c.byKey[key] = b
+	}
+	b.seen = now
+	return b.limiter.ReserveN(now, 1)
+}
+
+func burst(perSecond float64) int {
+	return int(math.Ceil(perSecond))
+}
+
+// Handler applies s to the requests next serves. Requests over a rate limit
+// are answered 429 with ErrRateLimited and a Retry-After header, without
+// reaching next. Bodies longer than s.MaxBodyBytes fail to read with an
+// *http.MaxBytesError, which BodyError turns into ErrBodyTooLarge, and are
+// refused up front when their Content-Length says so. The zero Settings
+// limit nothing. Clients are identified with authn, and the outcome is kept
+// in the request's context for auth.HTTPToContext.
+func Handler(s Settings, authn auth.Authenticator, next http.Handler) http.Handler {
+	var (
+		reads  = newClients(s.Rate)
+		writes = newClients(s.WriteRate)
+		global *rate.Limiter
+	)
+	if s.GlobalRate > 0 {
+		global = rate.NewLimiter(rate.Limit(s.GlobalRate), burst(s.GlobalRate))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.MaxBodyBytes > 0 {
+			if r.ContentLength > s.MaxBodyBytes {
+				apierror.Encode(w, http.StatusRequestEntityTooLarge, tooLarge(s.MaxBodyBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, s.MaxBodyBytes)
+		}
+
+		now := time.Now()
+		perClient := reads
+		if isWrite(r.Method) {
+			perClient = writes
+		}
+		var reservations []*rate.Reservation
+		if perClient != nil && !fromService(r, s.ServiceKey) {
+			r = auth.AuthenticateHTTP(authn, r)
+			reservations = append(reservations, perClient.reserve(clientKey(r), now))
+		}
+		if global != nil {
+			reservations = append(reservations, global.ReserveN(now, 1))
+		}
+		var wait time.Duration
+		for _, res := range reservations {
+			if d := res.DelayFrom(now); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			// the request isn't going ahead, so give its tokens back
+			for _, res := range reservations {
+				res.CancelAt(now)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierror.Encode(w, http.StatusTooManyRequests, ErrRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BodyError is ErrBodyTooLarge if err is from reading a body over the limit
+// Handler set, and otherwise err, for decoders to return.
+func BodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return tooLarge(maxErr.Limit)
+	}
+	return err
+}
+
+func tooLarge(max int64) error {
+	return apierror.WithDetails(ErrBodyTooLarge, map[string]string{"maxBytes": strconv.FormatInt(max, 10)})
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// fromService reports whether r was made by another service, holding key.
+func fromService(r *http.Request, key string) bool {
+	given := r.Header.Get(auth.ServiceKeyHeader)
+	return key != "" && subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1
+}
+
+// clientKey names the caller by the identity its credential authenticated
+// as, and its IP address, so that every key and token of one user at one
+// place shares its buckets, while screens sharing a display key each have
+// their own. A caller without a credential that authenticates is named by
+// its address alone: keying on the credential as given would let it skip
+// the limit by sending a different made-up one each time. With
+// authentication disabled every credential is the same anonymous identity,
+// so those callers are only told apart by address.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if id, ok := auth.FromContext(r.Context()); ok && auth.HTTPCredential(r) != "" {
+		return "id " + id.Store + "/" + id.Subject + " ip " + host
+	}
+	return "ip " + host
+}
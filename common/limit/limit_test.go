@@ -0,0 +1,211 @@
+package limit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+)
+
+var keys = auth.APIKeys{
+	"ada-1": {Subject: "ada", Role: auth.RoleStaff},
+	"ada-2": {Subject: "ada", Role: auth.RoleStaff},
+	"bob":   {Subject: "bob", Role: auth.RoleStaff},
+}
+
+// ok answers every request it gets 200, reading the body through first.
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if _, err := io.ReadAll(r.Body); err != nil {
+		apierror.Encode(w, http.StatusRequestEntityTooLarge, BodyError(err))
+		return
+	}
+})
+
+func call(h http.Handler, method, remote, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	r.RemoteAddr = remote + ":1234"
+	if key != "" {
+		r.Header.Set(auth.APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func code(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var e apierror.Error
+	if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	return e.Code
+}
+
+func TestRateLimited(t *testing.T) {
+	h := Handler(Settings{Rate: 1}, keys, ok)
+
+	if w := call(h, "GET", "10.0.0.1", "ada-1"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", w.Code)
+	}
+	w := call(h, "GET", "10.0.0.1", "ada-1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := code(t, w); got != "request.rate_limited" {
+		t.Errorf("code = %q, want request.rate_limited", got)
+	}
+}
+
+func TestClientKeys(t *testing.T) {
+	h := Handler(Settings{Rate: 1}, keys, ok)
+
+	call(h, "GET", "10.0.0.1", "ada-1")
+	if w := call(h, "GET", "10.0.0.1", "ada-2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("another key of the same user: status = %d, want 429", w.Code)
+	}
+	if w := call(h, "GET", "10.0.0.2", "ada-1"); w.Code != http.StatusOK {
+		t.Errorf("the same key from elsewhere, as a shared display key is: status = %d, want 200", w.Code)
+	}
+	if w := call(h, "GET", "10.0.0.1", "bob"); w.Code != http.StatusOK {
+		t.Errorf("another user from the same address: status = %d, want 200", w.Code)
+	}
+
+	call(h, "GET", "10.0.0.3", "made-up-1")
+	if w := call(h, "GET", "10.0.0.3", "made-up-2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("a second invalid key from the same address: status = %d, want 429", w.Code)
+	}
+	if w := call(h, "GET", "10.0.0.4", ""); w.Code != http.StatusOK {
+		t.Errorf("no key from a new address: status = %d, want 200", w.Code)
+	}
+}
+
+func TestServiceCalls(t *testing.T) {
+	h := Handler(Settings{Rate: 1, GlobalRate: 3, ServiceKey: "svc-key"}, keys, ok)
+	fromService := func(serviceKey, key string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.0.0.9:1234"
+		r.Header.Set(auth.APIKeyHeader, key)
+		r.Header.Set(auth.ServiceKeyHeader, serviceKey)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// a service forwarding its callers' requests, each limited where it
+	// came in, isn't limited by client again
+	for i := 0; i < 3; i++ {
+		if code := fromService("svc-key", "ada-1"); code != http.StatusOK {
+			t.Fatalf("call %d from a service: status = %d, want 200", i, code)
+		}
+	}
+	// but still counts toward the limit on the whole service
+	if code := fromService("svc-key", "bob"); code != http.StatusTooManyRequests {
+		t.Errorf("call from a service over the global limit: status = %d, want 429", code)
+	}
+
+	h = Handler(Settings{Rate: 1, ServiceKey: "svc-key"}, keys, ok)
+	call(h, "GET", "10.0.0.9", "ada-1")
+	if code := fromService("guessed", "ada-1"); code != http.StatusTooManyRequests {
+		t.Errorf("call with the wrong service key: status = %d, want 429", code)
+	}
+}
+
+func TestAuthenticatesOnce(t *testing.T) {
+	var calls int
+	authn := authFunc(func(ctx context.Context, credential string) (auth.Identity, error) {
+		calls++
+		return keys.Authenticate(ctx, credential)
+	})
+	var id auth.Identity
+	h := Handler(Settings{Rate: 10}, authn, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.HTTPToContext(authn)(r.Context(), r)
+		id, _ = auth.FromContext(ctx)
+	}))
+	call(h, "GET", "10.0.0.1", "bob")
+	if calls != 1 || id.Subject != "bob" {
+		t.Errorf("authenticated %d times as %v, want once as bob", calls, id)
+	}
+}
+
+type authFunc func(ctx context.Context, credential string) (auth.Identity, error)
+
+func (f authFunc) Authenticate(ctx context.Context, credential string) (auth.Identity, error) {
+	return f(ctx, credential)
+}
+
+func TestWriteRate(t *testing.T) {
+	h := Handler(Settings{Rate: 100, WriteRate: 1}, keys, ok)
+
+	if w := call(h, "POST", "10.0.0.1", "ada-1"); w.Code != http.StatusOK {
+		t.Fatalf("first write: status = %d, want 200", w.Code)
+	}
+	if w := call(h, "PUT", "10.0.0.1", "ada-1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second write: status = %d, want 429", w.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if w := call(h, "GET", "10.0.0.1", "ada-1"); w.Code != http.StatusOK {
+			t.Fatalf("read %d after the writes: status = %d, want 200", i, w.Code)
+		}
+	}
+}
+
+func TestGlobalRate(t *testing.T) {
+	h := Handler(Settings{Rate: 100, GlobalRate: 2}, keys, ok)
+
+	call(h, "GET", "10.0.0.1", "ada-1")
+	call(h, "GET", "10.0.0.2", "bob")
+	if w := call(h, "GET", "10.0.0.3", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third client: status = %d, want 429", w.Code)
+	}
+}
+
+func TestMaxBody(t *testing.T) {
+	h := Handler(Settings{MaxBodyBytes: 10}, keys, ok)
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		length int64
+		status int
+	}{
+		{"within the limit", "0123456789", 10, http.StatusOK},
+		{"declared too long", strings.Repeat("x", 11), 11, http.StatusRequestEntityTooLarge},
+		{"read too long", strings.Repeat("x", 11), -1, http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			r.ContentLength = tc.length
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+			if tc.status == http.StatusOK {
+				return
+			}
+			if got := code(t, w); got != "request.too_large" {
+				t.Errorf("code = %q, want request.too_large", got)
+			}
+		})
+	}
+}
+
+func TestBodyError(t *testing.T) {
+	other := errors.New("unexpected EOF")
+	if err := BodyError(other); err != other {
+		t.Errorf("BodyError(%v) = %v, want it unchanged", other, err)
+	}
+	if err := BodyError(&http.MaxBytesError{Limit: 10}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("BodyError(MaxBytesError) = %v, want %v", err, ErrBodyTooLarge)
+	}
+}
@@ -0,0 +1,396 @@
+// Package openapi publishes a service's OpenAPI 3 document at /openapi.json
+// and rejects requests that don't match it before they reach the decoders,
+// answering 400 with the offending field named, or 415 for a body of a type
+// the operation doesn't take. Each service embeds its document, and its
+// tests CheckRoutes against its router so the two can't drift apart.
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/limit"
+)
+
+// Path is where Serve publishes the document.
+const Path = "/openapi.json"
+
+var (
+	ErrInvalidRequest  = apierror.New("request.invalid", "invalid request")
+	ErrUnsupportedType = apierror.New("request.unsupported_type", "unsupported Content-Type")
+)
+
+func init() {
+	// the roster import takes CSV, which the validator only needs to see as
+	// a string
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.FileBodyDecoder)
+}
+
+// Load parses and validates a document, given as YAML or JSON.
+func Load(data []byte) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// MustLoad is Load for documents embedded in the binary, where an invalid
+// document is a bug.
+func MustLoad(data []byte) *openapi3.T {
+	doc, err := Load(data)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: %v", err))
+	}
+	return doc
+}
+
+// Merge returns a document with doc's info and the paths and components of
+// doc and others, as the gateway serves them. Components are shared by name,
+// so two documents defining the same name differently is an error.
+func Merge(doc *openapi3.T, others ...*openapi3.T) (*openapi3.T, error) {
+	merged := &openapi3.T{
+		OpenAPI:    doc.OpenAPI,
+		Info:       doc.Info,
+		Security:   doc.Security,
+		Paths:      openapi3.Paths{},
+		Components: openapi3.NewComponents(),
+	}
+	merged.Components.Schemas = openapi3.Schemas{}
+	merged.Components.Parameters = openapi3.ParametersMap{}
+	merged.Components.RequestBodies = openapi3.RequestBodies{}
+	merged.Components.Responses = openapi3.Responses{}
+	merged.Components.SecuritySchemes = openapi3.SecuritySchemes{}
+
+	for _, d := range append([]*openapi3.T{doc}, others...) {
+		for path, item := range d.Paths {
+			if _, ok := merged.Paths[path]; ok {
+				return nil, fmt.Errorf("openapi: %s is in more than one document", path)
+			}
+			merged.Paths[path] = item
+		}
+		for _, m := range []struct{ into, from interface{} }{
+			{merged.Components.Schemas, d.Components.Schemas},
+			{merged.Components.Parameters, d.Components.Parameters},
+			{merged.Components.RequestBodies, d.Components.RequestBodies},
+			{merged.Components.Responses, d.Components.Responses},
+			{merged.Components.SecuritySchemes, d.Components.SecuritySchemes},
+		} {
+			if err := mergeComponents(m.into, m.from); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return merged, nil
+}
+
+// MustMerge is Merge for documents embedded in the binary.
+func MustMerge(doc *openapi3.T, others ...*openapi3.T) *openapi3.T {
+	merged, err := Merge(doc, others...)
+	if err != nil {
+		panic(err)
+	}
+	return merged
+}
+
+func mergeComponents(into, from interface{}) error {
+	dst, src := reflect.ValueOf(into), reflect.ValueOf(from)
+	for _, name := range src.MapKeys() {
+		value := src.MapIndex(name)
+		if existing := dst.MapIndex(name); existing.IsValid() {
+			a, _ := json.Marshal(existing.Interface())
+			b, _ := json.Marshal(value.Interface())
+			if string(a) != string(b) {
+				return fmt.Errorf("openapi: component %s is defined differently in two documents", name)
+			}
+			continue
+		}
+		dst.SetMapIndex(name, value)
+	}
+	return nil
+}
+
+// Handler serves doc as JSON.
+func Handler(doc *openapi3.T) http.Handler {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: %v", err))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(body)
+	})
+}
+
+// Serve is how a service's MakeHTTPHandler finishes: it adds the document to
+// r at Path and returns r with requests validated against doc.
+func Serve(doc *openapi3.T, r *mux.Router) http.Handler {
+	r.Methods("GET").Path(Path).Handler(Handler(doc))
+	return Validate(doc, r)
+}
+
+// CheckRoutes fails t if Check finds doc and r apart. Each service's tests
+// call it with their router.
+func CheckRoutes(t testing.TB, doc *openapi3.T, r *mux.Router) {
+	t.Helper()
+	if err := Check(doc, r); err != nil {
+		t.Error(err)
+	}
+}
+
+// Check reports the routes registered on r that doc doesn't describe, or else
+// the operations in doc that r doesn't route. A route registered with
+// PathPrefix, such as the gateway's proxied services, stands for every
+// documented path under it.
+func Check(doc *openapi3.T, r *mux.Router) error {
+	var missing []string
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path := pathOf(tmpl)
+		if isPrefix(route) {
+			for p := range doc.Paths {
+				if strings.HasPrefix(p, path) {
+					return nil
+				}
+			}
+			missing = append(missing, path+"/...")
+			return nil
+		}
+		item := doc.Paths[path]
+		if item == nil {
+			missing = append(missing, path)
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if item.GetOperation(method) == nil {
+				missing = append(missing, method+" "+path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("openapi: routes missing from %q: %s", doc.Info.Title, strings.Join(missing, ", "))
+	}
+
+	var unrouted []string
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			req := httptest.NewRequest(method, samplePath.ReplaceAllString(path, "0"), nil)
+			var match mux.RouteMatch
+			if !r.Match(req, &match) || match.MatchErr != nil || !routes(match.Route, path) {
+				unrouted = append(unrouted, method+" "+path)
+			}
+		}
+	}
+	if len(unrouted) > 0 {
+		sort.Strings(unrouted)
+		return fmt.Errorf("openapi: %q documents unrouted operations: %s", doc.Info.Title, strings.Join(unrouted, ", "))
+	}
+	return nil
+}
+
+var (
+	samplePath = regexp.MustCompile(`\{[^}]+\}`)
+	muxPattern = regexp.MustCompile(`\{([^}:]+):[^}]+\}`)
+
+	unsupported = regexp.MustCompile(`^property "(.*)" is unsupported$`)
+)
+
+// pathOf turns a mux path template into the documented path, dropping the
+// patterns from variables: /players/{id:[0-9]+} is /players/{id}.
+func pathOf(tmpl string) string {
+	return muxPattern.ReplaceAllString(tmpl, "{$1}")
+}
+
+func isPrefix(route *mux.Route) bool {
+	re, err := route.GetPathRegexp()
+	return err == nil && !strings.HasSuffix(re, "$")
+}
+
+// routes reports whether route is the one serving the documented path.
+func routes(route *mux.Route, path string) bool {
+	if route == nil {
+		return false
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	if isPrefix(route) {
+		return strings.HasPrefix(path, pathOf(tmpl))
+	}
+	return pathOf(tmpl) == path
+}
+
+// Validate checks each request r routes against the matching operation in
+// doc before letting r serve it. Requests doc doesn't describe, including
+// those r will answer 404 or 405, are passed through untouched. Credentials
+// are left to the endpoints, which know the roles each route needs.
+func Validate(doc *openapi3.T, r *mux.Router) http.Handler {
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var match mux.RouteMatch
+		if !r.Match(req, &match) || match.Route == nil {
+			r.ServeHTTP(w, req)
+			return
+		}
+		tmpl, _ := match.Route.GetPathTemplate()
+		path := pathOf(tmpl)
+		item := doc.Paths[path]
+		if item == nil || item.GetOperation(req.Method) == nil {
+			r.ServeHTTP(w, req)
+			return
+		}
+
+		op := item.GetOperation(req.Method)
+		if op.RequestBody != nil && req.ContentLength != 0 {
+			content := op.RequestBody.Value.Content
+			if req.Header.Get("Content-Type") == "" && content.Get("application/json") != nil {
+				// the decoders have always read unlabelled bodies as JSON
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if err := checkContentType(req, content); err != nil {
+				// a form or plain text body is refused rather than read as
+				// JSON, so a page on another site can't make a browser send
+				// one without a CORS preflight
+				apierror.Encode(w, http.StatusUnsupportedMediaType, err)
+				return
+			}
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: match.Vars,
+			Route: &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    req.Method,
+				Operation: op,
+			},
+			Options: options,
+		}
+		if err := openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Encode(w, http.StatusRequestEntityTooLarge, limit.BodyError(err))
+				return
+			}
+			apierror.Encode(w, http.StatusBadRequest, invalid(err))
+			return
+		}
+		r.ServeHTTP(w, req)
+	})
+}
+
+func checkContentType(req *http.Request, content openapi3.Content) error {
+	if content.Get(req.Header.Get("Content-Type")) != nil {
+		return nil
+	}
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	reason := "should be " + strings.Join(types, " or ")
+	return apierror.WithDetails(
+		fmt.Errorf("%w: %s", ErrUnsupportedType, reason),
+		map[string]string{"field": "Content-Type", "reason": reason},
+	)
+}
+
+// invalid turns a validation error into ErrInvalidRequest, naming the field
+// at fault in its message and details. Fields in the body are given as a
+// path such as tickets[2].id, and "body" stands for the body as a whole.
+func invalid(err error) error {
+	var (
+		field  = "body"
+		reason = err.Error()
+	)
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		if reqErr.Parameter != nil {
+			field = reqErr.Parameter.Name
+		}
+		reason = reqErr.Reason
+		if reqErr.Err != nil {
+			reason = reqErr.Err.Error()
+		}
+	}
+
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+	switch {
+	case errors.As(err, &schemaErr):
+		if reqErr == nil || reqErr.Parameter == nil {
+			pointer := schemaErr.JSONPointer()
+			if m := unsupported.FindStringSubmatch(schemaErr.Reason); m != nil {
+				// the pointer is to the object, not the property
+				pointer = append(pointer, m[1])
+			}
+			if len(pointer) > 0 {
+				field = fieldPath(pointer)
+			}
+		}
+		reason = schemaErr.Reason
+	case errors.As(err, &parseErr):
+		reason = parseErr.Reason
+		if parseErr.Cause != nil {
+			reason = parseErr.Cause.Error()
+		}
+	}
+	if reason == "" {
+		reason = "does not match the schema"
+	}
+
+	return apierror.WithDetails(
+		fmt.Errorf("%w: %s: %s", ErrInvalidRequest, field, reason),
+		map[string]string{"field": field, "reason": reason},
+	)
+}
+
+func fieldPath(pointer []string) string {
+	var b strings.Builder
+	for _, p := range pointer {
+		if _, err := strconv.Atoi(p); err == nil {
+			b.WriteString("[" + p + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+const testDoc = `
+openapi: 3.0.3
+info:
+  title: test
+  version: "1"
+paths:
+  /tickets:
+    put:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              additionalProperties: false
+              required: [tickets]
+              properties:
+                tickets:
+                  type: array
+                  items:
+                    type: object
+                    additionalProperties: false
+                    required: [id, tickets]
+                    properties:
+                      id:
+                        type: integer
+                        minimum: 1
+                      tickets:
+                        type: integer
+      responses:
+        "200":
+          description: ok
+  /players/import:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+          text/csv:
+            schema:
+              type: string
+      responses:
+        "200":
+          description: ok
+`
+
+func testRouter() *mux.Router {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	r := mux.NewRouter()
+	r.Methods("PUT").Path("/tickets").Handler(ok)
+	r.Methods("POST").Path("/players/import").Handler(ok)
+	return r
+}
+
+func TestValidate(t *testing.T) {
+	h := Serve(MustLoad([]byte(testDoc)), testRouter())
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		field       string
+	}{
+		{"valid", "PUT", "/tickets", "application/json", `{"tickets":[{"id":1,"tickets":3}]}`, 200, ""},
+		{"no type", "PUT", "/tickets", "", `{"tickets":[]}`, 200, ""},
+		{"form type", "PUT", "/tickets", "application/x-www-form-urlencoded", `{"tickets":[]}`, 415, "Content-Type"},
+		{"plain text", "PUT", "/tickets", "text/plain", `{"tickets":[]}`, 415, "Content-Type"},
+		{"json with charset", "PUT", "/tickets", "application/json; charset=utf-8", `{"tickets":[]}`, 200, ""},
+		{"csv where only json is taken", "PUT", "/tickets", "text/csv", "id,tickets\n", 415, "Content-Type"},
+		{"unknown field", "PUT", "/tickets", "application/json", `{"tickets":[],"extra":1}`, 400, "extra"},
+		{"wrong type", "PUT", "/tickets", "application/json", `{"tickets":"many"}`, 400, "tickets"},
+		{"missing required", "PUT", "/tickets", "application/json", `{}`, 400, "tickets"},
+		{"id out of range", "PUT", "/tickets", "application/json", `{"tickets":[{"id":0,"tickets":1}]}`, 400, "tickets[0].id"},
+		{"nested path", "PUT", "/tickets", "application/json", `{"tickets":[{"id":1,"tickets":1},{"id":2,"tickets":1},{"id":"three","tickets":1}]}`, 400, "tickets[2].id"},
+		{"csv", "POST", "/players/import", "text/csv", "name\nAda\n", 200, ""},
+		{"form where json or csv is taken", "POST", "/players/import", "application/x-www-form-urlencoded", `{}`, 415, "Content-Type"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body)
+			}
+			if tc.status == http.StatusOK {
+				return
+			}
+			var e apierror.Error
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			code := "request.invalid"
+			if tc.status == http.StatusUnsupportedMediaType {
+				code = "request.unsupported_type"
+			}
+			if e.Code != code {
+				t.Errorf("code = %q, want %s", e.Code, code)
+			}
+			if e.Details["field"] != tc.field {
+				t.Errorf("field = %q, want %q (%s)", e.Details["field"], tc.field, e.Message)
+			}
+		})
+	}
+}
+
+func TestServePublishesDocument(t *testing.T) {
+	h := Serve(MustLoad([]byte(testDoc)), testRouter())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", Path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var doc struct {
+		Info struct{ Title string }
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Info.Title != "test" {
+		t.Errorf("title = %q, want test", doc.Info.Title)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	doc := MustLoad([]byte(testDoc))
+	if err := Check(doc, testRouter()); err != nil {
+		t.Errorf("Check = %v, want nil", err)
+	}
+
+	undocumented := testRouter()
+	undocumented.Methods("GET").Path("/spins/{id:[0-9]+}").Handler(http.NotFoundHandler())
+	if err := Check(doc, undocumented); err == nil || !strings.Contains(err.Error(), "/spins/{id}") {
+		t.Errorf("Check with an undocumented route = %v, want it named", err)
+	}
+
+	unrouted := mux.NewRouter()
+	unrouted.Methods("PUT").Path("/tickets").Handler(http.NotFoundHandler())
+	if err := Check(doc, unrouted); err == nil || !strings.Contains(err.Error(), "POST /players/import") {
+		t.Errorf("Check with an unrouted operation = %v, want it named", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package server runs a service's HTTP server, and its gRPC server if it has
+// one, until it is told to stop, then shuts them down without cutting off
+// requests that are still in progress.
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/jlthompson3259/matspinner/common/tracing"
+)
+
+// Hook is run during shutdown. It is given the remainder of the shutdown
+// timeout.
+type Hook func(ctx context.Context) error
+
+type Server struct {
+	HTTP            *http.Server
+	ShutdownTimeout time.Duration
+	Logger          log.Logger
+
+	// GRPC, if set, is served on GRPCAddr alongside HTTP.
+	GRPC     *grpc.Server
+	GRPCAddr string
+
+	// OnDrain hooks run as soon as shutdown begins, to stop the service
+	// taking on new work while in-flight requests finish.
+	OnDrain []Hook
+	// OnClose hooks run once the last request has finished, to flush and
+	// close storage and exporters.
+	OnClose []Hook
+}
+
+// NewGRPC returns a gRPC server for Server.GRPC that traces every call and
+// answers the standard health service, which health.ReachableGRPC asks.
+func NewGRPC() *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(tracing.UnaryServerInterceptor))
+	healthpb.RegisterHealthServer(s, grpchealth.NewServer())
+	return s
+}
+
+// Run serves HTTP and gRPC until ctx is done, then drains the service, waits
+// up to ShutdownTimeout for in-flight requests and runs the close hooks. It
+// returns the first error from serving or shutting down.
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		level.Info(s.Logger).Log("transport", "HTTP", "addr", s.HTTP.Addr)
+		errs <- s.HTTP.ListenAndServe()
+	}()
+
+	grpcErrs := make(chan error, 1)
+	if s.GRPC != nil {
+		lis, err := net.Listen("tcp", s.GRPCAddr)
+		if err != nil {
+			s.HTTP.Close()
+			return err
+		}
+		go func() {
+			level.Info(s.Logger).Log("transport", "gRPC", "addr", s.GRPCAddr)
+			grpcErrs <- s.GRPC.Serve(lis)
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		if s.GRPC != nil {
+			s.GRPC.Stop()
+		}
+		return err
+	case err := <-grpcErrs:
+		s.HTTP.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	level.Info(s.Logger).Log("msg", "shutting down", "timeout", s.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	var firstErr error
+	keep := func(err error) {
+		if err != nil {
+			level.Error(s.Logger).Log("during", "shutdown", "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for _, hook := range s.OnDrain {
+		keep(hook(ctx))
+	}
+	s.stopGRPC(ctx)
+	keep(s.HTTP.Shutdown(ctx))
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		keep(err)
+	}
+	for _, hook := range s.OnClose {
+		keep(hook(ctx))
+	}
+	return firstErr
+}
+
+// stopGRPC waits for in-flight gRPC calls to finish, cutting them off when
+// ctx is done.
+func (s *Server) stopGRPC(ctx context.Context) {
+	if s.GRPC == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.GRPC.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.GRPC.Stop()
+	}
+}
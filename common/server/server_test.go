@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// freeAddr returns a local address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// steps records what happened during a shutdown, in order.
+type steps struct {
+	mtx  sync.Mutex
+	list []string
+}
+
+func (s *steps) add(step string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.list = append(s.list, step)
+}
+
+func (s *steps) hook(step string, err error) Hook {
+	return func(context.Context) error {
+		s.add(step)
+		return err
+	}
+}
+
+func waitForServer(t *testing.T, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunShutsDownGracefully(t *testing.T) {
+	var (
+		done     steps
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		addr     = freeAddr(t)
+		errClose = errors.New("flush failed")
+	)
+	s := &Server{
+		HTTP: &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			done.add("request")
+		})},
+		ShutdownTimeout: 2 * time.Second,
+		Logger:          log.NewNopLogger(),
+		OnDrain:         []Hook{done.hook("drain 1", nil), done.hook("drain 2", nil)},
+		OnClose:         []Hook{done.hook("close 1", errClose), done.hook("close 2", nil)},
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() { ran <- s.Run(ctx) }()
+	waitForServer(t, addr)
+
+	answered := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		answered <- err
+	}()
+	<-started
+	stop()
+
+	// the drain hooks run straight away, while the request is in progress
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-ran:
+		t.Fatalf("Run returned %v before the request in progress finished", err)
+	default:
+	}
+	close(release)
+
+	if err := <-answered; err != nil {
+		t.Errorf("request in progress at shutdown: %v", err)
+	}
+	// every hook runs, and the first error is returned
+	if err := <-ran; !errors.Is(err, errClose) {
+		t.Errorf("Run = %v, want %v", err, errClose)
+	}
+	want := []string{"drain 1", "drain 2", "request", "close 1", "close 2"}
+	if !reflect.DeepEqual(done.list, want) {
+		t.Errorf("steps = %v, want %v", done.list, want)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("still listening after Run returned")
+	}
+}
+
+func TestRunGivesUpAfterTimeout(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		addr    = freeAddr(t)
+		closed  bool
+	)
+	defer close(release)
+	s := &Server{
+		HTTP: &http.Server{Addr: addr, Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		})},
+		ShutdownTimeout: 50 * time.Millisecond,
+		Logger:          log.NewNopLogger(),
+		OnClose:         []Hook{func(context.Context) error { closed = true; return nil }},
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() { ran <- s.Run(ctx) }()
+	waitForServer(t, addr)
+	go http.Get("http://" + addr)
+	<-started
+	stop()
+
+	select {
+	case err := <-ran:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run waited on a request past the shutdown timeout")
+	}
+	if !closed {
+		t.Error("close hooks didn't run after the timeout")
+	}
+}
+
+func TestRunReturnsServeError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	s := &Server{HTTP: &http.Server{Addr: lis.Addr().String()}, ShutdownTimeout: time.Second, Logger: log.NewNopLogger()}
+	if err := s.Run(context.Background()); err == nil {
+		t.Error("Run on an address in use = nil, want an error")
+	}
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// record installs a tracer provider that keeps every ended span, for the
+// length of the test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	if _, err := Setup("test", ExporterNone, nil); err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return spans
+}
+
+func find(t *testing.T, spans *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range spans.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %q among %d ended", name, len(spans.Ended()))
+	return nil
+}
+
+func TestHTTPSpans(t *testing.T) {
+	spans := record(t)
+
+	r := mux.NewRouter()
+	r.Methods("GET").Path("/spins/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := HTTPToContext()(r.Context(), r)
+		code := http.StatusOK
+		if mux.Vars(r)["id"] == "13" {
+			code = http.StatusInternalServerError
+		}
+		w.WriteHeader(code)
+		ServerFinalizer(ctx, code, r)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	call := TraceClient("spinsvc.Get")(func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+request.(string), nil)
+		ContextToHTTP(ctx, req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New(resp.Status)
+		}
+		return nil, nil
+	})
+	if _, err := call(context.Background(), "/spins/12"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server span continues the client's trace, and is named after the
+	// route rather than the path
+	client, server := find(t, spans, "spinsvc.Get"), find(t, spans, "GET /spins/{id}")
+	if server.Parent().SpanID() != client.SpanContext().SpanID() || server.SpanContext().TraceID() != client.SpanContext().TraceID() {
+		t.Errorf("server span parent = %v, want the client span %v", server.Parent(), client.SpanContext())
+	}
+	if client.Status().Code == codes.Error || server.Status().Code == codes.Error {
+		t.Errorf("spans of a successful call have status %v and %v", client.Status(), server.Status())
+	}
+
+	spans = record(t)
+	if _, err := call(context.Background(), "/spins/13"); err == nil {
+		t.Fatal("call answered 500 succeeded")
+	}
+	if client, server := find(t, spans, "spinsvc.Get"), find(t, spans, "GET /spins/{id}"); client.Status().Code != codes.Error || server.Status().Code != codes.Error {
+		t.Errorf("spans of a failed call have status %v and %v, want errors", client.Status(), server.Status())
+	}
+}
+
+func TestGRPCSpans(t *testing.T) {
+	spans := record(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/ticketsvc.Tickets/Increment"}
+
+	for _, tc := range []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.Unset},
+		// a caller's mistake isn't the server's error
+		{status.Error(grpccodes.NotFound, "no such player"), codes.Unset},
+		{status.Error(grpccodes.Internal, "disk full"), codes.Error},
+	} {
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "client")
+		md := metadata.MD{}
+		ContextToGRPC(ctx, &md)
+		parent.End()
+
+		incoming := metadata.NewIncomingContext(context.Background(), md)
+		_, err := UnaryServerInterceptor(incoming, nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, tc.err
+		})
+		if err != tc.err {
+			t.Errorf("interceptor err = %v, want the handler's %v", err, tc.err)
+		}
+		ended := spans.Ended()
+		server := ended[len(ended)-1]
+		if server.Name() != "ticketsvc.Tickets/Increment" || server.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("server span %q has parent %v, want ticketsvc.Tickets/Increment under %v", server.Name(), server.Parent(), parent.SpanContext())
+		}
+		if server.Status().Code != tc.want {
+			t.Errorf("span of a call failing with %v has status %v, want %v", tc.err, server.Status().Code, tc.want)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	if _, err := Setup("test", "jaeger", nil); !errors.Is(err, ErrUnknownExporter) {
+		t.Errorf("Setup(jaeger) err = %v, want %v", err, ErrUnknownExporter)
+	}
+
+	var out bytes.Buffer
+	flush, err := Setup("test", ExporterStdout, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tracer().Start(context.Background(), "GET /tickets")
+	span.End()
+	if err := flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"GET /tickets"`)) {
+		t.Errorf("stdout exporter wrote %q, want the span", out.String())
+	}
+}
@@ -0,0 +1,241 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/dnssrv"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/health"
+)
+
+const (
+	schemeSRV = "dns+srv://"
+	schemeDNS = "dns://"
+)
+
+var ErrMissingPort = errors.New("dns:// upstream needs a port, e.g. dns://ticketsvc:8085")
+
+// the resolver's lookups, replaced in tests
+var (
+	lookupSRV  = net.LookupSRV
+	lookupHost = net.LookupHost
+)
+
+// NewInstancer finds the instances of a service from target, which is one of
+//
+//	http://ticketsvc:8085                            a single instance
+//	http://ticketsvc-1:8085,http://ticketsvc-2:8085  a fixed list
+//	dns+srv://_http._tcp.ticketsvc                   a DNS SRV record
+//	dns://ticketsvc:8085                             the A/AAAA records of a name, on one port
+//
+// DNS records are looked up again every refresh.
+func NewInstancer(target string, refresh time.Duration, logger log.Logger) (sd.Instancer, error) {
+	switch {
+	case strings.HasPrefix(target, schemeSRV):
+		return dnssrv.NewInstancerDetailed(strings.TrimPrefix(target, schemeSRV), time.NewTicker(refresh), lookupSRV, level.Warn(logger)), nil
+
+	case strings.HasPrefix(target, schemeDNS):
+		host, port, err := net.SplitHostPort(strings.TrimPrefix(target, schemeDNS))
+		if err != nil {
+			return nil, ErrMissingPort
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, ErrMissingPort
+		}
+		// an A lookup is an SRV lookup whose records all have the same port
+		lookup := func(_, _, name string) (string, []*net.SRV, error) {
+			addrs, err := lookupHost(name)
+			if err != nil {
+				return "", nil, err
+			}
+			srvs := make([]*net.SRV, len(addrs))
+			for i, addr := range addrs {
+				srvs[i] = &net.SRV{Target: addr, Port: uint16(p)}
+			}
+			return name, srvs, nil
+		}
+		return dnssrv.NewInstancerDetailed(host, time.NewTicker(refresh), lookup, level.Warn(logger)), nil
+	}
+
+	var instances []string
+	for _, instance := range strings.Split(target, ",") {
+		if instance = strings.TrimSpace(instance); instance != "" {
+			instances = append(instances, instance)
+		}
+	}
+	return sd.FixedInstancer(instances), nil
+}
+
+// healthFilter passes on the instances of its source that pass their health
+// check, probing each every interval, so a stopped or restarting instance is taken
+// out of rotation before calls to it start failing. If none are healthy it
+// passes on all of them: calls then fail with the instances' own errors
+// rather than with no endpoints at all.
+type healthFilter struct {
+	source   sd.Instancer
+	interval time.Duration
+	check    Probe
+	logger   log.Logger
+	events   chan sd.Event
+	quit     chan struct{}
+
+	mtx       sync.Mutex
+	instances []string
+	healthy   map[string]bool
+	state     sd.Event
+	reg       map[chan<- sd.Event]struct{}
+}
+
+func newHealthFilter(source sd.Instancer, interval time.Duration, probe Probe, logger log.Logger) *healthFilter {
+	f := &healthFilter{
+		source:   source,
+		interval: interval,
+		check:    probe,
+		logger:   logger,
+		events:   make(chan sd.Event),
+		quit:     make(chan struct{}),
+		healthy:  map[string]bool{},
+		reg:      map[chan<- sd.Event]struct{}{},
+	}
+	go f.loop()
+	source.Register(f.events)
+	return f
+}
+
+func (f *healthFilter) loop() {
+	t := time.NewTicker(f.interval)
+	defer t.Stop()
+	for {
+		select {
+		case e := <-f.events:
+			if e.Err != nil {
+				f.publish(e)
+				continue
+			}
+			f.mtx.Lock()
+			f.instances = e.Instances
+			f.mtx.Unlock()
+			// new instances are in rotation until a probe says otherwise
+			f.publish(sd.Event{Instances: f.up()})
+			f.probe()
+		case <-t.C:
+			f.probe()
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// probe checks every instance and publishes the healthy ones.
+func (f *healthFilter) probe() {
+	f.mtx.Lock()
+	instances := append([]string(nil), f.instances...)
+	f.mtx.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		healthy = make(map[string]bool, len(instances))
+	)
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), health.DefaultTimeout)
+			defer cancel()
+			err := f.check(instance, instance).Check(ctx)
+			mtx.Lock()
+			healthy[instance] = err == nil
+			mtx.Unlock()
+		}(instance)
+	}
+	wg.Wait()
+
+	f.mtx.Lock()
+	for instance, up := range healthy {
+		if was, ok := f.healthy[instance]; ok && was != up {
+			level.Warn(f.logger).Log("instance", instance, "healthy", up)
+		}
+	}
+	f.healthy = healthy
+	f.mtx.Unlock()
+
+	up := f.up()
+	if len(up) == 0 {
+		up = instances
+	}
+	f.publish(sd.Event{Instances: up})
+}
+
+// up returns the instances that haven't failed a probe.
+func (f *healthFilter) up() []string {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	var up []string
+	for _, instance := range f.instances {
+		if healthy, probed := f.healthy[instance]; healthy || !probed {
+			up = append(up, instance)
+		}
+	}
+	return up
+}
+
+// publish passes e on to the subscribers, unless it is what they last got.
+// Only loop calls it, so events reach them in order; it sends without the
+// lock held, so a slow subscriber doesn't hold up up() and the health check.
+func (f *healthFilter) publish(e sd.Event) {
+	sort.Strings(e.Instances)
+	f.mtx.Lock()
+	if reflect.DeepEqual(f.state, e) {
+		f.mtx.Unlock()
+		return
+	}
+	f.state = e
+	reg := make([]chan<- sd.Event, 0, len(f.reg))
+	for ch := range f.reg {
+		reg = append(reg, ch)
+	}
+	f.mtx.Unlock()
+
+	for _, ch := range reg {
+		ch <- copyEvent(e)
+	}
+}
+
+func (f *healthFilter) Register(ch chan<- sd.Event) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.reg[ch] = struct{}{}
+	ch <- copyEvent(f.state)
+}
+
+func (f *healthFilter) Deregister(ch chan<- sd.Event) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	delete(f.reg, ch)
+}
+
+func (f *healthFilter) Stop() {
+	f.source.Deregister(f.events)
+	close(f.quit)
+	f.source.Stop()
+}
+
+// copyEvent gives each subscriber its own slice, which endpointers sort in
+// place.
+func copyEvent(e sd.Event) sd.Event {
+	e.Instances = append([]string(nil), e.Instances...)
+	return e
+}
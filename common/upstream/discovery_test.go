@@ -0,0 +1,177 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/health"
+)
+
+// instances returns what inst gives a newly registered subscriber.
+func instances(t *testing.T, inst sd.Instancer) sd.Event {
+	t.Helper()
+	ch := make(chan sd.Event, 1)
+	inst.Register(ch)
+	defer inst.Deregister(ch)
+	e := <-ch
+	sort.Strings(e.Instances)
+	return e
+}
+
+func TestNewInstancer(t *testing.T) {
+	lookupSRV = func(_, _, name string) (string, []*net.SRV, error) {
+		switch name {
+		case "_http._tcp.ticketsvc":
+			return name, []*net.SRV{{Target: "ticketsvc-2.", Port: 8085}, {Target: "ticketsvc-1.", Port: 8085}}, nil
+		case "_http._tcp.noport":
+			return name, []*net.SRV{{Target: "ticketsvc-1.", Port: 0}}, nil
+		}
+		return "", nil, errors.New("no such host")
+	}
+	lookupHost = func(name string) ([]string, error) {
+		if name == "ticketsvc" {
+			return []string{"10.0.0.2", "10.0.0.1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() { lookupSRV, lookupHost = net.LookupSRV, net.LookupHost }()
+
+	for _, tc := range []struct {
+		target string
+		want   []string
+		err    bool
+	}{
+		{"http://ticketsvc:8085", []string{"http://ticketsvc:8085"}, false},
+		{" http://ticketsvc-1:8085, http://ticketsvc-2:8085,", []string{"http://ticketsvc-1:8085", "http://ticketsvc-2:8085"}, false},
+		{"dns+srv://_http._tcp.ticketsvc", []string{"ticketsvc-1.:8085", "ticketsvc-2.:8085"}, false},
+		{"dns+srv://_http._tcp.noport", nil, true},
+		{"dns+srv://_http._tcp.missing", nil, true},
+		{"dns://ticketsvc:8085", []string{"10.0.0.1:8085", "10.0.0.2:8085"}, false},
+		{"dns://missing:8085", nil, true},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			inst, err := NewInstancer(tc.target, time.Hour, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inst.Stop()
+			e := instances(t, inst)
+			if tc.err {
+				if e.Err == nil {
+					t.Errorf("instances = %v, want an error", e.Instances)
+				}
+				return
+			}
+			if e.Err != nil || !reflect.DeepEqual(e.Instances, tc.want) {
+				t.Errorf("instances = %v, %v, want %v", e.Instances, e.Err, tc.want)
+			}
+		})
+	}
+
+	for _, target := range []string{"dns://ticketsvc", "dns://ticketsvc:http"} {
+		if _, err := NewInstancer(target, time.Hour, log.NewNopLogger()); !errors.Is(err, ErrMissingPort) {
+			t.Errorf("NewInstancer(%q) err = %v, want %v", target, err, ErrMissingPort)
+		}
+	}
+}
+
+// probes is a Probe whose answer for each instance the test sets.
+type probes struct {
+	mtx  sync.Mutex
+	down map[string]bool
+}
+
+func (p *probes) set(instance string, down bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.down[instance] = down
+}
+
+func (p *probes) probe(name, instance string) health.Check {
+	return health.Check{Name: name, Check: func(context.Context) error {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		if p.down[instance] {
+			return errors.New("down")
+		}
+		return nil
+	}}
+}
+
+// waitForInstances waits for f to publish want.
+func waitForInstances(t *testing.T, ch <-chan sd.Event, want ...string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	var got []string
+	for {
+		select {
+		case e := <-ch:
+			if got = e.Instances; reflect.DeepEqual(got, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("instances = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestHealthFilter(t *testing.T) {
+	p := &probes{down: map[string]bool{}}
+	f := newHealthFilter(sd.FixedInstancer{"a", "b"}, 10*time.Millisecond, p.probe, log.NewNopLogger())
+	defer f.Stop()
+	ch := make(chan sd.Event, 16)
+	f.Register(ch)
+
+	waitForInstances(t, ch, "a", "b")
+
+	// an instance failing its probe is taken out of rotation, and put back
+	// once it passes again
+	p.set("b", true)
+	waitForInstances(t, ch, "a")
+	p.set("b", false)
+	waitForInstances(t, ch, "a", "b")
+
+	// with none healthy, all are passed on, so calls fail with the
+	// instances' own errors
+	p.set("a", true)
+	waitForInstances(t, ch, "b")
+	p.set("b", true)
+	waitForInstances(t, ch, "a", "b")
+	if up := f.up(); len(up) != 0 {
+		t.Errorf("up = %v, want none", up)
+	}
+}
+
+func TestHealthFilterDoesntWaitForSubscribers(t *testing.T) {
+	p := &probes{down: map[string]bool{}}
+	f := newHealthFilter(sd.FixedInstancer{"a", "b"}, 10*time.Millisecond, p.probe, log.NewNopLogger())
+	defer f.Stop()
+
+	// a subscriber that stops reading, here after the instances it is given
+	// on registering, mustn't hold up the ones the readiness check asks for
+	stuck := make(chan sd.Event, 1)
+	f.Register(stuck)
+	p.set("b", true)
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		f.up()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("up blocked on a subscriber")
+	}
+	<-stuck
+}
@@ -0,0 +1,310 @@
+// Package upstream guards calls from one service to another. Calls are
+// spread over the instances of the service being called, found from a fixed
+// list or DNS, with instances that fail their health check taken out of
+// rotation. Each attempt gets a time limit, and failed attempts are retried
+// on another instance when that is safe. A circuit breaker per instance
+// fails calls fast while the instance is down instead of letting every
+// request wait out the timeout, and calls are rate limited so a burst of
+// requests to one service can't flood the next.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/ratelimit"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/sony/gobreaker"
+	"golang.org/x/time/rate"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/health"
+)
+
+var (
+	ErrInvalidTimeout     = errors.New("client timeout must be positive")
+	ErrInvalidRetries     = errors.New("client retries can't be negative")
+	ErrInvalidBreaker     = errors.New("client breaker failures and cooldown must be positive")
+	ErrInvalidRate        = errors.New("client rate limit can't be negative")
+	ErrInvalidInterval    = errors.New("client DNS refresh and health interval must be positive")
+	ErrBreakerOpen        = errors.New("circuit breaker is open for every instance")
+	ErrNoHealthyInstances = errors.New("no healthy instances")
+
+	// ErrUnavailable and ErrBadGateway are what a call fails with when the
+	// service being called didn't answer. The reason, which names the
+	// instance's address, is logged rather than passed to the caller.
+	ErrUnavailable = apierror.New("upstream.unavailable", "a service this request needs is unavailable, try again shortly")
+	ErrBadGateway  = apierror.New("upstream.failed", "a service this request needs failed to answer")
+)
+
+// Settings tune the calls a service makes to the others. Include them in a
+// binary's settings as `client`.
+type Settings struct {
+	Timeout         time.Duration `yaml:"timeout" env:"CLIENT_TIMEOUT" flag:"client-timeout" help:"time limit for each attempt at a call to another service"`
+	Retries         int           `yaml:"retries" env:"CLIENT_RETRIES" flag:"client-retries" help:"extra attempts for failed calls that are safe to repeat"`
+	BreakerFailures int           `yaml:"breakerFailures" env:"CLIENT_BREAKER_FAILURES" flag:"client-breaker-failures" help:"consecutive failures that open an instance's circuit breaker"`
+	BreakerCooldown time.Duration `yaml:"breakerCooldown" env:"CLIENT_BREAKER_COOLDOWN" flag:"client-breaker-cooldown" help:"how long an open breaker fails calls before letting one through"`
+	RateLimit       float64       `yaml:"rateLimit" env:"CLIENT_RATE_LIMIT" flag:"client-rate-limit" help:"calls per second to each service, 0 for no limit"`
+	DNSRefresh      time.Duration `yaml:"dnsRefresh" env:"CLIENT_DNS_REFRESH" flag:"client-dns-refresh" help:"how often to look up dns:// and dns+srv:// upstreams again"`
+	HealthInterval  time.Duration `yaml:"healthInterval" env:"CLIENT_HEALTH_INTERVAL" flag:"client-health-interval" help:"how often to check each upstream instance's /healthz"`
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		Timeout:         5 * time.Second,
+		Retries:         2,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+		RateLimit:       100,
+		DNSRefresh:      30 * time.Second,
+		HealthInterval:  5 * time.Second,
+	}
+}
+
+func (s *Settings) Validate() error {
+	switch {
+	case s.Timeout <= 0:
+		return ErrInvalidTimeout
+	case s.Retries < 0:
+		return ErrInvalidRetries
+	case s.BreakerFailures <= 0 || s.BreakerCooldown <= 0:
+		return ErrInvalidBreaker
+	case s.RateLimit < 0:
+		return ErrInvalidRate
+	case s.DNSRefresh <= 0 || s.HealthInterval <= 0:
+		return ErrInvalidInterval
+	}
+	return nil
+}
+
+// Upstream is one service being called. Its instances, breakers and rate
+// limit are shared by every endpoint of that service.
+type Upstream struct {
+	name      string
+	settings  Settings
+	instancer *healthFilter
+	state     metrics.Gauge
+	limiter   endpoint.Middleware
+	probe     Probe
+	logger    log.Logger
+
+	mtx      sync.Mutex
+	breakers map[string]*gobreaker.CircuitBreaker
+}
+
+// Probe makes the health check for one instance of a service.
+type Probe func(name, instance string) health.Check
+
+// Option changes how New sets up an upstream.
+type Option func(*Upstream)
+
+// WithProbe checks instances with probe instead of health.Reachable, e.g.
+// health.ReachableGRPC for instances that serve gRPC.
+func WithProbe(probe Probe) Option {
+	return func(u *Upstream) { u.probe = probe }
+}
+
+// New returns the guard for calls to the service called name, at target (see
+// NewInstancer). Each instance's breaker state is set on state, labelled by
+// upstream and instance: 0 closed, 1 half-open and 2 open. state may be nil.
+func New(name, target string, s Settings, state metrics.Gauge, logger log.Logger, options ...Option) (*Upstream, error) {
+	logger = log.With(logger, "upstream", name)
+	instancer, err := NewInstancer(target, s.DNSRefresh, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Upstream{
+		name:     name,
+		settings: s,
+		state:    state,
+		probe:    health.Reachable,
+		logger:   logger,
+		breakers: map[string]*gobreaker.CircuitBreaker{},
+	}
+	for _, option := range options {
+		option(u)
+	}
+	u.instancer = newHealthFilter(instancer, s.HealthInterval, u.probe, logger)
+	u.limiter = func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	if s.RateLimit > 0 {
+		burst := int(math.Ceil(s.RateLimit))
+		u.limiter = ratelimit.NewDelayingLimiter(rate.NewLimiter(rate.Limit(s.RateLimit), burst))
+	}
+	return u, nil
+}
+
+// Balance returns an endpoint that spreads calls over the upstream's
+// instances, making each instance's endpoint with factory. Calls that are
+// idempotent are retried on the next instance after any failure except a
+// 4xx answer; others only when the failed attempt can't have reached the
+// instance, since repeating a ticket increment whose response was lost
+// would hand out the tickets twice.
+func (u *Upstream) Balance(factory sd.Factory, idempotent bool) endpoint.Endpoint {
+	endpointer := sd.NewEndpointer(u.instancer, u.guard(factory), level.Warn(u.logger))
+	balancer := lb.NewRoundRobin(endpointer)
+	total := time.Duration(u.settings.Retries+1) * u.settings.Timeout
+	e := lb.RetryWithCallback(total, balancer, func(n int, err error) (bool, error) {
+		if n > u.settings.Retries || isClientError(err) {
+			return false, nil
+		}
+		return idempotent || notSent(err), nil
+	})
+	return u.limiter(func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := e(ctx, request)
+		var retryErr lb.RetryError
+		if errors.As(err, &retryErr) {
+			// callers want the upstream's error, for errors.Is, rather than
+			// a later attempt's open breaker
+			err = retryErr.Final
+			for _, raw := range retryErr.RawErrors {
+				if !errors.Is(raw, gobreaker.ErrOpenState) && !errors.Is(raw, gobreaker.ErrTooManyRequests) {
+					err = raw
+				}
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			err = u.sanitize(err)
+		}
+		return response, err
+	})
+}
+
+// sanitize passes on errors the service answered with, and replaces the
+// rest, a refused connection or a timeout say, with ErrUnavailable when no
+// instance could be tried and ErrBadGateway otherwise.
+func (u *Upstream) sanitize(err error) error {
+	var answer *apierror.Error
+	if errors.As(err, &answer) && !transportStatus(answer) {
+		return err
+	}
+	level.Warn(u.logger).Log("msg", "call failed", "err", err)
+	details := map[string]string{"upstream": u.name}
+	if notTried(err) {
+		return apierror.WithDetails(ErrUnavailable, details)
+	}
+	return apierror.WithDetails(ErrBadGateway, details)
+}
+
+// guard gives every instance's endpoint a time limit and the instance's
+// breaker.
+func (u *Upstream) guard(factory sd.Factory) sd.Factory {
+	return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+		e, closer, err := factory(instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		e = timeout(u.settings.Timeout)(e)
+		e = circuitbreaker.Gobreaker(u.breaker(instance))(e)
+		return e, closer, nil
+	}
+}
+
+func (u *Upstream) breaker(instance string) *gobreaker.CircuitBreaker {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if cb, ok := u.breakers[instance]; ok {
+		return cb
+	}
+
+	setState := func(s gobreaker.State) {
+		if u.state != nil {
+			u.state.With("upstream", u.name, "instance", instance).Set(float64(s))
+		}
+	}
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    instance,
+		Timeout: u.settings.BreakerCooldown,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(u.settings.BreakerFailures)
+		},
+		IsSuccessful: func(err error) bool {
+			// the service answering that a player doesn't exist is the
+			// service working
+			return err == nil || isClientError(err)
+		},
+		OnStateChange: func(_ string, _, to gobreaker.State) {
+			level.Warn(u.logger).Log("instance", instance, "breaker", to)
+			setState(to)
+		},
+	})
+	setState(gobreaker.StateClosed)
+	u.breakers[instance] = cb
+	return cb
+}
+
+// Check reports the upstream as down when none of its instances passed
+// their last health check, or every instance's breaker is open.
+func (u *Upstream) Check() health.Check {
+	return health.Check{Name: u.name, Check: func(context.Context) error {
+		up := u.instancer.up()
+		if len(up) == 0 {
+			return ErrNoHealthyInstances
+		}
+		u.mtx.Lock()
+		defer u.mtx.Unlock()
+		for _, instance := range up {
+			if cb, ok := u.breakers[instance]; !ok || cb.State() != gobreaker.StateOpen {
+				return nil
+			}
+		}
+		return ErrBreakerOpen
+	}}
+}
+
+// Close stops looking for instances. It is a server.Hook.
+func (u *Upstream) Close(context.Context) error {
+	u.instancer.Stop()
+	return nil
+}
+
+func timeout(d time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
+// isClientError reports whether the upstream rejected the request, which
+// another attempt won't change.
+func isClientError(err error) bool {
+	status := apierror.StatusCode(err, http.StatusInternalServerError)
+	return status >= 400 && status < 500
+}
+
+// transportStatus reports whether a gRPC status came from the client's own
+// transport, which has no envelope, rather than from the service.
+func transportStatus(e *apierror.Error) bool {
+	return e.Code == apierror.CodeInternal && (e.Status == http.StatusServiceUnavailable || e.Status == http.StatusGatewayTimeout)
+}
+
+// notTried reports whether no instance could be called at all: there were
+// none, or every breaker was open.
+func notTried(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) || errors.Is(err, lb.ErrNoEndpoints)
+}
+
+// notSent reports whether a failed attempt never reached the instance: its
+// breaker was open, or the connection was refused.
+func notSent(err error) bool {
+	if notTried(err) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,224 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+	"github.com/sony/gobreaker"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/health"
+)
+
+var errNotFound = apierror.New("test.not_found", "not found")
+
+// instance is an httptest server standing in for one instance of a service,
+// counting the calls that reach it.
+type instance struct {
+	*httptest.Server
+	calls  int32
+	answer atomic.Value // func(http.ResponseWriter)
+}
+
+func newInstance(t *testing.T, answer func(http.ResponseWriter)) *instance {
+	t.Helper()
+	i := &instance{}
+	i.answer.Store(answer)
+	i.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&i.calls, 1)
+		i.answer.Load().(func(http.ResponseWriter))(w)
+	}))
+	t.Cleanup(i.Close)
+	return i
+}
+
+func (i *instance) called() int { return int(atomic.LoadInt32(&i.calls)) }
+
+func ok(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) }
+
+func notFound(w http.ResponseWriter) { apierror.Encode(w, http.StatusNotFound, errNotFound) }
+
+// hangUp reads the request and drops the connection without answering, as
+// an instance that dies mid-call would.
+func hangUp(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// deadURL is the address of an instance that refuses connections.
+func deadURL(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func factory(instance string) (endpoint.Endpoint, io.Closer, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := httptransport.NewClient("POST", u,
+		func(context.Context, *http.Request, interface{}) error { return nil },
+		func(_ context.Context, resp *http.Response) (interface{}, error) {
+			return nil, apierror.Decode(resp)
+		},
+	).Endpoint()
+	return e, nil, nil
+}
+
+func healthy(name, _ string) health.Check {
+	return health.Check{Name: name, Check: func(context.Context) error { return nil }}
+}
+
+// newUpstream returns an upstream over instances whose probes always pass,
+// so that every instance stays in rotation and only breakers take them out.
+func newUpstream(t *testing.T, s Settings, instances ...string) *Upstream {
+	t.Helper()
+	u, err := New("test", strings.Join(instances, ","), s, nil, log.NewNopLogger(), WithProbe(healthy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { u.Close(context.Background()) })
+	// wait for the health filter to have the instances to hand to
+	// balancers
+	deadline := time.Now().Add(time.Second)
+	for {
+		u.instancer.mtx.Lock()
+		n := len(u.instancer.state.Instances)
+		u.instancer.mtx.Unlock()
+		if n == len(instances) || time.Now().After(deadline) {
+			return u
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testSettings() Settings {
+	s := DefaultSettings()
+	s.Timeout = time.Second
+	s.RateLimit = 0
+	return s
+}
+
+func TestBalanceRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		answer     func(http.ResponseWriter)
+		idempotent bool
+		calls      int
+		err        error
+	}{
+		{"idempotent after a dropped call", hangUp, true, 3, ErrBadGateway},
+		{"not idempotent after a dropped call", hangUp, false, 1, ErrBadGateway},
+		{"idempotent after a 4xx", notFound, true, 1, errNotFound},
+		{"not idempotent after a 4xx", notFound, false, 1, errNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inst := newInstance(t, tc.answer)
+			u := newUpstream(t, testSettings(), inst.URL)
+			_, err := u.Balance(factory, tc.idempotent)(context.Background(), nil)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("err = %v, want %v", err, tc.err)
+			}
+			if got := inst.called(); got != tc.calls {
+				t.Errorf("instance called %d times, want %d", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestBalanceRetriesUnsentCalls(t *testing.T) {
+	// a refused connection never reached the instance, so even a call
+	// that isn't safe to repeat goes on to the next one
+	inst := newInstance(t, ok)
+	u := newUpstream(t, testSettings(), deadURL(t), inst.URL)
+	e := u.Balance(factory, false)
+	for i := 0; i < 4; i++ {
+		if _, err := e(context.Background(), nil); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if got := inst.called(); got != 4 {
+		t.Errorf("live instance called %d times, want every call", got)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	s := testSettings()
+	s.Retries = 0
+	s.BreakerFailures = 2
+	s.BreakerCooldown = 100 * time.Millisecond
+	inst := newInstance(t, hangUp)
+	u := newUpstream(t, s, inst.URL)
+	e := u.Balance(factory, true)
+	ctx := context.Background()
+	state := func() gobreaker.State { return u.breaker(inst.URL).State() }
+
+	// calls that reach the instance and fail are a bad gateway, until
+	// enough of them open the breaker
+	for i := 0; i < s.BreakerFailures; i++ {
+		if _, err := e(ctx, nil); !errors.Is(err, ErrBadGateway) {
+			t.Fatalf("call %d err = %v, want %v", i, err, ErrBadGateway)
+		}
+	}
+	if got := state(); got != gobreaker.StateOpen {
+		t.Fatalf("breaker %v, want open", got)
+	}
+
+	// then calls fail fast as unavailable without reaching the instance
+	called := inst.called()
+	if _, err := e(ctx, nil); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("call with the breaker open err = %v, want %v", err, ErrUnavailable)
+	}
+	if inst.called() != called {
+		t.Error("a call reached the instance through an open breaker")
+	}
+	if err := u.Check().Check(ctx); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Check err = %v, want %v", err, ErrBreakerOpen)
+	}
+
+	// after the cooldown one call is let through, and its success closes
+	// the breaker
+	time.Sleep(s.BreakerCooldown)
+	if got := state(); got != gobreaker.StateHalfOpen {
+		t.Fatalf("breaker after cooldown %v, want half-open", got)
+	}
+	inst.answer.Store(ok)
+	if _, err := e(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := state(); got != gobreaker.StateClosed {
+		t.Errorf("breaker after a success %v, want closed", got)
+	}
+	if err := u.Check().Check(ctx); err != nil {
+		t.Errorf("Check err = %v, want nil", err)
+	}
+}
+
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	s := testSettings()
+	s.BreakerFailures = 2
+	inst := newInstance(t, notFound)
+	u := newUpstream(t, s, inst.URL)
+	e := u.Balance(factory, true)
+	for i := 0; i < 2*s.BreakerFailures; i++ {
+		e(context.Background(), nil)
+	}
+	if got := u.breaker(inst.URL).State(); got != gobreaker.StateClosed {
+		t.Errorf("breaker after 4xx answers %v, want closed", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+)
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	Sessions       config.Sessions   `yaml:"sessions"`
+	Upstreams      upstreams         `yaml:"upstreams"`
+	Client         upstream.Settings `yaml:"client"`
+}
+
+type upstreams struct {
+	TicketSvc string `yaml:"ticketsvc" env:"TICKETSVC_URL" flag:"ticketsvc-url" help:"ticketsvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+	SpinSvc   string `yaml:"spinsvc" env:"SPINSVC_URL" flag:"spinsvc-url" help:"spinsvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+	PlayerSvc string `yaml:"playersvc" env:"PLAYERSVC_URL" flag:"playersvc-url" help:"playersvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+	UserSvc   string `yaml:"usersvc" env:"USERSVC_URL" flag:"usersvc-url" help:"usersvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+}
+
+func (u *upstreams) Validate() error {
+	if u.TicketSvc == "" || u.SpinSvc == "" || u.PlayerSvc == "" || u.UserSvc == "" {
+		return config.ErrMissingUpstream
+	}
+	return nil
+}
+
+func (s *settings) Validate() error {
+	return s.Sessions.Required(s.Auth)
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:  config.DefaultService(":8080"),
+		Sessions: config.DefaultSessions(),
+		Upstreams: upstreams{
+			TicketSvc: "http://ticketsvc:8085",
+			SpinSvc:   "http://spinsvc:8086",
+			PlayerSvc: "http://playersvc:8087",
+			UserSvc:   "http://usersvc:8088",
+		},
+		Client: upstream.DefaultSettings(),
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+	"github.com/jlthompson3259/matspinner/gatewaysvc"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+func main() {
+	cfg := defaultSettings()
+	if err := config.Load("gatewaysvc", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("gatewaysvc", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	sessions, err := usersvc.MakeClientEndpoints(cfg.Sessions.URL, cfg.Auth.Forward())
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, stopSessions := cfg.Sessions.Watch(cfg.Auth, authenticator, sessions.Ended, logger)
+	bus, err := cfg.Events.NewBus("gatewaysvc", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	forward := cfg.Auth.Forward()
+	breakerState := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "matspinner",
+		Subsystem: "gatewaysvc",
+		Name:      "upstream_breaker_state",
+		Help:      "Circuit breaker state per upstream instance: 0 closed, 1 half-open, 2 open.",
+	}, []string{"upstream", "instance"})
+	var tickets, spins, players, users *upstream.Upstream
+	{
+		if tickets, err = upstream.New("ticketsvc", cfg.Upstreams.TicketSvc, cfg.Client, breakerState, logger); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		if spins, err = upstream.New("spinsvc", cfg.Upstreams.SpinSvc, cfg.Client, breakerState, logger); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		if players, err = upstream.New("playersvc", cfg.Upstreams.PlayerSvc, cfg.Client, breakerState, logger); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		if users, err = upstream.New("usersvc", cfg.Upstreams.UserSvc, cfg.Client, breakerState, logger); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+	}
+
+	upstreams := gatewaysvc.Upstreams{
+		Tickets: ticketsvc.MakeBalancedClientEndpoints(tickets, forward),
+		Spins:   spinsvc.MakeBalancedClientEndpoints(spins, forward),
+		Players: playersvc.MakeBalancedClientEndpoints(players, forward),
+		Users:   usersvc.MakeBalancedClientEndpoints(users, forward),
+	}
+
+	var service gatewaysvc.Service
+	{
+		service = gatewaysvc.NewService(log.With(logger, "component", "service"), &upstreams.Players, &upstreams.Tickets, &upstreams.Spins)
+		service = gatewaysvc.LoggingMiddleware(log.With(logger, "component", "loggingMiddleware"))(service)
+	}
+
+	var (
+		endpoints   = gatewaysvc.MakeServerEndpoints(service)
+		httpHandler = gatewaysvc.MakeHTTPHandler(endpoints, upstreams, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
+	)
+
+	readiness := []health.Check{tickets.Check(), spins.Check(), players.Check(), users.Check()}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/", httpHandler)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnClose:         []server.Hook{stopSessions, bus.Close, tickets.Close, spins.Close, players.Close, users.Close, shutdownTracing},
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("exit", "stopped")
+}
@@ -0,0 +1,106 @@
+package gatewaysvc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/playersvc"
+)
+
+type EndpointSet struct {
+	PlayersWithTicketsEndpoint endpoint.Endpoint
+	SpinWithWinnerEndpoint     endpoint.Endpoint
+}
+
+func MakeServerEndpoints(svc Service) EndpointSet {
+	return EndpointSet{
+		PlayersWithTicketsEndpoint: MakePlayersWithTicketsEndpoint(svc),
+		SpinWithWinnerEndpoint:     MakeSpinWithWinnerEndpoint(svc),
+	}
+}
+
+func MakeClientEndpoints(instance string, options ...httptransport.ClientOption) (EndpointSet, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return EndpointSet{}, err
+	}
+
+	tgt.Path = ""
+	options = append([]httptransport.ClientOption{httptransport.ClientBefore(tracing.ContextToHTTP)}, options...)
+
+	return EndpointSet{
+		PlayersWithTicketsEndpoint: tracing.TraceClient("gatewaysvc.PlayersWithTickets")(httptransport.NewClient("GET", tgt, encodePlayersWithTicketsRequest, decodePlayersWithTicketsResponse, options...).Endpoint()),
+		SpinWithWinnerEndpoint:     tracing.TraceClient("gatewaysvc.SpinWithWinner")(httptransport.NewClient("POST", tgt, encodeSpinWithWinnerRequest, decodeSpinWithWinnerResponse, options...).Endpoint()),
+	}, nil
+}
+
+func (e *EndpointSet) PlayersWithTickets(ctx context.Context, opts playersvc.ListOptions) ([]PlayerWithTickets, error) {
+	request := playersWithTicketsRequest{ListOptions: opts}
+	r, err := e.PlayersWithTicketsEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(playersWithTicketsResponse)
+	return resp.Players, nil
+}
+
+func (e *EndpointSet) SpinWithWinner(ctx context.Context, participantIds []int, unweighted bool) (SpinWithWinner, error) {
+	if participantIds == nil {
+		// sent as [] so the server answers spinsvc.ErrNoParticipants
+		participantIds = []int{}
+	}
+	request := spinWithWinnerRequest{ParticipantIds: participantIds, Unweighted: unweighted}
+	r, err := e.SpinWithWinnerEndpoint(ctx, request)
+	if err != nil {
+		return SpinWithWinner{}, err
+	}
+	resp := r.(spinWithWinnerResponse)
+	return resp.SpinWithWinner, nil
+}
+
+func MakePlayersWithTicketsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(playersWithTicketsRequest)
+		players, err := svc.PlayersWithTickets(ctx, req.ListOptions)
+		return playersWithTicketsResponse{players, err}, nil
+	}
+}
+
+func MakeSpinWithWinnerEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(spinWithWinnerRequest)
+		result, err := svc.SpinWithWinner(ctx, req.ParticipantIds, req.Unweighted)
+		return spinWithWinnerResponse{result, err}, nil
+	}
+}
+
+type playersWithTicketsRequest struct {
+	playersvc.ListOptions
+}
+
+type spinWithWinnerRequest struct {
+	ParticipantIds []int `json:"participantIds"`
+	Unweighted     bool  `json:"unweighted"`
+}
+
+type playersWithTicketsResponse struct {
+	Players []PlayerWithTickets `json:"players"`
+	Err     error               `json:"-"`
+}
+
+func (r playersWithTicketsResponse) error() error { return r.Err }
+
+type spinWithWinnerResponse struct {
+	SpinWithWinner
+	Err error `json:"-"`
+}
+
+func (r spinWithWinnerResponse) error() error { return r.Err }
@@ -0,0 +1,13 @@
+module github.com/jlthompson3259/matspinner/gatewaysvc
+
+go 1.19
+
+require (
+	github.com/getkin/kin-openapi v0.112.0
+	github.com/go-kit/kit v0.12.0
+	github.com/go-kit/log v0.2.1
+	github.com/gorilla/mux v1.8.0
+	github.com/prometheus/client_golang v1.11.1
+)
+
+require github.com/go-logfmt/logfmt v0.5.1 // indirect
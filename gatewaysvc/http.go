@@ -0,0 +1,216 @@
+package gatewaysvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+// Upstreams are the client endpoints of the services the gateway fronts.
+type Upstreams struct {
+	Players playersvc.EndpointSet
+	Tickets ticketsvc.EndpointSet
+	Spins   spinsvc.EndpointSet
+	Users   usersvc.EndpointSet
+}
+
+// MakeHTTPHandler serves the composite endpoints and, under their usual
+// paths, every route of playersvc, ticketsvc and spinsvc. The proxied routes
+// are the services' own HTTP handlers wired to client endpoints, so requests
+// are decoded and re-encoded exactly as the services expect.
+// The usersvc routes are left out if u.Users has no endpoints, for
+// deployments without staff accounts. /openapi.json describes every route
+// served. crossOrigin covers the proxied routes too; the services' handlers
+// are built without one so that headers aren't added twice.
+func MakeHTTPHandler(e EndpointSet, u Upstreams, authn auth.Authenticator, crossOrigin cors.Policy, limits limit.Settings, logger log.Logger) http.Handler {
+	r := makeRouter(e, u, authn, logger)
+	doc := served
+	if u.Users.LoginEndpoint != nil {
+		doc = servedWithUsers
+	}
+	r.Methods("GET").Path(openapi.Path).Handler(openapi.Handler(doc))
+	// the proxied routes are validated by the services' own handlers
+	return cors.Handler(crossOrigin, limit.Handler(limits, authn, openapi.Validate(spec, r)))
+}
+
+// makeRouter routes the composite endpoints and the proxied services. Every
+// route must be described by the document MakeHTTPHandler serves, which the
+// tests check.
+func makeRouter(e EndpointSet, u Upstreams, authn auth.Authenticator, logger log.Logger) *mux.Router {
+	r := mux.NewRouter()
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.HTTPToContext(), auth.HTTPToContext(authn)),
+		httptransport.ServerFinalizer(tracing.ServerFinalizer),
+	}
+
+	r.Methods("GET").Path("/players/with-tickets").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.PlayersWithTicketsEndpoint),
+		decodePlayersWithTicketsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/spin/winner").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.SpinWithWinnerEndpoint),
+		decodeSpinWithWinnerRequest,
+		encodeResponse,
+		options...,
+	))
+
+	var (
+		players = playersvc.MakeHTTPHandler(u.Players, authn, cors.Policy{}, limit.Settings{}, log.With(logger, "upstream", "playersvc"))
+		tickets = ticketsvc.MakeHTTPHandler(u.Tickets, authn, cors.Policy{}, limit.Settings{}, log.With(logger, "upstream", "ticketsvc"))
+		spins   = spinsvc.MakeHTTPHandler(u.Spins, authn, cors.Policy{}, limit.Settings{}, log.With(logger, "upstream", "spinsvc"))
+	)
+	r.PathPrefix("/players").Handler(players)
+	r.PathPrefix("/tickets").Handler(tickets)
+	r.Path("/spins").Handler(spins)
+	r.PathPrefix("/spins/").Handler(spins)
+	r.Path("/spin").Handler(spins)
+	r.Path("/get-last-spin").Handler(spins)
+
+	if u.Users.LoginEndpoint != nil {
+		users := usersvc.MakeHTTPHandler(u.Users, authn, cors.Policy{}, limit.Settings{}, log.With(logger, "upstream", "usersvc"))
+		r.Path("/login").Handler(users)
+		r.Path("/logout").Handler(users)
+		r.Path("/me").Handler(users)
+		r.PathPrefix("/users").Handler(users)
+		r.PathPrefix("/sessions").Handler(users)
+	}
+	return r
+}
+
+/** server decode/encode **/
+func decodePlayersWithTicketsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	opts, err := playersvc.ListOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return playersWithTicketsRequest{ListOptions: opts}, nil
+}
+
+func decodeSpinWithWinnerRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req spinWithWinnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	return req, nil
+}
+
+// errorer is implemented by all concrete response types that may contain
+// errors. It allows us to change the HTTP response code without needing to
+// trigger an endpoint (transport-level) error.
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		// Not a Go kit transport error, but a business-logic error.
+		// Provide those as HTTP errors.
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	if err == nil {
+		panic("encodeError with nil error")
+	}
+	apierror.Encode(w, codeFrom(err), err)
+}
+
+func codeFrom(err error) int {
+	switch apierror.Sentinel(err) {
+	case auth.ErrUnauthenticated, auth.ErrInvalidCredentials:
+		return http.StatusUnauthorized
+	case auth.ErrForbidden, auth.ErrWrongStore:
+		return http.StatusForbidden
+	case playersvc.ErrParsingList, playersvc.ErrUnknownSortField, playersvc.ErrInvalidPaging, spinsvc.ErrNoParticipants:
+		return http.StatusBadRequest
+	case spinsvc.ErrNoTickets:
+		return http.StatusUnprocessableEntity
+	case auth.ErrSessionsUnknown, upstream.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case upstream.ErrBadGateway:
+		return http.StatusBadGateway
+	case limit.ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return apierror.StatusCode(err, http.StatusInternalServerError)
+	}
+}
+
+/** client encode/decode **/
+func decodePlayersWithTicketsResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response playersWithTicketsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeSpinWithWinnerResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response spinWithWinnerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func encodePlayersWithTicketsRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(playersWithTicketsRequest)
+	req.URL.Path = "/players/with-tickets"
+	req.URL.RawQuery = r.ListOptions.Query().Encode()
+	return nil
+}
+
+func encodeSpinWithWinnerRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/spin/winner"
+	return encodeRequest(ctx, req, request)
+}
+
+// encodeRequest likewise JSON-encodes the request to the HTTP request body.
+// Don't use it directly as a transport/http.Client EncodeRequestFunc:
+// gateway endpoints require mutating the HTTP method and request path.
+func encodeRequest(_ context.Context, req *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(request)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Body = io.NopCloser(&buf)
+	return nil
+}
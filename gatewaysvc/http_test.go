@@ -0,0 +1,34 @@
+package gatewaysvc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	t.Run("without usersvc", func(t *testing.T) {
+		openapi.CheckRoutes(t, served, makeRouter(EndpointSet{}, Upstreams{}, nil, log.NewNopLogger()))
+	})
+	t.Run("with usersvc", func(t *testing.T) {
+		users := Upstreams{Users: usersvc.EndpointSet{LoginEndpoint: endpoint.Nop}}
+		openapi.CheckRoutes(t, servedWithUsers, makeRouter(EndpointSet{}, users, nil, log.NewNopLogger()))
+	})
+}
+
+func TestSpinRoutesAreExact(t *testing.T) {
+	r := makeRouter(EndpointSet{}, Upstreams{}, nil, log.NewNopLogger())
+	for _, path := range []string{"/spinanything", "/spinsx", "/spin/other"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("POST %s = %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package gatewaysvc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/playersvc"
+)
+
+type ServiceMiddleware func(Service) Service
+
+type loggingMiddleware struct {
+	next   Service
+	logger log.Logger
+}
+
+func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
+	return func(service Service) Service {
+		return &loggingMiddleware{
+			next:   service,
+			logger: logger,
+		}
+	}
+}
+
+func (mw *loggingMiddleware) PlayersWithTickets(ctx context.Context, opts playersvc.ListOptions) (p []PlayerWithTickets, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "PlayersWithTickets", "opts", fmt.Sprintf("%+v", opts), "players", len(p), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.PlayersWithTickets(ctx, opts)
+}
+
+func (mw *loggingMiddleware) SpinWithWinner(ctx context.Context, participantIds []int, unweighted bool) (res SpinWithWinner, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "SpinWithWinner", "unweighted", unweighted, "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.SpinWithWinner(ctx, participantIds, unweighted)
+}
@@ -0,0 +1,30 @@
+package gatewaysvc
+
+import (
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+//go:embed openapi.yaml
+var openapiYAML []byte
+
+var (
+	spec = openapi.MustLoad(openapiYAML)
+
+	// served is what MakeHTTPHandler publishes: every route the gateway
+	// serves, with usersvc's routes or without them.
+	served          = openapi.MustMerge(spec, playersvc.OpenAPI(), ticketsvc.OpenAPI(), spinsvc.OpenAPI())
+	servedWithUsers = openapi.MustMerge(spec, playersvc.OpenAPI(), ticketsvc.OpenAPI(), spinsvc.OpenAPI(), usersvc.OpenAPI())
+)
+
+// OpenAPI returns the document describing the gateway's own routes.
+// MakeHTTPHandler serves it at /openapi.json merged with the documents of the
+// services it fronts.
+func OpenAPI() *openapi3.T { return spec }
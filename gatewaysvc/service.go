@@ -0,0 +1,115 @@
+package gatewaysvc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+// Service provides the composite operations that would otherwise take the UI
+// several round trips to different services.
+type Service interface {
+	PlayersWithTickets(ctx context.Context, opts playersvc.ListOptions) ([]PlayerWithTickets, error)
+	SpinWithWinner(ctx context.Context, participantIds []int, unweighted bool) (SpinWithWinner, error)
+}
+
+// PlayerService is the part of playersvc the gateway composes with.
+type PlayerService interface {
+	GetAll(ctx context.Context, opts playersvc.ListOptions) ([]playersvc.Player, error)
+	GetByIds(ctx context.Context, ids ...int) ([]playersvc.Player, []int, error)
+}
+
+type PlayerWithTickets struct {
+	playersvc.Player
+	Tickets int `json:"tickets"`
+}
+
+func (p PlayerWithTickets) String() string {
+	return fmt.Sprintf("{id: %v, name: %v, tickets: %v}", p.Id, p.Name, p.Tickets)
+}
+
+type SpinWithWinner struct {
+	Result spinsvc.SpinResult `json:"result"`
+	Winner playersvc.Player   `json:"winner"`
+}
+
+func (s SpinWithWinner) String() string {
+	return fmt.Sprintf("{result: %v, winner: %v}", s.Result, s.Winner)
+}
+
+type gatewayService struct {
+	logger  log.Logger
+	players PlayerService
+	tickets ticketsvc.Service
+	spins   spinsvc.Service
+}
+
+func NewService(logger log.Logger, players PlayerService, tickets ticketsvc.Service, spins spinsvc.Service) Service {
+	return &gatewayService{
+		logger:  logger,
+		players: players,
+		tickets: tickets,
+		spins:   spins,
+	}
+}
+
+func (s *gatewayService) PlayersWithTickets(ctx context.Context, opts playersvc.ListOptions) ([]PlayerWithTickets, error) {
+	players, err := s.players.GetAll(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(players) == 0 {
+		return []PlayerWithTickets{}, nil
+	}
+
+	ids := make([]int, len(players))
+	for i, p := range players {
+		ids[i] = p.Id
+	}
+	tickets, err := s.tickets.Get(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int, len(tickets))
+	for _, t := range tickets {
+		counts[t.Id] = t.Tickets
+	}
+
+	result := make([]PlayerWithTickets, len(players))
+	for i, p := range players {
+		result[i] = PlayerWithTickets{Player: p, Tickets: counts[p.Id]}
+	}
+	return result, nil
+}
+
+func (s *gatewayService) SpinWithWinner(ctx context.Context, participantIds []int, unweighted bool) (SpinWithWinner, error) {
+	var (
+		result spinsvc.SpinResult
+		err    error
+	)
+	if unweighted {
+		result, err = s.spins.SpinUnweighted(ctx, participantIds)
+	} else {
+		result, err = s.spins.Spin(ctx, participantIds)
+	}
+	if err != nil {
+		return SpinWithWinner{}, err
+	}
+
+	// the spin happened, so report it even if the winner can't be looked
+	// up or has since been removed from playersvc
+	players, _, err := s.players.GetByIds(ctx, result.WinnerId)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "spun but couldn't look up the winner", "spin", result.Id, "winner", result.WinnerId, "err", err)
+	}
+	if len(players) == 0 {
+		return SpinWithWinner{Result: result, Winner: playersvc.Player{Id: result.WinnerId}}, nil
+	}
+	return SpinWithWinner{Result: result, Winner: players[0]}, nil
+}
@@ -0,0 +1,46 @@
+package gatewaysvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/playersvc"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+)
+
+type downPlayers struct{}
+
+func (downPlayers) GetAll(context.Context, playersvc.ListOptions) ([]playersvc.Player, error) {
+	return nil, errors.New("playersvc is down")
+}
+
+func (downPlayers) GetByIds(context.Context, ...int) ([]playersvc.Player, []int, error) {
+	return nil, nil, errors.New("playersvc is down")
+}
+
+type fixedSpin struct {
+	spinsvc.Service
+	result spinsvc.SpinResult
+}
+
+func (s fixedSpin) Spin(context.Context, []int) (spinsvc.SpinResult, error) {
+	return s.result, nil
+}
+
+func TestSpinWithWinnerReportsSpinWithoutPlayers(t *testing.T) {
+	result := spinsvc.SpinResult{Id: 3, ParticipantIds: []int{1, 2}, WinnerId: 2, WinnerTickets: 4}
+	s := NewService(log.NewNopLogger(), downPlayers{}, nil, fixedSpin{result: result})
+
+	// the spin already happened, so it's answered even if the winner's name
+	// can't be looked up
+	got, err := s.SpinWithWinner(context.Background(), []int{1, 2}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Result.Id != result.Id || got.Winner.Id != result.WinnerId {
+		t.Errorf("SpinWithWinner = %v, want spin %d won by %d", got, result.Id, result.WinnerId)
+	}
+}
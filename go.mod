@@ -0,0 +1,11 @@
+module github.com/jlthompson3259/matspinner
+
+go 1.19
+
+require (
+	github.com/go-kit/kit v0.12.0
+	github.com/go-kit/log v0.2.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/go-logfmt/logfmt v0.5.1 // indirect
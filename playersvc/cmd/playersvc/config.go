@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+)
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	Sessions       config.Sessions   `yaml:"sessions"`
+	GRPC           config.GRPC       `yaml:"grpc"`
+	Upstreams      upstreams         `yaml:"upstreams"`
+	Client         upstream.Settings `yaml:"client"`
+}
+
+type upstreams struct {
+	TicketSvc string `yaml:"ticketsvc" env:"TICKETSVC_URL" flag:"ticketsvc-url" help:"ticketsvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+	SpinSvc   string `yaml:"spinsvc" env:"SPINSVC_URL" flag:"spinsvc-url" help:"spinsvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+}
+
+func (u *upstreams) Validate() error {
+	if u.TicketSvc == "" || u.SpinSvc == "" {
+		return config.ErrMissingUpstream
+	}
+	return nil
+}
+
+func (s *settings) Validate() error {
+	return s.Sessions.Required(s.Auth)
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:  config.DefaultService(":8087"),
+		Sessions: config.DefaultSessions(),
+		GRPC:     config.GRPC{Addr: ":9087"},
+		Upstreams: upstreams{
+			TicketSvc: "http://ticketsvc:8085",
+			SpinSvc:   "http://spinsvc:8086",
+		},
+		Client: upstream.DefaultSettings(),
+	}
+}
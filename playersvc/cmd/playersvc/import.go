@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/playersvc"
+)
+
+// errUsage is returned when the import command is called wrongly, after
+// its usage has been printed.
+var errUsage = errors.New("usage")
+
+// runImport implements `playersvc import`, which reads an event roster export
+// and sends it to a running playersvc.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	var (
+		server = fs.String("server", envString("PLAYERSVC_URL", "http://localhost:8087"), "playersvc base URL")
+		format = fs.String("format", "", "roster format, csv or json (default: from file extension)")
+		dryRun = fs.Bool("dry-run", false, "report what would happen without changing anything")
+		apiKey = fs.String("api-key", envString("MATSPINNER_API_KEY", ""), "admin API key or token to authenticate with")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: playersvc import [flags] <roster file>\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	path := fs.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	var entries []playersvc.RosterEntry
+	switch *format {
+	case "csv":
+		entries, err = playersvc.ParseRosterCSV(f)
+	case "json":
+		entries, err = playersvc.ParseRosterJSON(f)
+	default:
+		return fmt.Errorf("unknown roster format %q, use -format csv or -format json", *format)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	client, err := playersvc.MakeClientEndpoints(*server, auth.ForwardCredentials(*apiKey))
+	if err != nil {
+		return err
+	}
+
+	report, err := client.Import(context.Background(), entries, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func envString(env, fallback string) string {
+	e := os.Getenv(env)
+	if e == "" {
+		return fallback
+	}
+	return e
+}
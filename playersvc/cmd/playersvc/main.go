@@ -1,64 +1,157 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 	"github.com/jlthompson3259/matspinner/playersvc"
-)
-
-const (
-	defaultHttpPort = "8087"
+	"github.com/jlthompson3259/matspinner/playersvc/pb"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+	"github.com/jlthompson3259/matspinner/usersvc"
 )
 
 func main() {
-	var (
-		httpAddr = net.JoinHostPort("0.0.0.0", envString("HTTP_PORT", defaultHttpPort))
-	)
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		switch err := runImport(os.Args[2:]); {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		default:
+			fmt.Fprintln(os.Stderr, "import:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	var logger log.Logger
-	{
-		logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-		logger = level.NewFilter(logger, level.AllowInfo(), level.SquelchNoLevel(true))
-		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	cfg := defaultSettings()
+	if err := config.Load("playersvc", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("playersvc", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	sessions, err := usersvc.MakeClientEndpoints(cfg.Sessions.URL, cfg.Auth.Forward())
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
 	}
+	authenticator, stopSessions := cfg.Sessions.Watch(cfg.Auth, authenticator, sessions.Ended, logger)
+	bus, err := cfg.Events.NewBus("playersvc", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	forward := cfg.Auth.Forward()
+	breakerState := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "matspinner",
+		Subsystem: "playersvc",
+		Name:      "upstream_breaker_state",
+		Help:      "Circuit breaker state per upstream instance: 0 closed, 1 half-open, 2 open.",
+	}, []string{"upstream", "instance"})
+	tickets, err := upstream.New("ticketsvc", cfg.Upstreams.TicketSvc, cfg.Client, breakerState, logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	spins, err := upstream.New("spinsvc", cfg.Upstreams.SpinSvc, cfg.Client, breakerState, logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	ticketService := ticketsvc.MakeBalancedClientEndpoints(tickets, forward)
+	spinService := spinsvc.MakeBalancedClientEndpoints(spins, forward)
+
+	// only profiles need the upstreams, players can be listed and edited
+	// without them
+	readiness := []health.Check{health.Optional(tickets.Check()), health.Optional(spins.Check())}
 
 	var service playersvc.Service
 	{
-		service = playersvc.NewService(log.With(logger, "component", "service"))
+		fieldKeys := []string{"method"}
+		requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "playersvc",
+			Name:      "requests_total",
+			Help:      "Number of requests received.",
+		}, fieldKeys)
+		errorCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "playersvc",
+			Name:      "errors_total",
+			Help:      "Number of requests that failed.",
+		}, fieldKeys)
+		requestLatency := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: "matspinner",
+			Subsystem: "playersvc",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling requests, in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, fieldKeys)
+
+		base := playersvc.NewService(log.With(logger, "component", "service"), &ticketService, &spinService)
+		service = playersvc.EventsMiddleware(bus, log.With(logger, "component", "eventsMiddleware"))(base)
+		service = playersvc.InstrumentingMiddleware(requestCount, errorCount, requestLatency)(service)
 		service = playersvc.LoggingMiddleware(log.With(logger, "component", "loggingMiddleware"))(service)
 	}
 
 	var (
 		endpoints   = playersvc.MakeServerEndpoints(service)
-		httpHandler = playersvc.MakeHTTPHandler(endpoints, log.With(logger, "component", "http"))
+		httpHandler = playersvc.MakeHTTPHandler(endpoints, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
 	)
 
-	errs := make(chan error)
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		errs <- fmt.Errorf("%s", <-c)
-	}()
-
-	go func() {
-		level.Info(logger).Log("transport", "HTTP", "addr", httpAddr)
-		errs <- http.ListenAndServe(httpAddr, httpHandler)
-	}()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/", httpHandler)
 
-	level.Error(logger).Log("exit", <-errs)
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func envString(env, fallback string) string {
-	e := os.Getenv(env)
-	if e == "" {
-		return fallback
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnClose:         []server.Hook{stopSessions, bus.Close, tickets.Close, spins.Close, shutdownTracing},
+	}
+	if cfg.GRPC.Addr != "" {
+		srv.GRPC, srv.GRPCAddr = server.NewGRPC(), cfg.GRPC.Addr
+		pb.RegisterPlayersServer(srv.GRPC, playersvc.MakeGRPCServer(endpoints, authenticator, log.With(logger, "component", "grpc")))
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
 	}
-	return e
+	level.Info(logger).Log("exit", "stopped")
 }
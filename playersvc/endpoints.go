@@ -2,28 +2,43 @@ package playersvc
 
 import (
 	"context"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
 	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 )
 
 type EndpointSet struct {
-	GetAllEndpoint endpoint.Endpoint
-	AddEndpoint    endpoint.Endpoint
-	UpdateEndpoint endpoint.Endpoint
+	GetAllEndpoint   endpoint.Endpoint
+	GetByIdsEndpoint endpoint.Endpoint
+	GetEndpoint      endpoint.Endpoint
+	AddEndpoint      endpoint.Endpoint
+	UpdateEndpoint   endpoint.Endpoint
+	ImportEndpoint   endpoint.Endpoint
+	ProfileEndpoint  endpoint.Endpoint
 }
 
 func MakeServerEndpoints(svc Service) EndpointSet {
 	return EndpointSet{
-		GetAllEndpoint: MakeGetAllEndpoint(svc),
-		AddEndpoint:    MakeAddEndpoint(svc),
-		UpdateEndpoint: MakeUpdateEndpoint(svc),
+		GetAllEndpoint:   MakeGetAllEndpoint(svc),
+		GetByIdsEndpoint: MakeGetByIdsEndpoint(svc),
+		GetEndpoint:      MakeGetEndpoint(svc),
+		AddEndpoint:      MakeAddEndpoint(svc),
+		UpdateEndpoint:   MakeUpdateEndpoint(svc),
+		ImportEndpoint:   MakeImportEndpoint(svc),
+		ProfileEndpoint:  MakeProfileEndpoint(svc),
 	}
 }
 
-func MakeClientEndpoints(instance string) (EndpointSet, error) {
+func MakeClientEndpoints(instance string, options ...httptransport.ClientOption) (EndpointSet, error) {
 	if !strings.HasPrefix(instance, "http") {
 		instance = "http://" + instance
 	}
@@ -33,18 +48,44 @@ func MakeClientEndpoints(instance string) (EndpointSet, error) {
 	}
 
 	tgt.Path = ""
-
-	options := []httptransport.ClientOption{}
+	options = append([]httptransport.ClientOption{httptransport.ClientBefore(tracing.ContextToHTTP)}, options...)
 
 	return EndpointSet{
-		GetAllEndpoint: httptransport.NewClient("GET", tgt, encodeGetAllRequest, decodeGetAllResponse, options...).Endpoint(),
-		AddEndpoint:    httptransport.NewClient("POST", tgt, encodeAddRequest, decodeAddResponse, options...).Endpoint(),
-		UpdateEndpoint: httptransport.NewClient("PUT", tgt, encodeUpdateRequest, decodeUpdateResponse, options...).Endpoint(),
+		GetAllEndpoint:   tracing.TraceClient("playersvc.GetAll")(httptransport.NewClient("GET", tgt, encodeGetAllRequest, decodeGetAllResponse, options...).Endpoint()),
+		GetByIdsEndpoint: tracing.TraceClient("playersvc.GetByIds")(httptransport.NewClient("GET", tgt, encodeGetByIdsRequest, decodeGetByIdsResponse, options...).Endpoint()),
+		GetEndpoint:      tracing.TraceClient("playersvc.Get")(httptransport.NewClient("GET", tgt, encodeGetRequest, decodeGetResponse, options...).Endpoint()),
+		AddEndpoint:      tracing.TraceClient("playersvc.Add")(httptransport.NewClient("POST", tgt, encodeAddRequest, decodeAddResponse, options...).Endpoint()),
+		UpdateEndpoint:   tracing.TraceClient("playersvc.Update")(httptransport.NewClient("PUT", tgt, encodeUpdateRequest, decodeUpdateResponse, options...).Endpoint()),
+		ImportEndpoint:   tracing.TraceClient("playersvc.Import")(httptransport.NewClient("POST", tgt, encodeImportRequest, decodeImportResponse, options...).Endpoint()),
+		ProfileEndpoint:  tracing.TraceClient("playersvc.Profile")(httptransport.NewClient("GET", tgt, encodeProfileRequest, decodeProfileResponse, options...).Endpoint()),
 	}, nil
 }
 
-func (e *EndpointSet) GetAll(ctx context.Context) ([]Player, error) {
-	request := getAllRequest{}
+// MakeBalancedClientEndpoints makes client endpoints that spread calls over
+// u's instances. Adding and importing players aren't safe to retry.
+func MakeBalancedClientEndpoints(u *upstream.Upstream, options ...httptransport.ClientOption) EndpointSet {
+	factory := func(method func(EndpointSet) endpoint.Endpoint) sd.Factory {
+		return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+			e, err := MakeClientEndpoints(instance, options...)
+			if err != nil {
+				return nil, nil, err
+			}
+			return method(e), nil, nil
+		}
+	}
+	return EndpointSet{
+		GetAllEndpoint:   u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetAllEndpoint }), true),
+		GetByIdsEndpoint: u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetByIdsEndpoint }), true),
+		GetEndpoint:      u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetEndpoint }), true),
+		AddEndpoint:      u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.AddEndpoint }), false),
+		UpdateEndpoint:   u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.UpdateEndpoint }), true),
+		ImportEndpoint:   u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.ImportEndpoint }), false),
+		ProfileEndpoint:  u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.ProfileEndpoint }), true),
+	}
+}
+
+func (e *EndpointSet) GetAll(ctx context.Context, opts ListOptions) ([]Player, error) {
+	request := getAllRequest{ListOptions: opts}
 	r, err := e.GetAllEndpoint(ctx, request)
 	if err != nil {
 		return nil, err
@@ -53,6 +94,26 @@ func (e *EndpointSet) GetAll(ctx context.Context) ([]Player, error) {
 	return resp.Players, nil
 }
 
+func (e *EndpointSet) GetByIds(ctx context.Context, ids ...int) ([]Player, []int, error) {
+	request := getByIdsRequest{Ids: ids}
+	r, err := e.GetByIdsEndpoint(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := r.(byIdsResponse)
+	return resp.Players, resp.NotFound, nil
+}
+
+func (e *EndpointSet) Get(ctx context.Context, id int) (Player, error) {
+	request := getRequest{Id: id}
+	r, err := e.GetEndpoint(ctx, request)
+	if err != nil {
+		return Player{}, err
+	}
+	resp := r.(singleResponse)
+	return resp.Player, nil
+}
+
 func (e *EndpointSet) Add(ctx context.Context, name string) (Player, error) {
 	request := addRequest{Name: name}
 	r, err := e.AddEndpoint(ctx, request)
@@ -63,7 +124,7 @@ func (e *EndpointSet) Add(ctx context.Context, name string) (Player, error) {
 	return resp.Player, nil
 }
 
-func (e *EndpointSet) Increment(ctx context.Context, player Player) (Player, error) {
+func (e *EndpointSet) Update(ctx context.Context, player Player) (Player, error) {
 	request := updateRequest{Player: player}
 	r, err := e.UpdateEndpoint(ctx, request)
 	if err != nil {
@@ -73,13 +134,57 @@ func (e *EndpointSet) Increment(ctx context.Context, player Player) (Player, err
 	return resp.Player, nil
 }
 
+func (e *EndpointSet) Import(ctx context.Context, entries []RosterEntry, dryRun bool) (ImportReport, error) {
+	request := importRequest{Entries: entries, DryRun: dryRun}
+	r, err := e.ImportEndpoint(ctx, request)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	resp := r.(importResponse)
+	return resp.Report, nil
+}
+
+func (e *EndpointSet) GetProfile(ctx context.Context, id int) (Profile, error) {
+	request := profileRequest{Id: id}
+	r, err := e.ProfileEndpoint(ctx, request)
+	if err != nil {
+		return Profile{}, err
+	}
+	resp := r.(profileResponse)
+	return resp.Profile, nil
+}
+
 func MakeGetAllEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		players, err := svc.GetAll(ctx)
+		req := request.(getAllRequest)
+		players, err := svc.GetAll(ctx, req.ListOptions)
 		return multiResponse{players, err}, nil
 	}
 }
 
+func MakeGetByIdsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getByIdsRequest)
+		players, notFound, err := svc.GetByIds(ctx, req.Ids...)
+		return byIdsResponse{players, notFound, err}, nil
+	}
+}
+
+func MakeGetEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getRequest)
+		players, _, err := svc.GetByIds(ctx, req.Id)
+		if err == nil && len(players) == 0 {
+			err = apierror.WithDetails(ErrPlayerDoesNotExist, map[string]string{"id": strconv.Itoa(req.Id)})
+		}
+		var player Player
+		if len(players) > 0 {
+			player = players[0]
+		}
+		return singleResponse{player, err}, nil
+	}
+}
+
 func MakeAddEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(addRequest)
@@ -96,7 +201,36 @@ func MakeUpdateEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+func MakeImportEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(importRequest)
+		report, err := svc.Import(ctx, req.Entries, req.DryRun)
+		return importResponse{report, err}, nil
+	}
+}
+
+func MakeProfileEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(profileRequest)
+		profile, err := svc.GetProfile(ctx, req.Id)
+		return profileResponse{profile, err}, nil
+	}
+}
+
 type getAllRequest struct {
+	ListOptions
+}
+
+type getByIdsRequest struct {
+	Ids []int
+}
+
+type getRequest struct {
+	Id int
+}
+
+type profileRequest struct {
+	Id int
 }
 
 type updateRequest struct {
@@ -107,16 +241,43 @@ type addRequest struct {
 	Name string `json:"name,omitempty"`
 }
 
+type importRequest struct {
+	Entries []RosterEntry `json:"entries"`
+	DryRun  bool          `json:"dryRun"`
+}
+
 type singleResponse struct {
 	Player Player `json:"player,omitempty"`
-	Err    error  `json:"err,omitempty"`
+	Err    error  `json:"-"`
 }
 
 func (r singleResponse) error() error { return r.Err }
 
 type multiResponse struct {
 	Players []Player `json:"players,omitempty"`
-	Err     error    `json:"err,omitempty"`
+	Err     error    `json:"-"`
 }
 
 func (r multiResponse) error() error { return r.Err }
+
+type byIdsResponse struct {
+	Players  []Player `json:"players"`
+	NotFound []int    `json:"notFound"`
+	Err      error    `json:"-"`
+}
+
+func (r byIdsResponse) error() error { return r.Err }
+
+type importResponse struct {
+	Report ImportReport `json:"report"`
+	Err    error        `json:"-"`
+}
+
+func (r importResponse) error() error { return r.Err }
+
+type profileResponse struct {
+	Profile Profile `json:"profile"`
+	Err     error   `json:"-"`
+}
+
+func (r profileResponse) error() error { return r.Err }
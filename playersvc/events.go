@@ -0,0 +1,66 @@
+package playersvc
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/events"
+)
+
+// EventPlayerAdded is published with a PlayerAdded for each new player,
+// whether added by hand or created by a roster import.
+const EventPlayerAdded = "players.added"
+
+type PlayerAdded struct {
+	Player Player `json:"player"`
+}
+
+type eventsMiddleware struct {
+	next   Service
+	bus    events.Publisher
+	logger log.Logger
+}
+
+// EventsMiddleware publishes the service's events on bus after each change.
+func EventsMiddleware(bus events.Publisher, logger log.Logger) ServiceMiddleware {
+	return func(next Service) Service {
+		return &eventsMiddleware{next: next, bus: bus, logger: logger}
+	}
+}
+
+func (mw *eventsMiddleware) Add(ctx context.Context, name string) (Player, error) {
+	p, err := mw.next.Add(ctx, name)
+	if err == nil {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventPlayerAdded, PlayerAdded{Player: p})
+	}
+	return p, err
+}
+
+func (mw *eventsMiddleware) GetAll(ctx context.Context, opts ListOptions) ([]Player, error) {
+	return mw.next.GetAll(ctx, opts)
+}
+
+func (mw *eventsMiddleware) GetByIds(ctx context.Context, ids ...int) ([]Player, []int, error) {
+	return mw.next.GetByIds(ctx, ids...)
+}
+
+func (mw *eventsMiddleware) Update(ctx context.Context, player Player) (Player, error) {
+	return mw.next.Update(ctx, player)
+}
+
+func (mw *eventsMiddleware) Import(ctx context.Context, entries []RosterEntry, dryRun bool) (ImportReport, error) {
+	r, err := mw.next.Import(ctx, entries, dryRun)
+	if err == nil && !r.DryRun {
+		for _, row := range r.Created {
+			if row.Player != nil {
+				events.PublishOrLog(ctx, mw.bus, mw.logger, EventPlayerAdded, PlayerAdded{Player: *row.Player})
+			}
+		}
+	}
+	return r, err
+}
+
+func (mw *eventsMiddleware) GetProfile(ctx context.Context, id int) (Profile, error) {
+	return mw.next.GetProfile(ctx, id)
+}
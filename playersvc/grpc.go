@@ -0,0 +1,416 @@
+package playersvc
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/transport"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/playersvc/pb"
+)
+
+const grpcService = "playersvc.Players"
+
+type grpcServer struct {
+	pb.UnimplementedPlayersServer
+	getAll   grpctransport.Handler
+	getByIds grpctransport.Handler
+	get      grpctransport.Handler
+	add      grpctransport.Handler
+	update   grpctransport.Handler
+	import_  grpctransport.Handler
+	profile  grpctransport.Handler
+}
+
+// MakeGRPCServer serves the endpoints over gRPC, with the same roles
+// required as over HTTP.
+func MakeGRPCServer(e EndpointSet, authn auth.Authenticator, logger log.Logger) pb.PlayersServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		grpctransport.ServerBefore(auth.GRPCToContext(authn)),
+	}
+	return &grpcServer{
+		getAll:   grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetAllEndpoint), decodeGRPCGetAllRequest, encodeGRPCMultiResponse, options...),
+		getByIds: grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetByIdsEndpoint), decodeGRPCGetByIdsRequest, encodeGRPCByIdsResponse, options...),
+		get:      grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetEndpoint), decodeGRPCGetRequest, encodeGRPCSingleResponse, options...),
+		add:      grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.AddEndpoint), decodeGRPCAddRequest, encodeGRPCSingleResponse, options...),
+		update:   grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.UpdateEndpoint), decodeGRPCUpdateRequest, encodeGRPCSingleResponse, options...),
+		import_:  grpctransport.NewServer(auth.Require(auth.RoleAdmin)(e.ImportEndpoint), decodeGRPCImportRequest, encodeGRPCImportResponse, options...),
+		profile:  grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.ProfileEndpoint), decodeGRPCProfileRequest, encodeGRPCProfileResponse, options...),
+	}
+}
+
+func (s *grpcServer) GetAll(ctx context.Context, req *pb.GetAllRequest) (*pb.PlayersReply, error) {
+	_, rep, err := s.getAll.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.PlayersReply), nil
+}
+
+func (s *grpcServer) GetByIds(ctx context.Context, req *pb.GetByIdsRequest) (*pb.ByIdsReply, error) {
+	_, rep, err := s.getByIds.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ByIdsReply), nil
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.PlayerReply, error) {
+	_, rep, err := s.get.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.PlayerReply), nil
+}
+
+func (s *grpcServer) Add(ctx context.Context, req *pb.AddRequest) (*pb.PlayerReply, error) {
+	_, rep, err := s.add.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.PlayerReply), nil
+}
+
+func (s *grpcServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.PlayerReply, error) {
+	_, rep, err := s.update.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.PlayerReply), nil
+}
+
+func (s *grpcServer) Import(ctx context.Context, req *pb.ImportRequest) (*pb.ImportReply, error) {
+	_, rep, err := s.import_.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ImportReply), nil
+}
+
+func (s *grpcServer) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.ProfileReply, error) {
+	_, rep, err := s.profile.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ProfileReply), nil
+}
+
+// MakeGRPCClientEndpoints makes client endpoints that call the service over
+// conn.
+func MakeGRPCClientEndpoints(conn *grpc.ClientConn, options ...grpctransport.ClientOption) EndpointSet {
+	options = append([]grpctransport.ClientOption{grpctransport.ClientBefore(tracing.ContextToGRPC)}, options...)
+
+	return EndpointSet{
+		GetAllEndpoint:   tracing.TraceClient("playersvc.GetAll")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetAll", encodeGRPCGetAllRequest, decodeGRPCMultiResponse, pb.PlayersReply{}, options...).Endpoint())),
+		GetByIdsEndpoint: tracing.TraceClient("playersvc.GetByIds")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetByIds", encodeGRPCGetByIdsRequest, decodeGRPCByIdsResponse, pb.ByIdsReply{}, options...).Endpoint())),
+		GetEndpoint:      tracing.TraceClient("playersvc.Get")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Get", encodeGRPCGetRequest, decodeGRPCSingleResponse, pb.PlayerReply{}, options...).Endpoint())),
+		AddEndpoint:      tracing.TraceClient("playersvc.Add")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Add", encodeGRPCAddRequest, decodeGRPCSingleResponse, pb.PlayerReply{}, options...).Endpoint())),
+		UpdateEndpoint:   tracing.TraceClient("playersvc.Update")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Update", encodeGRPCUpdateRequest, decodeGRPCSingleResponse, pb.PlayerReply{}, options...).Endpoint())),
+		ImportEndpoint:   tracing.TraceClient("playersvc.Import")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Import", encodeGRPCImportRequest, decodeGRPCImportResponse, pb.ImportReply{}, options...).Endpoint())),
+		ProfileEndpoint:  tracing.TraceClient("playersvc.GetProfile")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetProfile", encodeGRPCProfileRequest, decodeGRPCProfileResponse, pb.ProfileReply{}, options...).Endpoint())),
+	}
+}
+
+// grpcError turns err into a gRPC status with the code matching the HTTP
+// status the error is answered with.
+func grpcError(err error) error {
+	return apierror.GRPCStatus(codeFrom(err), err)
+}
+
+/** server decode/encode **/
+func decodeGRPCGetAllRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetAllRequest)
+	return getAllRequest{ListOptions: ListOptions{
+		SortBy:          req.SortBy,
+		Descending:      req.Descending,
+		Offset:          int(req.Offset),
+		Limit:           int(req.Limit),
+		IncludeInactive: req.IncludeInactive,
+	}}, nil
+}
+
+func decodeGRPCGetByIdsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetByIdsRequest)
+	if len(req.Ids) == 0 {
+		return nil, ErrMissingIds
+	}
+	return getByIdsRequest{Ids: toInts(req.Ids)}, nil
+}
+
+func decodeGRPCGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetRequest)
+	return getRequest{Id: int(req.Id)}, nil
+}
+
+func decodeGRPCAddRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AddRequest)
+	return addRequest{Name: req.Name}, nil
+}
+
+func decodeGRPCUpdateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UpdateRequest)
+	return updateRequest{Player: fromPBPlayer(req.Player)}, nil
+}
+
+// decodeGRPCImportRequest numbers entries without a line by their position,
+// as decodeImportRequest does.
+func decodeGRPCImportRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ImportRequest)
+	request := importRequest{Entries: make([]RosterEntry, len(req.Entries)), DryRun: req.DryRun}
+	for i, entry := range req.Entries {
+		request.Entries[i] = fromPBEntry(entry)
+		if request.Entries[i].Line == 0 {
+			request.Entries[i].Line = i + 1
+		}
+	}
+	return request, nil
+}
+
+func decodeGRPCProfileRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetProfileRequest)
+	return profileRequest{Id: int(req.Id)}, nil
+}
+
+// encodeGRPCSingleResponse and the other encoders return a business-logic
+// error as the call's error, where the HTTP transport would have encoded it
+// in the response.
+func encodeGRPCSingleResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(singleResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.PlayerReply{Player: toPBPlayer(r.Player)}, nil
+}
+
+func encodeGRPCMultiResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(multiResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.PlayersReply{Players: toPBPlayers(r.Players)}, nil
+}
+
+func encodeGRPCByIdsResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(byIdsResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.ByIdsReply{Players: toPBPlayers(r.Players), NotFound: toInt64s(r.NotFound)}, nil
+}
+
+func encodeGRPCImportResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(importResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	rows := func(rows []ImportRow) []*pb.ImportRow {
+		out := make([]*pb.ImportRow, len(rows))
+		for i, row := range rows {
+			out[i] = &pb.ImportRow{Entry: toPBEntry(row.Entry), Candidates: toPBPlayers(row.Candidates), Reason: row.Reason}
+			if row.Player != nil {
+				out[i].Player = toPBPlayer(*row.Player)
+			}
+		}
+		return out
+	}
+	return &pb.ImportReply{Report: &pb.ImportReport{
+		DryRun:    r.Report.DryRun,
+		Created:   rows(r.Report.Created),
+		Matched:   rows(r.Report.Matched),
+		Ambiguous: rows(r.Report.Ambiguous),
+		Invalid:   rows(r.Report.Invalid),
+	}}, nil
+}
+
+func encodeGRPCProfileResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(profileResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	profile := &pb.Profile{
+		Player:     toPBPlayer(r.Profile.Player),
+		Tickets:    int64(r.Profile.Tickets),
+		Attendance: int64(r.Profile.Attendance),
+		Wins:       int64(r.Profile.Wins),
+		NextOdds:   r.Profile.NextOdds,
+	}
+	if r.Profile.LastWin != nil {
+		profile.LastWin = timestamppb.New(*r.Profile.LastWin)
+	}
+	return &pb.ProfileReply{Profile: profile}, nil
+}
+
+/** client encode/decode **/
+func encodeGRPCGetAllRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getAllRequest)
+	return &pb.GetAllRequest{
+		SortBy:          req.SortBy,
+		Descending:      req.Descending,
+		Offset:          int64(req.Offset),
+		Limit:           int64(req.Limit),
+		IncludeInactive: req.IncludeInactive,
+	}, nil
+}
+
+func encodeGRPCGetByIdsRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getByIdsRequest)
+	return &pb.GetByIdsRequest{Ids: toInt64s(req.Ids)}, nil
+}
+
+func encodeGRPCGetRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getRequest)
+	return &pb.GetRequest{Id: int64(req.Id)}, nil
+}
+
+func encodeGRPCAddRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(addRequest)
+	return &pb.AddRequest{Name: req.Name}, nil
+}
+
+func encodeGRPCUpdateRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(updateRequest)
+	return &pb.UpdateRequest{Player: toPBPlayer(req.Player)}, nil
+}
+
+func encodeGRPCImportRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(importRequest)
+	entries := make([]*pb.RosterEntry, len(req.Entries))
+	for i, entry := range req.Entries {
+		entries[i] = toPBEntry(entry)
+	}
+	return &pb.ImportRequest{Entries: entries, DryRun: req.DryRun}, nil
+}
+
+func encodeGRPCProfileRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(profileRequest)
+	return &pb.GetProfileRequest{Id: int64(req.Id)}, nil
+}
+
+func decodeGRPCSingleResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PlayerReply)
+	return singleResponse{Player: fromPBPlayer(reply.Player)}, nil
+}
+
+func decodeGRPCMultiResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.PlayersReply)
+	return multiResponse{Players: fromPBPlayers(reply.Players)}, nil
+}
+
+func decodeGRPCByIdsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ByIdsReply)
+	return byIdsResponse{Players: fromPBPlayers(reply.Players), NotFound: toInts(reply.NotFound)}, nil
+}
+
+func decodeGRPCImportResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ImportReply)
+	rows := func(rows []*pb.ImportRow) []ImportRow {
+		out := make([]ImportRow, len(rows))
+		for i, row := range rows {
+			out[i] = ImportRow{Entry: fromPBEntry(row.Entry), Candidates: fromPBPlayers(row.Candidates), Reason: row.Reason}
+			if row.Player != nil {
+				player := fromPBPlayer(row.Player)
+				out[i].Player = &player
+			}
+		}
+		return out
+	}
+	report := reply.GetReport()
+	return importResponse{Report: ImportReport{
+		DryRun:    report.GetDryRun(),
+		Created:   rows(report.GetCreated()),
+		Matched:   rows(report.GetMatched()),
+		Ambiguous: rows(report.GetAmbiguous()),
+		Invalid:   rows(report.GetInvalid()),
+	}}, nil
+}
+
+func decodeGRPCProfileResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	p := grpcReply.(*pb.ProfileReply).GetProfile()
+	profile := Profile{
+		Player:     fromPBPlayer(p.GetPlayer()),
+		Tickets:    int(p.GetTickets()),
+		Attendance: int(p.GetAttendance()),
+		Wins:       int(p.GetWins()),
+		NextOdds:   p.GetNextOdds(),
+	}
+	if p.GetLastWin() != nil {
+		lastWin := p.GetLastWin().AsTime()
+		profile.LastWin = &lastWin
+	}
+	return profileResponse{Profile: profile}, nil
+}
+
+// toPBPlayer leaves created unset for a player without one, such as an
+// update.
+func toPBPlayer(p Player) *pb.Player {
+	player := &pb.Player{
+		Id:         int64(p.Id),
+		Name:       p.Name,
+		ExternalId: p.ExternalId,
+		Inactive:   p.Inactive,
+	}
+	if !p.Created.IsZero() {
+		player.Created = timestamppb.New(p.Created)
+	}
+	return player
+}
+
+func fromPBPlayer(p *pb.Player) Player {
+	player := Player{
+		Id:         int(p.GetId()),
+		Name:       p.GetName(),
+		ExternalId: p.GetExternalId(),
+		Inactive:   p.GetInactive(),
+	}
+	if p.GetCreated() != nil {
+		player.Created = p.GetCreated().AsTime()
+	}
+	return player
+}
+
+func toPBPlayers(players []Player) []*pb.Player {
+	out := make([]*pb.Player, len(players))
+	for i, p := range players {
+		out[i] = toPBPlayer(p)
+	}
+	return out
+}
+
+func fromPBPlayers(players []*pb.Player) []Player {
+	out := make([]Player, len(players))
+	for i, p := range players {
+		out[i] = fromPBPlayer(p)
+	}
+	return out
+}
+
+func toPBEntry(e RosterEntry) *pb.RosterEntry {
+	return &pb.RosterEntry{Line: int64(e.Line), ExternalId: e.ExternalId, Name: e.Name}
+}
+
+func fromPBEntry(e *pb.RosterEntry) RosterEntry {
+	return RosterEntry{Line: int(e.GetLine()), ExternalId: e.GetExternalId(), Name: e.GetName()}
+}
+
+func toInt64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
+
+func toInts(ids []int64) []int {
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
@@ -4,59 +4,135 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 )
 
 var (
-	ErrMissingIds = errors.New("missing ids")
-	ErrParsingIds = errors.New("error parsing ids, should be ints")
+	ErrMissingIds    = apierror.New("players.missing_ids", "missing ids")
+	ErrParsingIds    = apierror.New("players.parsing_ids", "error parsing ids, should be ints")
+	ErrParsingDryRun = apierror.New("players.parsing_dry_run", "error parsing dryRun, should be a bool")
 )
 
-func MakeHTTPHandler(e EndpointSet, logger log.Logger) http.Handler {
+func MakeHTTPHandler(e EndpointSet, authn auth.Authenticator, crossOrigin cors.Policy, limits limit.Settings, logger log.Logger) http.Handler {
+	return cors.Handler(crossOrigin, limit.Handler(limits, authn, openapi.Serve(spec, makeRouter(e, authn, logger))))
+}
+
+// makeRouter routes the endpoints. Every route must be described by the
+// OpenAPI document, which the tests check.
+func makeRouter(e EndpointSet, authn auth.Authenticator, logger log.Logger) *mux.Router {
 	r := mux.NewRouter()
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.HTTPToContext(), auth.HTTPToContext(authn)),
+		httptransport.ServerFinalizer(tracing.ServerFinalizer),
 	}
 
+	r.Methods("GET").Path("/players").Queries("ids", "{ids}").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetByIdsEndpoint),
+		decodeGetByIdsRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/players/{id:[0-9]+}").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetEndpoint),
+		decodeGetRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/players/{id:[0-9]+}/profile").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.ProfileEndpoint),
+		decodeProfileRequest,
+		encodeResponse,
+		options...,
+	))
 	r.Methods("GET").Path("/players").Handler(httptransport.NewServer(
-		e.GetAllEndpoint,
+		auth.Require(auth.RoleDisplay)(e.GetAllEndpoint),
 		decodeGetAllRequest,
 		encodeResponse,
 		options...,
 	))
 	r.Methods("PUT").Path("/players").Handler(httptransport.NewServer(
-		e.UpdateEndpoint,
+		auth.Require(auth.RoleStaff)(e.UpdateEndpoint),
 		decodeUpdateRequest,
 		encodeResponse,
 		options...,
 	))
 	r.Methods("POST").Path("/players").Handler(httptransport.NewServer(
-		e.AddEndpoint,
+		auth.Require(auth.RoleStaff)(e.AddEndpoint),
 		decodeAddRequest,
 		encodeResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/players/import").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.ImportEndpoint),
+		decodeImportRequest,
+		encodeResponse,
+		options...,
+	))
 	return r
 }
 
 /** server decode/encode **/
 func decodeGetAllRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	return getAllRequest{}, nil
+	opts, err := ListOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	return getAllRequest{ListOptions: opts}, nil
+}
+
+func decodeGetByIdsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	if !q.Has("ids") {
+		return nil, ErrMissingIds
+	}
+	ids, err := decodeIdsQueryString(q.Get("ids"))
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"ids": q.Get("ids")})
+	}
+	return getByIdsRequest{Ids: ids}, nil
+}
+
+func decodeGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"id": mux.Vars(r)["id"]})
+	}
+	return getRequest{Id: id}, nil
+}
+
+func decodeProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"id": mux.Vars(r)["id"]})
+	}
+	return profileRequest{Id: id}, nil
 }
 
 func decodeAddRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request addRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, limit.BodyError(err)
 	}
 	return request, nil
 }
@@ -64,7 +140,50 @@ func decodeAddRequest(_ context.Context, r *http.Request) (interface{}, error) {
 func decodeUpdateRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request updateRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, limit.BodyError(err)
+	}
+	return request, nil
+}
+
+// decodeImportRequest accepts either a CSV roster export (Content-Type
+// text/csv) or JSON, which may be a full importRequest or a bare array of
+// entries. dryRun can also be given as a query parameter.
+func decodeImportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request importRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		entries, err := ParseRosterCSV(r.Body)
+		if err != nil {
+			return nil, limit.BodyError(err)
+		}
+		request.Entries = entries
+	default:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, limit.BodyError(err)
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			entries, err := ParseRosterJSON(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			request.Entries = entries
+		}
+	}
+
+	if q := r.URL.Query(); q.Has("dryRun") {
+		dryRun, err := strconv.ParseBool(q.Get("dryRun"))
+		if err != nil {
+			return nil, ErrParsingDryRun
+		}
+		request.DryRun = dryRun
+	}
+
+	for i := range request.Entries {
+		if request.Entries[i].Line == 0 {
+			request.Entries[i].Line = i + 1
+		}
 	}
 	return request, nil
 }
@@ -96,19 +215,47 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(codeFrom(err))
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+	apierror.Encode(w, codeFrom(err), err)
+}
+
+func decodeIdsQueryString(idStr string) (ids []int, err error) {
+	strs := strings.Split(idStr, ",")
+	ids = make([]int, len(strs))
+	for i, v := range strs {
+		ids[i], err = strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func encodeIdsQueryString(ids []int) (idStr string) {
+	strs := make([]string, len(ids))
+	for i, v := range ids {
+		strs[i] = fmt.Sprint(v)
+	}
+	return strings.Join(strs, ",")
 }
 
 func codeFrom(err error) int {
-	switch err {
+	switch apierror.Sentinel(err) {
+	case auth.ErrUnauthenticated, auth.ErrInvalidCredentials:
+		return http.StatusUnauthorized
+	case auth.ErrForbidden, auth.ErrWrongStore:
+		return http.StatusForbidden
 	case ErrPlayerDoesNotExist:
 		return http.StatusNotFound
+	case ErrMissingIds, ErrParsingIds, ErrRosterMissingName, ErrParsingDryRun, ErrParsingList, ErrUnknownSortField, ErrInvalidPaging:
+		return http.StatusBadRequest
+	case auth.ErrSessionsUnknown, upstream.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case upstream.ErrBadGateway:
+		return http.StatusBadGateway
+	case limit.ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
 	default:
-		return http.StatusInternalServerError
+		return apierror.StatusCode(err, http.StatusInternalServerError)
 	}
 }
 
@@ -117,6 +264,21 @@ func decodeGetAllResponse(ctx context.Context, resp *http.Response) (interface{}
 	return decodeMultiResponse(ctx, resp)
 }
 
+func decodeGetByIdsResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response byIdsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeGetResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	return decodeSingleResponse(ctx, resp)
+}
+
 func decodeAddResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
 	return decodeSingleResponse(ctx, resp)
 }
@@ -125,7 +287,32 @@ func decodeUpdateResponse(ctx context.Context, resp *http.Response) (interface{}
 	return decodeSingleResponse(ctx, resp)
 }
 
+func decodeImportResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response importResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeProfileResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response profileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func decodeSingleResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
 	var response singleResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
@@ -134,6 +321,9 @@ func decodeSingleResponse(ctx context.Context, resp *http.Response) (interface{}
 }
 
 func decodeMultiResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
 	var response multiResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
@@ -142,7 +332,30 @@ func decodeMultiResponse(ctx context.Context, resp *http.Response) (interface{},
 }
 
 func encodeGetAllRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getAllRequest)
 	req.URL.Path = "/players"
+	req.URL.RawQuery = r.ListOptions.Query().Encode()
+	return nil
+}
+
+func encodeGetByIdsRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getByIdsRequest)
+	q := req.URL.Query()
+	q.Set("ids", encodeIdsQueryString(r.Ids))
+	req.URL.Path = "/players"
+	req.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func encodeGetRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getRequest)
+	req.URL.Path = "/players/" + strconv.Itoa(r.Id)
+	return nil
+}
+
+func encodeProfileRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(profileRequest)
+	req.URL.Path = "/players/" + strconv.Itoa(r.Id) + "/profile"
 	return nil
 }
 
@@ -156,6 +369,11 @@ func encodeUpdateRequest(ctx context.Context, req *http.Request, request interfa
 	return encodeRequest(ctx, req, request)
 }
 
+func encodeImportRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/players/import"
+	return encodeRequest(ctx, req, request)
+}
+
 // encodeRequest likewise JSON-encodes the request to the HTTP request body.
 // Don't use it directly as a transport/http.Client EncodeRequestFunc:
 // profilesvc endpoints require mutating the HTTP method and request path.
@@ -165,6 +383,7 @@ func encodeRequest(_ context.Context, req *http.Request, request interface{}) er
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Body = io.NopCloser(&buf)
 	return nil
 }
@@ -0,0 +1,13 @@
+package playersvc
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/openapi"
+)
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	openapi.CheckRoutes(t, OpenAPI(), makeRouter(EndpointSet{}, nil, log.NewNopLogger()))
+}
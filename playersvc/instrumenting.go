@@ -0,0 +1,66 @@
+package playersvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+	next           Service
+}
+
+// InstrumentingMiddleware records request counts, error counts and latency
+// per method, labelled "method".
+func InstrumentingMiddleware(requestCount, errorCount metrics.Counter, requestLatency metrics.Histogram) ServiceMiddleware {
+	return func(next Service) Service {
+		return &instrumentingMiddleware{
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+			next:           next,
+		}
+	}
+}
+
+func (mw *instrumentingMiddleware) Add(ctx context.Context, name string) (p Player, err error) {
+	defer mw.observe("Add", time.Now(), &err)
+	return mw.next.Add(ctx, name)
+}
+
+func (mw *instrumentingMiddleware) GetAll(ctx context.Context, opts ListOptions) (p []Player, err error) {
+	defer mw.observe("GetAll", time.Now(), &err)
+	return mw.next.GetAll(ctx, opts)
+}
+
+func (mw *instrumentingMiddleware) GetByIds(ctx context.Context, ids ...int) (p []Player, notFound []int, err error) {
+	defer mw.observe("GetByIds", time.Now(), &err)
+	return mw.next.GetByIds(ctx, ids...)
+}
+
+func (mw *instrumentingMiddleware) Update(ctx context.Context, player Player) (p Player, err error) {
+	defer mw.observe("Update", time.Now(), &err)
+	return mw.next.Update(ctx, player)
+}
+
+func (mw *instrumentingMiddleware) Import(ctx context.Context, entries []RosterEntry, dryRun bool) (r ImportReport, err error) {
+	defer mw.observe("Import", time.Now(), &err)
+	return mw.next.Import(ctx, entries, dryRun)
+}
+
+func (mw *instrumentingMiddleware) GetProfile(ctx context.Context, id int) (p Profile, err error) {
+	defer mw.observe("GetProfile", time.Now(), &err)
+	return mw.next.GetProfile(ctx, id)
+}
+
+func (mw *instrumentingMiddleware) observe(method string, begin time.Time, err *error) {
+	mw.requestCount.With("method", method).Add(1)
+	if *err != nil {
+		mw.errorCount.With("method", method).Add(1)
+	}
+	mw.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
@@ -0,0 +1,139 @@
+package playersvc
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var (
+	ErrUnknownSortField = apierror.New("players.unknown_sort_field", "unknown sort field, should be one of id, name, created")
+	ErrInvalidPaging    = apierror.New("players.invalid_paging", "offset and limit must not be negative")
+	ErrParsingList      = apierror.New("players.parsing_list", "error parsing list options, offset and limit should be ints, order asc or desc and includeInactive a bool")
+)
+
+const (
+	SortById      = "id"
+	SortByName    = "name"
+	SortByCreated = "created"
+)
+
+// ListOptions controls which players GetAll returns and in what order. The
+// zero value lists every active player ordered by id.
+type ListOptions struct {
+	SortBy          string `json:"sortBy,omitempty"`
+	Descending      bool   `json:"descending,omitempty"`
+	Offset          int    `json:"offset,omitempty"`
+	Limit           int    `json:"limit,omitempty"`
+	IncludeInactive bool   `json:"includeInactive,omitempty"`
+}
+
+func (o ListOptions) validate() error {
+	switch o.SortBy {
+	case "", SortById, SortByName, SortByCreated:
+	default:
+		return ErrUnknownSortField
+	}
+	if o.Offset < 0 || o.Limit < 0 {
+		return ErrInvalidPaging
+	}
+	return nil
+}
+
+// ListOptionsFromQuery reads list options from the sort, order, offset, limit
+// and includeInactive query parameters.
+func ListOptionsFromQuery(q url.Values) (opts ListOptions, err error) {
+	opts.SortBy = q.Get("sort")
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return ListOptions{}, ErrParsingList
+	}
+	if q.Has("offset") {
+		if opts.Offset, err = strconv.Atoi(q.Get("offset")); err != nil {
+			return ListOptions{}, ErrParsingList
+		}
+	}
+	if q.Has("limit") {
+		if opts.Limit, err = strconv.Atoi(q.Get("limit")); err != nil {
+			return ListOptions{}, ErrParsingList
+		}
+	}
+	if q.Has("includeInactive") {
+		if opts.IncludeInactive, err = strconv.ParseBool(q.Get("includeInactive")); err != nil {
+			return ListOptions{}, ErrParsingList
+		}
+	}
+	return opts, nil
+}
+
+// Query is the inverse of ListOptionsFromQuery, leaving out defaults.
+func (o ListOptions) Query() url.Values {
+	q := url.Values{}
+	if o.SortBy != "" {
+		q.Set("sort", o.SortBy)
+	}
+	if o.Descending {
+		q.Set("order", "desc")
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.IncludeInactive {
+		q.Set("includeInactive", "true")
+	}
+	return q
+}
+
+// sortPlayers orders players by the given field, falling back to id so the
+// order is always deterministic.
+func sortPlayers(players []Player, sortBy string, descending bool) {
+	var less func(a, b Player) bool
+	switch sortBy {
+	case SortByName:
+		less = func(a, b Player) bool {
+			an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			if an != bn {
+				return an < bn
+			}
+			return a.Id < b.Id
+		}
+	case SortByCreated:
+		less = func(a, b Player) bool {
+			if !a.Created.Equal(b.Created) {
+				return a.Created.Before(b.Created)
+			}
+			return a.Id < b.Id
+		}
+	default:
+		less = func(a, b Player) bool { return a.Id < b.Id }
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		if descending {
+			return less(players[j], players[i])
+		}
+		return less(players[i], players[j])
+	})
+}
+
+// paginate returns the page of players starting at offset. A limit of zero
+// means no limit.
+func paginate(players []Player, offset, limit int) []Player {
+	if offset >= len(players) {
+		return []Player{}
+	}
+	players = players[offset:]
+	if limit > 0 && limit < len(players) {
+		players = players[:limit]
+	}
+	return players
+}
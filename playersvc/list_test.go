@@ -0,0 +1,93 @@
+package playersvc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func ids(players []Player) []int {
+	ids := make([]int, len(players))
+	for i, p := range players {
+		ids[i] = p.Id
+	}
+	return ids
+}
+
+func TestGetAll(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	// ids 0 to 5, with names out of id order and one inactive player
+	players := s.add(t, "cy", "Ann", "eve", "Bo", "dee", "Al")
+	inactive := players[4]
+	inactive.Inactive = true
+	if _, err := s.players.Update(ctx, inactive); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts ListOptions
+		want []int
+	}{
+		{"zero value", ListOptions{}, []int{0, 1, 2, 3, 5}},
+		{"descending", ListOptions{Descending: true}, []int{5, 3, 2, 1, 0}},
+		{"by name", ListOptions{SortBy: SortByName}, []int{5, 1, 3, 0, 2}},
+		{"by name descending", ListOptions{SortBy: SortByName, Descending: true}, []int{2, 0, 3, 1, 5}},
+		{"by created", ListOptions{SortBy: SortByCreated}, []int{0, 1, 2, 3, 5}},
+		{"first page", ListOptions{Limit: 2}, []int{0, 1}},
+		{"second page", ListOptions{Offset: 2, Limit: 2}, []int{2, 3}},
+		{"last page", ListOptions{Offset: 4, Limit: 2}, []int{5}},
+		{"past the end", ListOptions{Offset: 9}, []int{}},
+		{"including inactive", ListOptions{IncludeInactive: true}, []int{0, 1, 2, 3, 4, 5}},
+		{"including inactive by name", ListOptions{SortBy: SortByName, IncludeInactive: true}, []int{5, 1, 3, 0, 4, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// players are kept in a map, so ask repeatedly to catch an
+			// order that only holds by chance
+			for i := 0; i < 20; i++ {
+				got, err := s.players.GetAll(ctx, tc.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(ids(got), tc.want) {
+					t.Fatalf("GetAll = %v, want ids %v", got, tc.want)
+				}
+				for _, p := range got {
+					if p.Name == "" || p.Created.IsZero() {
+						t.Fatalf("GetAll returned an empty player: %v", got)
+					}
+				}
+			}
+		})
+	}
+
+	for _, opts := range []ListOptions{{SortBy: "tickets"}, {Offset: -1}, {Limit: -1}} {
+		if _, err := s.players.GetAll(ctx, opts); err == nil {
+			t.Errorf("GetAll(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestListOptionsQuery(t *testing.T) {
+	opts := ListOptions{SortBy: SortByName, Descending: true, Offset: 20, Limit: 10, IncludeInactive: true}
+	got, err := ListOptionsFromQuery(opts.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != opts {
+		t.Errorf("round trip = %+v, want %+v", got, opts)
+	}
+	if q := (ListOptions{}).Query(); len(q) != 0 {
+		t.Errorf("zero value query = %v, want none", q)
+	}
+
+	for _, q := range []string{"order=up", "offset=x", "limit=1.5", "includeInactive=maybe"} {
+		values, _ := url.ParseQuery(q)
+		if _, err := ListOptionsFromQuery(values); !errors.Is(err, ErrParsingList) {
+			t.Errorf("ListOptionsFromQuery(%s) err = %v, want %v", q, err, ErrParsingList)
+		}
+	}
+}
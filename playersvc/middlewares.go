@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
 )
 
 type ServiceMiddleware func(Service) Service
@@ -27,23 +29,44 @@ func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
 
 func (mw *loggingMiddleware) Add(ctx context.Context, name string) (p Player, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Add", "player", fmt.Sprintf("%v", p), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Add", "player", fmt.Sprintf("%v", p), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	p, err = mw.next.Add(ctx, name)
 	return
 }
 
-func (mw *loggingMiddleware) GetAll(ctx context.Context) (p []Player, err error) {
+func (mw *loggingMiddleware) GetAll(ctx context.Context, opts ListOptions) (p []Player, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "GetAll", "players", fmt.Sprintf("%v", p), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetAll", "opts", fmt.Sprintf("%+v", opts), "players", fmt.Sprintf("%v", p), "duration", time.Since(begin), "err", err)
 	}(time.Now())
-	p, err = mw.next.GetAll(ctx)
+	p, err = mw.next.GetAll(ctx, opts)
 	return
 }
 
+func (mw *loggingMiddleware) GetByIds(ctx context.Context, ids ...int) (p []Player, notFound []int, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetByIds", "ids", fmt.Sprintf("%v", ids), "notFound", fmt.Sprintf("%v", notFound), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetByIds(ctx, ids...)
+}
+
 func (mw *loggingMiddleware) Update(ctx context.Context, player Player) (p Player, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Update", "player", fmt.Sprintf("%v", player), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Update", "player", fmt.Sprintf("%v", player), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.Update(ctx, player)
 }
+
+func (mw *loggingMiddleware) Import(ctx context.Context, entries []RosterEntry, dryRun bool) (r ImportReport, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Import", "entries", len(entries), "dryRun", dryRun, "report", fmt.Sprintf("%v", r), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Import(ctx, entries, dryRun)
+}
+
+func (mw *loggingMiddleware) GetProfile(ctx context.Context, id int) (p Profile, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetProfile", "id", id, "profile", fmt.Sprintf("%v", p), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetProfile(ctx, id)
+}
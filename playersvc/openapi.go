@@ -0,0 +1,18 @@
+package playersvc
+
+import (
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/jlthompson3259/matspinner/common/openapi"
+)
+
+//go:embed openapi.yaml
+var openapiYAML []byte
+
+var spec = openapi.MustLoad(openapiYAML)
+
+// OpenAPI returns the document describing the HTTP API, which
+// MakeHTTPHandler serves at /openapi.json and validates requests against.
+func OpenAPI() *openapi3.T { return spec }
@@ -0,0 +1,321 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: playersvc.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PlayersClient is the client API for Players service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PlayersClient interface {
+	GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*PlayersReply, error)
+	GetByIds(ctx context.Context, in *GetByIdsRequest, opts ...grpc.CallOption) (*ByIdsReply, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*PlayerReply, error)
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*PlayerReply, error)
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*PlayerReply, error)
+	Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error)
+	GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*ProfileReply, error)
+}
+
+type playersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPlayersClient(cc grpc.ClientConnInterface) PlayersClient {
+	return &playersClient{cc}
+}
+
+func (c *playersClient) GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*PlayersReply, error) {
+	out := new(PlayersReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/GetAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) GetByIds(ctx context.Context, in *GetByIdsRequest, opts ...grpc.CallOption) (*ByIdsReply, error) {
+	out := new(ByIdsReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/GetByIds", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*PlayerReply, error) {
+	out := new(PlayerReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*PlayerReply, error) {
+	out := new(PlayerReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*PlayerReply, error) {
+	out := new(PlayerReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReply, error) {
+	out := new(ImportReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/Import", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *playersClient) GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*ProfileReply, error) {
+	out := new(ProfileReply)
+	err := c.cc.Invoke(ctx, "/playersvc.Players/GetProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PlayersServer is the server API for Players service.
+// All implementations must embed UnimplementedPlayersServer
+// for forward compatibility
+type PlayersServer interface {
+	GetAll(context.Context, *GetAllRequest) (*PlayersReply, error)
+	GetByIds(context.Context, *GetByIdsRequest) (*ByIdsReply, error)
+	Get(context.Context, *GetRequest) (*PlayerReply, error)
+	Add(context.Context, *AddRequest) (*PlayerReply, error)
+	Update(context.Context, *UpdateRequest) (*PlayerReply, error)
+	Import(context.Context, *ImportRequest) (*ImportReply, error)
+	GetProfile(context.Context, *GetProfileRequest) (*ProfileReply, error)
+	mustEmbedUnimplementedPlayersServer()
+}
+
+// UnimplementedPlayersServer must be embedded to have forward compatible implementations.
+type UnimplementedPlayersServer struct {
+}
+
+func (UnimplementedPlayersServer) GetAll(context.Context, *GetAllRequest) (*PlayersReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAll not implemented")
+}
+func (UnimplementedPlayersServer) GetByIds(context.Context, *GetByIdsRequest) (*ByIdsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetByIds not implemented")
+}
+func (UnimplementedPlayersServer) Get(context.Context, *GetRequest) (*PlayerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPlayersServer) Add(context.Context, *AddRequest) (*PlayerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedPlayersServer) Update(context.Context, *UpdateRequest) (*PlayerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedPlayersServer) Import(context.Context, *ImportRequest) (*ImportReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedPlayersServer) GetProfile(context.Context, *GetProfileRequest) (*ProfileReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProfile not implemented")
+}
+func (UnimplementedPlayersServer) mustEmbedUnimplementedPlayersServer() {}
+
+// UnsafePlayersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PlayersServer will
+// result in compilation errors.
+type UnsafePlayersServer interface {
+	mustEmbedUnimplementedPlayersServer()
+}
+
+func RegisterPlayersServer(s grpc.ServiceRegistrar, srv PlayersServer) {
+	s.RegisterService(&Players_ServiceDesc, srv)
+}
+
+func _Players_GetAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).GetAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/GetAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).GetAll(ctx, req.(*GetAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_GetByIds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetByIdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).GetByIds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/GetByIds",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).GetByIds(ctx, req.(*GetByIdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_Import_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).Import(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/Import",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).Import(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Players_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlayersServer).GetProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/playersvc.Players/GetProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlayersServer).GetProfile(ctx, req.(*GetProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Players_ServiceDesc is the grpc.ServiceDesc for Players service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Players_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "playersvc.Players",
+	HandlerType: (*PlayersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAll",
+			Handler:    _Players_GetAll_Handler,
+		},
+		{
+			MethodName: "GetByIds",
+			Handler:    _Players_GetByIds_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Players_Get_Handler,
+		},
+		{
+			MethodName: "Add",
+			Handler:    _Players_Add_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Players_Update_Handler,
+		},
+		{
+			MethodName: "Import",
+			Handler:    _Players_Import_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _Players_GetProfile_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "playersvc.proto",
+}
@@ -0,0 +1,109 @@
+package playersvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+)
+
+// Profile is everything a player usually asks about at the counter, gathered
+// from playersvc, ticketsvc and the spinsvc history. spinsvc keeps its
+// history in memory, so attendance, wins and the next spin's field only
+// cover the spins since spinsvc last started.
+type Profile struct {
+	Player     Player     `json:"player"`
+	Tickets    int        `json:"tickets"`
+	Attendance int        `json:"attendance"`
+	Wins       int        `json:"wins"`
+	LastWin    *time.Time `json:"lastWin,omitempty"`
+	NextOdds   float64    `json:"nextOdds"`
+}
+
+func (p Profile) String() string {
+	return fmt.Sprintf("{player: %v, tickets: %v, attendance: %v, wins: %v, nextOdds: %.3f}",
+		p.Player, p.Tickets, p.Attendance, p.Wins, p.NextOdds)
+}
+
+func (s *playerService) GetProfile(ctx context.Context, id int) (Profile, error) {
+	s.mtx.RLock()
+	player, ok := s.players[id]
+	s.mtx.RUnlock()
+	if !ok {
+		return Profile{}, apierror.WithDetails(ErrPlayerDoesNotExist, map[string]string{"id": strconv.Itoa(id)})
+	}
+	profile := Profile{Player: player}
+
+	history, err := s.spins.GetHistory(ctx, id)
+	if err != nil {
+		return Profile{}, err
+	}
+	for _, spin := range history {
+		// a voided spin still took place, and its participants keep the
+		// ticket they got for it, but nobody won it
+		profile.Attendance++
+		if spin.WinnerId == id && !spin.Voided {
+			profile.Wins++
+			t := spin.Time
+			profile.LastWin = &t
+		}
+	}
+
+	field, err := s.nextEventField(ctx, id)
+	if err != nil {
+		return Profile{}, err
+	}
+	tickets, err := s.tickets.Get(ctx, field...)
+	if err != nil {
+		return Profile{}, err
+	}
+	counts := make(map[int]int, len(tickets))
+	for _, t := range tickets {
+		counts[t.Id] = t.Tickets
+	}
+
+	// every participant gets a ticket for showing up before the wheel is
+	// spun, so the odds are computed on the incremented counts
+	total := 0
+	for _, p := range field {
+		total += counts[p] + 1
+	}
+	profile.Tickets = counts[id]
+	profile.NextOdds = float64(profile.Tickets+1) / float64(total)
+
+	return profile, nil
+}
+
+// nextEventField guesses who will take part in the next spin: the
+// participants of the most recent spin, or every active player if there
+// hasn't been one yet. The player asking is always included.
+func (s *playerService) nextEventField(ctx context.Context, id int) ([]int, error) {
+	last, err := s.spins.GetLast(ctx)
+	if err != nil && !errors.Is(err, spinsvc.ErrNoSpin) {
+		return nil, err
+	}
+
+	var field []int
+	if err == nil {
+		field = append(field, last.ParticipantIds...)
+	} else {
+		s.mtx.RLock()
+		for _, p := range s.players {
+			if !p.Inactive {
+				field = append(field, p.Id)
+			}
+		}
+		s.mtx.RUnlock()
+	}
+
+	for _, p := range field {
+		if p == id {
+			return field, nil
+		}
+	}
+	return append(field, id), nil
+}
@@ -0,0 +1,81 @@
+package playersvc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/events"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+// services is a playersvc over in-memory ticketsvc and spinsvc, as the
+// single binary wires them.
+type services struct {
+	players Service
+	tickets ticketsvc.Service
+	spins   spinsvc.Service
+}
+
+func newServices(t *testing.T) services {
+	t.Helper()
+	logger := log.NewNopLogger()
+	ledger, err := ticketsvc.OpenLedger(ticketsvc.DefaultStorage(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := events.NewLocal(logger)
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	var s services
+	s.tickets = ticketsvc.NewService(logger, ledger)
+	s.spins = spinsvc.NewService(logger, s.tickets, spinsvc.DefaultPolicy(), bus, spinsvc.NewHistory())
+	s.players = NewService(logger, s.tickets, s.spins)
+	return s
+}
+
+func (s services) add(t *testing.T, names ...string) []Player {
+	t.Helper()
+	players := make([]Player, len(names))
+	for i, name := range names {
+		p, err := s.players.Add(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		players[i] = p
+	}
+	return players
+}
+
+func TestProfileSkipsVoidedWins(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	ann := s.add(t, "Ann")[0]
+
+	won, err := s.spins.Spin(ctx, []int{ann.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	voided, err := s.spins.Spin(ctx, []int{ann.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.spins.Void(ctx, voided.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := s.players.GetProfile(ctx, ann.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Attendance != 2 {
+		t.Errorf("attendance = %d, want the voided spin counted too", profile.Attendance)
+	}
+	if profile.Wins != 1 {
+		t.Errorf("wins = %d, want only the spin that wasn't voided", profile.Wins)
+	}
+	if profile.LastWin == nil || !profile.LastWin.Equal(won.Time) {
+		t.Errorf("last win = %v, want %v", profile.LastWin, won.Time)
+	}
+}
@@ -0,0 +1,225 @@
+package playersvc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var (
+	ErrRosterMissingName = apierror.New("players.roster_missing_name", "roster has no name column")
+)
+
+// RosterEntry is a single row of an event roster export.
+type RosterEntry struct {
+	Line       int    `json:"line,omitempty"`
+	ExternalId string `json:"externalId,omitempty"`
+	Name       string `json:"name"`
+}
+
+// ImportRow reports what happened to a single roster entry during an import.
+// Player is the created or matched player; Candidates lists the players an
+// ambiguous entry could refer to.
+type ImportRow struct {
+	Entry      RosterEntry `json:"entry"`
+	Player     *Player     `json:"player,omitempty"`
+	Candidates []Player    `json:"candidates,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun    bool        `json:"dryRun"`
+	Created   []ImportRow `json:"created"`
+	Matched   []ImportRow `json:"matched"`
+	Ambiguous []ImportRow `json:"ambiguous"`
+	Invalid   []ImportRow `json:"invalid"`
+}
+
+func (r ImportReport) String() string {
+	return fmt.Sprintf("{dryRun: %v, created: %v, matched: %v, ambiguous: %v, invalid: %v}",
+		r.DryRun, len(r.Created), len(r.Matched), len(r.Ambiguous), len(r.Invalid))
+}
+
+// importRoster matches each entry to an existing player by external id, then
+// by name, creating players for entries that match nothing. Callers must hold
+// the write lock.
+func (s *playerService) importRoster(entries []RosterEntry) ImportReport {
+	report := ImportReport{
+		Created:   []ImportRow{},
+		Matched:   []ImportRow{},
+		Ambiguous: []ImportRow{},
+		Invalid:   []ImportRow{},
+	}
+
+	for _, entry := range entries {
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.ExternalId = strings.TrimSpace(entry.ExternalId)
+		row := ImportRow{Entry: entry}
+
+		if entry.Name == "" && entry.ExternalId == "" {
+			row.Reason = "row has neither a name nor an external id"
+			report.Invalid = append(report.Invalid, row)
+			continue
+		}
+
+		if entry.ExternalId != "" {
+			if p, ok := s.findByExternalId(entry.ExternalId); ok {
+				row.Player = &p
+				report.Matched = append(report.Matched, row)
+				continue
+			}
+		}
+
+		if entry.Name == "" {
+			row.Reason = "unknown external id and no name to create a player with"
+			report.Invalid = append(report.Invalid, row)
+			continue
+		}
+
+		candidates := s.findByName(entry.Name)
+		switch len(candidates) {
+		case 0:
+			p := s.add(Player{Name: entry.Name, ExternalId: entry.ExternalId})
+			row.Player = &p
+			report.Created = append(report.Created, row)
+		case 1:
+			p := candidates[0]
+			if entry.ExternalId != "" && p.ExternalId != "" {
+				// same name, but the export says it's a different person
+				row.Candidates = candidates
+				row.Reason = "name matches a player with a different external id"
+				report.Ambiguous = append(report.Ambiguous, row)
+				continue
+			}
+			if entry.ExternalId != "" {
+				p.ExternalId = entry.ExternalId
+				s.players[p.Id] = p
+			}
+			row.Player = &p
+			report.Matched = append(report.Matched, row)
+		default:
+			row.Candidates = candidates
+			row.Reason = "name matches more than one player"
+			report.Ambiguous = append(report.Ambiguous, row)
+		}
+	}
+
+	return report
+}
+
+func (s *playerService) findByExternalId(externalId string) (Player, bool) {
+	for _, p := range s.players {
+		if p.ExternalId == externalId {
+			return p, true
+		}
+	}
+	return Player{}, false
+}
+
+func (s *playerService) findByName(name string) (players []Player) {
+	for _, p := range s.players {
+		if strings.EqualFold(strings.TrimSpace(p.Name), name) {
+			players = append(players, p)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Id < players[j].Id })
+	return
+}
+
+// ParseRosterJSON reads a roster export that is either a JSON array of
+// entries or an object with an "entries" array.
+func ParseRosterJSON(r io.Reader) ([]RosterEntry, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var entries []RosterEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		var wrapped struct {
+			Entries []RosterEntry `json:"entries"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, err
+		}
+		entries = wrapped.Entries
+	}
+
+	for i := range entries {
+		if entries[i].Line == 0 {
+			entries[i].Line = i + 1
+		}
+	}
+	return entries, nil
+}
+
+// ParseRosterCSV reads a roster export with a header row. Columns are matched
+// case-insensitively; the name may be given as a single column or split into
+// first and last name columns.
+func ParseRosterCSV(r io.Reader) ([]RosterEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		idCol    = findColumn(header, "external id", "externalid", "external_id", "gem id", "player id", "id")
+		nameCol  = findColumn(header, "name", "player name", "player")
+		firstCol = findColumn(header, "first name", "firstname", "first_name")
+		lastCol  = findColumn(header, "last name", "lastname", "last_name")
+	)
+	if nameCol < 0 && firstCol < 0 && lastCol < 0 {
+		return nil, ErrRosterMissingName
+	}
+
+	var entries []RosterEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := RosterEntry{Line: line, ExternalId: field(record, idCol)}
+		if nameCol >= 0 {
+			entry.Name = field(record, nameCol)
+		} else {
+			entry.Name = strings.TrimSpace(field(record, firstCol) + " " + field(record, lastCol))
+		}
+		if entry.Name == "" && entry.ExternalId == "" {
+			// blank line in the export
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func findColumn(header []string, names ...string) int {
+	for _, name := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func field(record []string, col int) string {
+	if col < 0 || col >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[col])
+}
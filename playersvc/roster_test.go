@@ -0,0 +1,160 @@
+package playersvc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// outcomes gives what the import did with each roster line, and the player
+// each created or matched line ended up as.
+func outcomes(report ImportReport) (map[int]string, map[int]int) {
+	what, players := map[int]string{}, map[int]int{}
+	for outcome, rows := range map[string][]ImportRow{
+		"created":   report.Created,
+		"matched":   report.Matched,
+		"ambiguous": report.Ambiguous,
+		"invalid":   report.Invalid,
+	} {
+		for _, row := range rows {
+			what[row.Entry.Line] = outcome
+			if row.Player != nil {
+				players[row.Entry.Line] = row.Player.Id
+			}
+		}
+	}
+	return what, players
+}
+
+func TestImportRoster(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	existing := s.add(t, "Ann Lee", "Bo Diaz", "Sam Fox", "Sam Fox", "Cy Park")
+	ann, bo, cy := existing[0], existing[1], existing[4]
+	ann.ExternalId = "G-1"
+	cy.ExternalId = "G-5"
+	for _, p := range []Player{ann, cy} {
+		if _, err := s.players.Update(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := s.players.Import(ctx, []RosterEntry{
+		// the external id wins over a name that has changed since
+		{Line: 1, ExternalId: "G-1", Name: "Ann Lee-Moore"},
+		// a name alone matches, case and spacing aside, and the row's
+		// external id is kept for next time
+		{Line: 2, ExternalId: "G-2", Name: "  bo diaz "},
+		{Line: 3, Name: "Sam Fox"},
+		// the name is Cy's, but the export says it's someone else
+		{Line: 4, ExternalId: "G-9", Name: "Cy Park"},
+		{Line: 5, ExternalId: "G-6", Name: "Dee Ng"},
+		// the same person twice in one export is created once
+		{Line: 6, ExternalId: "G-6", Name: "Dee Ng"},
+		{Line: 7, Name: "Eve Ruiz"},
+		{Line: 8, Name: "eve ruiz"},
+		{Line: 9},
+		{Line: 10, ExternalId: "G-404"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	what, players := outcomes(report)
+	wantWhat := map[int]string{
+		1: "matched", 2: "matched", 3: "ambiguous", 4: "ambiguous", 5: "created",
+		6: "matched", 7: "created", 8: "matched", 9: "invalid", 10: "invalid",
+	}
+	if !reflect.DeepEqual(what, wantWhat) {
+		t.Errorf("outcomes = %v, want %v", what, wantWhat)
+	}
+	if players[1] != ann.Id || players[2] != bo.Id {
+		t.Errorf("lines 1 and 2 matched %d and %d, want %d and %d", players[1], players[2], ann.Id, bo.Id)
+	}
+	if players[6] != players[5] || players[8] != players[7] {
+		t.Errorf("repeated rows matched %v, want the players created for their first rows", players)
+	}
+	for _, row := range report.Ambiguous {
+		want := 2
+		if row.Entry.Line == 4 {
+			want = 1
+		}
+		if len(row.Candidates) != want || row.Reason == "" {
+			t.Errorf("ambiguous line %d = %v, want %d candidates and a reason", row.Entry.Line, row, want)
+		}
+	}
+
+	// the name match recorded the external id, so it matches by it now
+	got, _, err := s.players.GetByIds(ctx, bo.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].ExternalId != "G-2" {
+		t.Errorf("Bo's external id = %q, want G-2", got[0].ExternalId)
+	}
+	all, err := s.players.GetAll(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(existing)+2 {
+		t.Errorf("%d players after the import, want %d", len(all), len(existing)+2)
+	}
+}
+
+func TestImportRosterDryRun(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	s.add(t, "Ann Lee")
+	before, err := s.players.GetAll(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []RosterEntry{
+		{Line: 1, ExternalId: "G-1", Name: "Ann Lee"},
+		{Line: 2, Name: "Bo Diaz"},
+		{Line: 3, Name: "Bo Diaz"},
+	}
+	dry, err := s.players.Import(ctx, entries, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.players.GetAll(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("players after a dry run = %v, want them unchanged, %v", after, before)
+	}
+
+	// and it reports what the import then does
+	imported, err := s.players.Import(ctx, entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dryWhat, _ := outcomes(dry)
+	importedWhat, _ := outcomes(imported)
+	if !dry.DryRun || imported.DryRun || !reflect.DeepEqual(dryWhat, importedWhat) {
+		t.Errorf("dry run %v reported %v, the import %v did %v", dry.DryRun, dryWhat, imported.DryRun, importedWhat)
+	}
+}
+
+func TestParseRosterCSV(t *testing.T) {
+	entries, err := ParseRosterCSV(strings.NewReader("GEM ID,First Name,Last Name\nG-1, Ann ,Lee\n,,\nG-2,Bo,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RosterEntry{
+		{Line: 2, ExternalId: "G-1", Name: "Ann Lee"},
+		{Line: 4, ExternalId: "G-2", Name: "Bo"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+
+	if _, err := ParseRosterCSV(strings.NewReader("id,points\n1,3\n")); !errors.Is(err, ErrRosterMissingName) {
+		t.Errorf("roster without names err = %v, want %v", err, ErrRosterMissingName)
+	}
+}
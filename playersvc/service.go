@@ -2,25 +2,37 @@ package playersvc
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
 )
 
 var (
-	ErrPlayerDoesNotExist = errors.New("player does not exist")
+	ErrPlayerDoesNotExist = apierror.New("players.not_found", "player does not exist")
 )
 
 type Service interface {
 	Add(ctx context.Context, name string) (Player, error)
-	GetAll(ctx context.Context) ([]Player, error)
+	GetAll(ctx context.Context, opts ListOptions) ([]Player, error)
+	GetByIds(ctx context.Context, ids ...int) (players []Player, notFound []int, err error)
 	Update(ctx context.Context, player Player) (Player, error)
+	Import(ctx context.Context, entries []RosterEntry, dryRun bool) (ImportReport, error)
+	GetProfile(ctx context.Context, id int) (Profile, error)
 }
 
 type Player struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id         int       `json:"id"`
+	Name       string    `json:"name"`
+	ExternalId string    `json:"externalId,omitempty"`
+	Inactive   bool      `json:"inactive,omitempty"`
+	Created    time.Time `json:"created"`
 }
 
 func (t Player) String() string {
@@ -28,36 +40,107 @@ func (t Player) String() string {
 }
 
 type playerService struct {
-	players map[int]string
+	mtx     sync.RWMutex
+	players map[int]Player
+	nextId  int
 	logger  log.Logger
+	tickets ticketsvc.Service
+	spins   spinsvc.Service
 }
 
-func NewService(logger log.Logger) Service {
+func NewService(logger log.Logger, ticketService ticketsvc.Service, spinService spinsvc.Service) Service {
 	return &playerService{
-		players: make(map[int]string),
+		players: make(map[int]Player),
 		logger:  logger,
+		tickets: ticketService,
+		spins:   spinService,
 	}
 }
 
 func (s *playerService) Add(ctx context.Context, name string) (Player, error) {
-	idx := len(s.players)
-	s.players[idx] = name
-	return Player{Id: idx, Name: name}, nil
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.add(Player{Name: name}), nil
 }
 
-func (s *playerService) GetAll(ctx context.Context) (players []Player, err error) {
-	players = make([]Player, len(s.players))
-	for id, name := range s.players {
-		players = append(players, Player{Id: id, Name: name})
+func (s *playerService) GetAll(ctx context.Context, opts ListOptions) ([]Player, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	players := make([]Player, 0, len(s.players))
+	for _, p := range s.players {
+		if p.Inactive && !opts.IncludeInactive {
+			continue
+		}
+		players = append(players, p)
 	}
-	return
+	s.mtx.RUnlock()
+
+	sortPlayers(players, opts.SortBy, opts.Descending)
+	return paginate(players, opts.Offset, opts.Limit), nil
 }
 
-func (s *playerService) Update(ctx context.Context, player Player) (Player, error) {
+// GetByIds returns the players with the given ids in the order requested.
+// Ids with no player are returned in notFound rather than as an error.
+func (s *playerService) GetByIds(ctx context.Context, ids ...int) (players []Player, notFound []int, err error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	players = []Player{}
+	notFound = []int{}
+	for _, id := range ids {
+		if p, ok := s.players[id]; ok {
+			players = append(players, p)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return players, notFound, nil
+}
 
-	if _, ok := s.players[player.Id]; !ok {
-		return Player{}, ErrPlayerDoesNotExist
+func (s *playerService) Update(ctx context.Context, player Player) (Player, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	existing, ok := s.players[player.Id]
+	if !ok {
+		return Player{}, apierror.WithDetails(ErrPlayerDoesNotExist, map[string]string{"id": strconv.Itoa(player.Id)})
 	}
-	s.players[player.Id] = player.Name
+	player.Created = existing.Created
+	s.players[player.Id] = player
 	return player, nil
 }
+
+func (s *playerService) Import(ctx context.Context, entries []RosterEntry, dryRun bool) (ImportReport, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// match against a copy so that a dry run reports exactly what a real
+	// import would do, including rows that match players created earlier
+	// in the same roster
+	staged := &playerService{
+		players: make(map[int]Player, len(s.players)),
+		nextId:  s.nextId,
+	}
+	for id, p := range s.players {
+		staged.players[id] = p
+	}
+
+	report := staged.importRoster(entries)
+	report.DryRun = dryRun
+	if !dryRun {
+		s.players = staged.players
+		s.nextId = staged.nextId
+	}
+	return report, nil
+}
+
+// add stores a new player under the next free id. Callers must hold the
+// write lock.
+func (s *playerService) add(player Player) Player {
+	player.Id = s.nextId
+	player.Created = time.Now().UTC()
+	s.nextId++
+	s.players[player.Id] = player
+	return player
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+	"github.com/jlthompson3259/matspinner/spinsvc"
+)
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	Sessions       config.Sessions   `yaml:"sessions"`
+	GRPC           config.GRPC       `yaml:"grpc"`
+	Upstreams      upstreams         `yaml:"upstreams"`
+	Client         upstream.Settings `yaml:"client"`
+	Spin           spinsvc.Policy    `yaml:"spin"`
+}
+
+type upstreams struct {
+	TicketSvc          string `yaml:"ticketsvc" env:"TICKETSVC_URL" flag:"ticketsvc-url" help:"ticketsvc base URL, comma-separated URLs, or a dns:// or dns+srv:// name"`
+	TicketSvcTransport string `yaml:"ticketsvcTransport" env:"TICKETSVC_TRANSPORT" flag:"ticketsvc-transport" help:"how to call ticketsvc: http, or grpc with host:port addresses in the ticketsvc URL"`
+}
+
+func (u *upstreams) Validate() error {
+	if u.TicketSvc == "" {
+		return config.ErrMissingUpstream
+	}
+	return config.ValidateTransport(u.TicketSvcTransport)
+}
+
+func (s *settings) Validate() error {
+	return s.Sessions.Required(s.Auth)
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:   config.DefaultService(":8086"),
+		Sessions:  config.DefaultSessions(),
+		GRPC:      config.GRPC{Addr: ":9086"},
+		Upstreams: upstreams{TicketSvc: "http://ticketsvc:8085", TicketSvcTransport: config.TransportHTTP},
+		Client:    upstream.DefaultSettings(),
+		Spin:      spinsvc.DefaultPolicy(),
+	}
+}
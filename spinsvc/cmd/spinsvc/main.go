@@ -1,70 +1,174 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 	"github.com/jlthompson3259/matspinner/spinsvc"
+	"github.com/jlthompson3259/matspinner/spinsvc/pb"
 	"github.com/jlthompson3259/matspinner/ticketsvc"
-)
-
-const (
-	defaultHttpPort = "8086"
+	"github.com/jlthompson3259/matspinner/usersvc"
 )
 
 func main() {
-	var (
-		httpAddr = net.JoinHostPort("0.0.0.0", envString("HTTP_PORT", defaultHttpPort))
-	)
+	cfg := defaultSettings()
+	if err := config.Load("spinsvc", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	var logger log.Logger
-	{
-		logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-		logger = level.NewFilter(logger, level.AllowInfo(), level.SquelchNoLevel(true))
-		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("spinsvc", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	sessions, err := usersvc.MakeClientEndpoints(cfg.Sessions.URL, cfg.Auth.Forward())
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, stopSessions := cfg.Sessions.Watch(cfg.Auth, authenticator, sessions.Ended, logger)
+	bus, err := cfg.Events.NewBus("spinsvc", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
 	}
 
-	ticketService, err := ticketsvc.MakeClientEndpoints("http://ticketsvc:8085")
+	breakerState := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "matspinner",
+		Subsystem: "spinsvc",
+		Name:      "upstream_breaker_state",
+		Help:      "Circuit breaker state per upstream instance: 0 closed, 1 half-open, 2 open.",
+	}, []string{"upstream", "instance"})
+	var (
+		tickets       *upstream.Upstream
+		ticketService ticketsvc.EndpointSet
+	)
+	if cfg.Upstreams.TicketSvcTransport == config.TransportGRPC {
+		tickets, err = upstream.New("ticketsvc", cfg.Upstreams.TicketSvc, cfg.Client, breakerState, logger, upstream.WithProbe(health.ReachableGRPC))
+		if err == nil {
+			ticketService = ticketsvc.MakeBalancedGRPCClientEndpoints(tickets, cfg.Auth.ForwardGRPC())
+		}
+	} else {
+		tickets, err = upstream.New("ticketsvc", cfg.Upstreams.TicketSvc, cfg.Client, breakerState, logger)
+		if err == nil {
+			ticketService = ticketsvc.MakeBalancedClientEndpoints(tickets, cfg.Auth.Forward())
+		}
+	}
 	if err != nil {
 		level.Error(logger).Log("error", err)
+		os.Exit(1)
 	}
 
-	var service spinsvc.Service
+	var (
+		service   spinsvc.Service
+		drainer   = spinsvc.NewDrainer()
+		readiness = []health.Check{tickets.Check()}
+	)
 	{
-		service = spinsvc.NewService(log.With(logger, "component", "service"), &ticketService)
+		fieldKeys := []string{"method"}
+		requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "spinsvc",
+			Name:      "requests_total",
+			Help:      "Number of requests received.",
+		}, fieldKeys)
+		errorCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "spinsvc",
+			Name:      "errors_total",
+			Help:      "Number of requests that failed.",
+		}, fieldKeys)
+		requestLatency := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: "matspinner",
+			Subsystem: "spinsvc",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling requests, in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, fieldKeys)
+		spins := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "spinsvc",
+			Name:      "spins_total",
+			Help:      "Number of completed spins.",
+		}, []string{"weighted"})
+		ticketsAtWin := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: "matspinner",
+			Subsystem: "spinsvc",
+			Name:      "tickets_at_win",
+			Help:      "Tickets the winner held when they won; sum over count is the average.",
+			Buckets:   []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20},
+		}, []string{})
+
+		history := spinsvc.NewHistory()
+		base := spinsvc.NewService(log.With(logger, "component", "service"), &ticketService, cfg.Spin, bus, history)
+		readiness = append(readiness, health.Check{Name: "storage", Check: func(ctx context.Context) error {
+			_, err := base.GetHistory(ctx)
+			return err
+		}})
+		if err := spinsvc.RecordHistory(bus, history); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		if err := spinsvc.CountSpins(bus, spins, ticketsAtWin); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		service = drainer.Middleware(base)
+		service = spinsvc.InstrumentingMiddleware(requestCount, errorCount, requestLatency)(service)
 		service = spinsvc.LoggingMiddleware(log.With(logger, "component", "loggingMiddleware"))(service)
 	}
 
 	var (
 		endpoints   = spinsvc.MakeServerEndpoints(service)
-		httpHandler = spinsvc.MakeHTTPHandler(endpoints, log.With(logger, "component", "http"))
+		httpHandler = spinsvc.MakeHTTPHandler(endpoints, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
 	)
 
-	errs := make(chan error)
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		errs <- fmt.Errorf("%s", <-c)
-	}()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/", httpHandler)
 
-	go func() {
-		level.Info(logger).Log("transport", "HTTP", "addr", httpAddr)
-		errs <- http.ListenAndServe(httpAddr, httpHandler)
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	level.Error(logger).Log("exit", <-errs)
-}
-
-func envString(env, fallback string) string {
-	e := os.Getenv(env)
-	if e == "" {
-		return fallback
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnDrain:         []server.Hook{drainer.Close},
+		OnClose:         []server.Hook{stopSessions, bus.Close, tickets.Close, shutdownTracing},
+	}
+	if cfg.GRPC.Addr != "" {
+		srv.GRPC, srv.GRPCAddr = server.NewGRPC(), cfg.GRPC.Addr
+		pb.RegisterSpinsServer(srv.GRPC, spinsvc.MakeGRPCServer(endpoints, authenticator, log.With(logger, "component", "grpc")))
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
 	}
-	return e
+	level.Info(logger).Log("exit", "stopped")
 }
@@ -0,0 +1,101 @@
+package spinsvc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var ErrShuttingDown = apierror.New("spins.shutting_down", "shutting down, not accepting new spins")
+
+// Drainer refuses new spins and voids once it is closed, and lets Close wait
+// for the ones already in progress. A spin's winner is drawn before its
+// tickets are recorded, so stopping between them would lose a spin the
+// caller was never told about.
+type Drainer struct {
+	mtx      sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Middleware is the ServiceMiddleware that tracks spins. Install it
+// innermost, directly around NewService.
+func (d *Drainer) Middleware(next Service) Service {
+	return &drainingMiddleware{next: next, drainer: d}
+}
+
+// Close stops new spins and waits for the ones in progress, or for ctx to
+// expire.
+func (d *Drainer) Close(ctx context.Context) error {
+	d.mtx.Lock()
+	d.closed = true
+	d.mtx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Drainer) start() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.closed {
+		return ErrShuttingDown
+	}
+	d.inflight.Add(1)
+	return nil
+}
+
+type drainingMiddleware struct {
+	next    Service
+	drainer *Drainer
+}
+
+func (mw *drainingMiddleware) Spin(ctx context.Context, participantIds []int) (SpinResult, error) {
+	if err := mw.drainer.start(); err != nil {
+		return SpinResult{}, err
+	}
+	defer mw.drainer.inflight.Done()
+	return mw.next.Spin(ctx, participantIds)
+}
+
+func (mw *drainingMiddleware) SpinUnweighted(ctx context.Context, participantIds []int) (SpinResult, error) {
+	if err := mw.drainer.start(); err != nil {
+		return SpinResult{}, err
+	}
+	defer mw.drainer.inflight.Done()
+	return mw.next.SpinUnweighted(ctx, participantIds)
+}
+
+func (mw *drainingMiddleware) Get(ctx context.Context, id int) (SpinResult, error) {
+	return mw.next.Get(ctx, id)
+}
+
+func (mw *drainingMiddleware) GetLast(ctx context.Context) (SpinResult, error) {
+	return mw.next.GetLast(ctx)
+}
+
+func (mw *drainingMiddleware) GetHistory(ctx context.Context, participantIds ...int) ([]SpinResult, error) {
+	return mw.next.GetHistory(ctx, participantIds...)
+}
+
+func (mw *drainingMiddleware) Void(ctx context.Context, id int) (SpinResult, error) {
+	if err := mw.drainer.start(); err != nil {
+		return SpinResult{}, err
+	}
+	defer mw.drainer.inflight.Done()
+	return mw.next.Void(ctx, id)
+}
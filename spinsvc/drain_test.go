@@ -0,0 +1,81 @@
+package spinsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stuckSpins is a Service whose spins wait until release is closed.
+type stuckSpins struct {
+	Service
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *stuckSpins) Spin(context.Context, []int) (SpinResult, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return SpinResult{Id: 1}, nil
+}
+
+func (s *stuckSpins) Void(_ context.Context, id int) (SpinResult, error) {
+	return SpinResult{Id: id, Voided: true}, nil
+}
+
+func TestDrainerWaitsForSpinsInProgress(t *testing.T) {
+	ctx := context.Background()
+	next := &stuckSpins{started: make(chan struct{}), release: make(chan struct{})}
+	drainer := NewDrainer()
+	s := drainer.Middleware(next)
+
+	spun := make(chan error, 1)
+	go func() {
+		_, err := s.Spin(ctx, []int{1, 2})
+		spun <- err
+	}()
+	<-next.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- drainer.Close(ctx) }()
+	// once closing has begun, new spins and voids are refused
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		if _, err := s.Void(ctx, 1); errors.Is(err, ErrShuttingDown) {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("Void still accepted after Close")
+		}
+	}
+	if _, err := s.Spin(ctx, []int{1, 2}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Spin while closing err = %v, want %v", err, ErrShuttingDown)
+	}
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v with a spin in progress", err)
+	default:
+	}
+
+	close(next.release)
+	if err := <-spun; err != nil {
+		t.Errorf("spin in progress at Close: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close = %v, want nil once the spin finished", err)
+	}
+}
+
+func TestDrainerCloseGivesUp(t *testing.T) {
+	next := &stuckSpins{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(next.release)
+	drainer := NewDrainer()
+	go drainer.Middleware(next).Spin(context.Background(), []int{1})
+	<-next.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := drainer.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -2,26 +2,37 @@ package spinsvc
 
 import (
 	"context"
+	"io"
 	"net/url"
 	"strings"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
 	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 )
 
 type EndpointSet struct {
-	SpinEndpoint    endpoint.Endpoint
-	GetLastEndpoint endpoint.Endpoint
+	SpinEndpoint       endpoint.Endpoint
+	GetEndpoint        endpoint.Endpoint
+	GetLastEndpoint    endpoint.Endpoint
+	GetHistoryEndpoint endpoint.Endpoint
+	VoidEndpoint       endpoint.Endpoint
 }
 
 func MakeServerEndpoints(svc Service) EndpointSet {
 	return EndpointSet{
-		SpinEndpoint:    MakeSpinEndpoint(svc),
-		GetLastEndpoint: MakeGetLastEndpoint(svc),
+		SpinEndpoint:       MakeSpinEndpoint(svc),
+		GetEndpoint:        MakeGetEndpoint(svc),
+		GetLastEndpoint:    MakeGetLastEndpoint(svc),
+		GetHistoryEndpoint: MakeGetHistoryEndpoint(svc),
+		VoidEndpoint:       MakeVoidEndpoint(svc),
 	}
 }
 
-func MakeClientEndpoints(instance string) (EndpointSet, error) {
+func MakeClientEndpoints(instance string, options ...httptransport.ClientOption) (EndpointSet, error) {
 	if !strings.HasPrefix(instance, "http") {
 		instance = "http://" + instance
 	}
@@ -31,17 +42,40 @@ func MakeClientEndpoints(instance string) (EndpointSet, error) {
 	}
 
 	tgt.Path = ""
-
-	options := []httptransport.ClientOption{}
+	options = append([]httptransport.ClientOption{httptransport.ClientBefore(tracing.ContextToHTTP)}, options...)
 
 	return EndpointSet{
-		SpinEndpoint:    httptransport.NewClient("POST", tgt, encodeSpinRequest, decodeResponse, options...).Endpoint(),
-		GetLastEndpoint: httptransport.NewClient("GET", tgt, encodeGetLastRequest, decodeResponse, options...).Endpoint(),
+		SpinEndpoint:       tracing.TraceClient("spinsvc.Spin")(httptransport.NewClient("POST", tgt, encodeSpinRequest, decodeResponse, options...).Endpoint()),
+		GetEndpoint:        tracing.TraceClient("spinsvc.Get")(httptransport.NewClient("GET", tgt, encodeGetRequest, decodeResponse, options...).Endpoint()),
+		GetLastEndpoint:    tracing.TraceClient("spinsvc.GetLast")(httptransport.NewClient("GET", tgt, encodeGetLastRequest, decodeResponse, options...).Endpoint()),
+		GetHistoryEndpoint: tracing.TraceClient("spinsvc.GetHistory")(httptransport.NewClient("GET", tgt, encodeGetHistoryRequest, decodeHistoryResponse, options...).Endpoint()),
+		VoidEndpoint:       tracing.TraceClient("spinsvc.Void")(httptransport.NewClient("POST", tgt, encodeVoidRequest, decodeResponse, options...).Endpoint()),
 	}, nil
 }
 
+// MakeBalancedClientEndpoints makes client endpoints that spread calls over
+// u's instances. Spin and Void aren't safe to retry.
+func MakeBalancedClientEndpoints(u *upstream.Upstream, options ...httptransport.ClientOption) EndpointSet {
+	factory := func(method func(EndpointSet) endpoint.Endpoint) sd.Factory {
+		return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+			e, err := MakeClientEndpoints(instance, options...)
+			if err != nil {
+				return nil, nil, err
+			}
+			return method(e), nil, nil
+		}
+	}
+	return EndpointSet{
+		SpinEndpoint:       u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.SpinEndpoint }), false),
+		GetEndpoint:        u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetEndpoint }), true),
+		GetLastEndpoint:    u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetLastEndpoint }), true),
+		GetHistoryEndpoint: u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetHistoryEndpoint }), true),
+		VoidEndpoint:       u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.VoidEndpoint }), false),
+	}
+}
+
 func (e *EndpointSet) Spin(ctx context.Context, participantids []int) (SpinResult, error) {
-	request := spinRequest{ParticipantIds: participantids, Unweighted: false}
+	request := spinRequest{ParticipantIds: nonNil(participantids), Unweighted: false}
 	r, err := e.SpinEndpoint(ctx, request)
 	if err != nil {
 		return SpinResult{}, err
@@ -51,7 +85,7 @@ func (e *EndpointSet) Spin(ctx context.Context, participantids []int) (SpinResul
 }
 
 func (e *EndpointSet) SpinUnweighted(ctx context.Context, participantids []int) (SpinResult, error) {
-	request := spinRequest{ParticipantIds: participantids, Unweighted: true}
+	request := spinRequest{ParticipantIds: nonNil(participantids), Unweighted: true}
 	r, err := e.SpinEndpoint(ctx, request)
 	if err != nil {
 		return SpinResult{}, err
@@ -60,6 +94,16 @@ func (e *EndpointSet) SpinUnweighted(ctx context.Context, participantids []int)
 	return resp.Result, nil
 }
 
+func (e *EndpointSet) Get(ctx context.Context, id int) (SpinResult, error) {
+	request := getRequest{Id: id}
+	r, err := e.GetEndpoint(ctx, request)
+	if err != nil {
+		return SpinResult{}, err
+	}
+	resp := r.(response)
+	return resp.Result, nil
+}
+
 func (e *EndpointSet) GetLast(ctx context.Context) (SpinResult, error) {
 	request := getLastRequest{}
 	r, err := e.GetLastEndpoint(ctx, request)
@@ -70,6 +114,26 @@ func (e *EndpointSet) GetLast(ctx context.Context) (SpinResult, error) {
 	return resp.Result, nil
 }
 
+func (e *EndpointSet) GetHistory(ctx context.Context, participantIds ...int) ([]SpinResult, error) {
+	request := getHistoryRequest{ParticipantIds: participantIds}
+	r, err := e.GetHistoryEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(historyResponse)
+	return resp.Results, nil
+}
+
+func (e *EndpointSet) Void(ctx context.Context, id int) (SpinResult, error) {
+	request := voidRequest{Id: id}
+	r, err := e.VoidEndpoint(ctx, request)
+	if err != nil {
+		return SpinResult{}, err
+	}
+	resp := r.(response)
+	return resp.Result, nil
+}
+
 func MakeSpinEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (r interface{}, err error) {
 		req := request.(spinRequest)
@@ -83,6 +147,14 @@ func MakeSpinEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+func MakeGetEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getRequest)
+		result, err := svc.Get(ctx, req.Id)
+		return response{result, err}, nil
+	}
+}
+
 func MakeGetLastEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		result, err := svc.GetLast(ctx)
@@ -90,17 +162,61 @@ func MakeGetLastEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+func MakeGetHistoryEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getHistoryRequest)
+		results, err := svc.GetHistory(ctx, req.ParticipantIds...)
+		return historyResponse{results, err}, nil
+	}
+}
+
+func MakeVoidEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(voidRequest)
+		result, err := svc.Void(ctx, req.Id)
+		return response{result, err}, nil
+	}
+}
+
 type spinRequest struct {
 	ParticipantIds []int `json:"participantIds"`
 	Unweighted     bool  `json:"unweighted"`
 }
 
+type getRequest struct {
+	Id int
+}
+
+type voidRequest struct {
+	Id int
+}
+
 type getLastRequest struct {
 }
 
+type getHistoryRequest struct {
+	ParticipantIds []int
+}
+
 type response struct {
 	Result SpinResult `json:"result,omitempty"`
-	Err    error      `json:"err,omitempty"`
+	Err    error      `json:"-"`
 }
 
 func (r response) error() error { return r.Err }
+
+type historyResponse struct {
+	Results []SpinResult `json:"results"`
+	Err     error        `json:"-"`
+}
+
+func (r historyResponse) error() error { return r.Err }
+
+// nonNil sends no participants as [], so the server answers
+// ErrNoParticipants rather than rejecting null.
+func nonNil(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
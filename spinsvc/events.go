@@ -0,0 +1,52 @@
+package spinsvc
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/jlthompson3259/matspinner/common/events"
+)
+
+const (
+	// EventSpinCompleted is published with a SpinCompleted for each spin,
+	// once its tickets have changed.
+	EventSpinCompleted = "spins.completed"
+
+	// EventSpinVoided is published with a SpinVoided when a spin is voided.
+	EventSpinVoided = "spins.voided"
+
+	// eventsSpins matches both, in one subscription so that a spin's void
+	// is never handled before the spin.
+	eventsSpins = "spins.*"
+)
+
+type SpinCompleted struct {
+	Spin     SpinResult `json:"spin"`
+	Weighted bool       `json:"weighted"`
+
+	// WinnerKeeps is what the winner's tickets are set to, from the policy
+	// at the time of the spin.
+	WinnerKeeps int `json:"winnerKeeps"`
+}
+
+type SpinVoided struct {
+	Spin        SpinResult `json:"spin"`
+	WinnerKeeps int        `json:"winnerKeeps"`
+}
+
+// CountSpins subscribes to completed spins, counting each in spins, labelled
+// "weighted", and observing the winner's tickets in ticketsAtWin. Every
+// instance of spinsvc shares the subscription, so each spin is counted once.
+func CountSpins(bus events.Bus, spins metrics.Counter, ticketsAtWin metrics.Histogram) error {
+	return bus.Subscribe(EventSpinCompleted, "spinsvc.metrics", func(_ context.Context, e events.Event) error {
+		var completed SpinCompleted
+		if err := e.Decode(&completed); err != nil {
+			return err
+		}
+		spins.With("weighted", strconv.FormatBool(completed.Weighted)).Add(1)
+		ticketsAtWin.Observe(float64(completed.Spin.WinnerTickets))
+		return nil
+	})
+}
@@ -0,0 +1,207 @@
+package spinsvc
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/transport"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/spinsvc/pb"
+)
+
+const grpcService = "spinsvc.Spins"
+
+type grpcServer struct {
+	pb.UnimplementedSpinsServer
+	spin       grpctransport.Handler
+	get        grpctransport.Handler
+	getLast    grpctransport.Handler
+	getHistory grpctransport.Handler
+}
+
+// MakeGRPCServer serves the endpoints over gRPC, with the same roles
+// required as over HTTP.
+func MakeGRPCServer(e EndpointSet, authn auth.Authenticator, logger log.Logger) pb.SpinsServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		grpctransport.ServerBefore(auth.GRPCToContext(authn)),
+	}
+	return &grpcServer{
+		spin:       grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.SpinEndpoint), decodeGRPCSpinRequest, encodeGRPCResponse, options...),
+		get:        grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetEndpoint), decodeGRPCGetRequest, encodeGRPCResponse, options...),
+		getLast:    grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetLastEndpoint), decodeGRPCGetLastRequest, encodeGRPCResponse, options...),
+		getHistory: grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetHistoryEndpoint), decodeGRPCGetHistoryRequest, encodeGRPCHistoryResponse, options...),
+	}
+}
+
+func (s *grpcServer) Spin(ctx context.Context, req *pb.SpinRequest) (*pb.SpinReply, error) {
+	_, rep, err := s.spin.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.SpinReply), nil
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.SpinReply, error) {
+	_, rep, err := s.get.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.SpinReply), nil
+}
+
+func (s *grpcServer) GetLast(ctx context.Context, req *pb.GetLastRequest) (*pb.SpinReply, error) {
+	_, rep, err := s.getLast.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.SpinReply), nil
+}
+
+func (s *grpcServer) GetHistory(ctx context.Context, req *pb.GetHistoryRequest) (*pb.HistoryReply, error) {
+	_, rep, err := s.getHistory.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.HistoryReply), nil
+}
+
+// MakeGRPCClientEndpoints makes client endpoints that call the service over
+// conn. Void isn't served over gRPC, so its endpoint is left nil.
+func MakeGRPCClientEndpoints(conn *grpc.ClientConn, options ...grpctransport.ClientOption) EndpointSet {
+	options = append([]grpctransport.ClientOption{grpctransport.ClientBefore(tracing.ContextToGRPC)}, options...)
+
+	return EndpointSet{
+		SpinEndpoint:       tracing.TraceClient("spinsvc.Spin")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Spin", encodeGRPCSpinRequest, decodeGRPCResponse, pb.SpinReply{}, options...).Endpoint())),
+		GetEndpoint:        tracing.TraceClient("spinsvc.Get")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Get", encodeGRPCGetRequest, decodeGRPCResponse, pb.SpinReply{}, options...).Endpoint())),
+		GetLastEndpoint:    tracing.TraceClient("spinsvc.GetLast")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetLast", encodeGRPCGetLastRequest, decodeGRPCResponse, pb.SpinReply{}, options...).Endpoint())),
+		GetHistoryEndpoint: tracing.TraceClient("spinsvc.GetHistory")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetHistory", encodeGRPCGetHistoryRequest, decodeGRPCHistoryResponse, pb.HistoryReply{}, options...).Endpoint())),
+	}
+}
+
+// grpcError turns err into a gRPC status with the code matching the HTTP
+// status the error is answered with.
+func grpcError(err error) error {
+	return apierror.GRPCStatus(codeFrom(err), err)
+}
+
+/** server decode/encode **/
+func decodeGRPCSpinRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SpinRequest)
+	return spinRequest{ParticipantIds: toInts(req.ParticipantIds), Unweighted: req.Unweighted}, nil
+}
+
+func decodeGRPCGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetRequest)
+	return getRequest{Id: int(req.Id)}, nil
+}
+
+func decodeGRPCGetLastRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return getLastRequest{}, nil
+}
+
+func decodeGRPCGetHistoryRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetHistoryRequest)
+	if len(req.ParticipantIds) == 0 {
+		return getHistoryRequest{}, nil
+	}
+	return getHistoryRequest{ParticipantIds: toInts(req.ParticipantIds)}, nil
+}
+
+// encodeGRPCResponse returns a business-logic error as the call's error,
+// where the HTTP transport would have encoded it in the response.
+func encodeGRPCResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(response)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.SpinReply{Result: toPBResult(r.Result)}, nil
+}
+
+func encodeGRPCHistoryResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(historyResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	results := make([]*pb.SpinResult, len(r.Results))
+	for i, result := range r.Results {
+		results[i] = toPBResult(result)
+	}
+	return &pb.HistoryReply{Results: results}, nil
+}
+
+/** client encode/decode **/
+func encodeGRPCSpinRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(spinRequest)
+	return &pb.SpinRequest{ParticipantIds: toInt64s(req.ParticipantIds), Unweighted: req.Unweighted}, nil
+}
+
+func encodeGRPCGetRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getRequest)
+	return &pb.GetRequest{Id: int64(req.Id)}, nil
+}
+
+func encodeGRPCGetLastRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.GetLastRequest{}, nil
+}
+
+func encodeGRPCGetHistoryRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getHistoryRequest)
+	return &pb.GetHistoryRequest{ParticipantIds: toInt64s(req.ParticipantIds)}, nil
+}
+
+func decodeGRPCResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.SpinReply)
+	return response{Result: fromPBResult(reply.Result)}, nil
+}
+
+func decodeGRPCHistoryResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.HistoryReply)
+	results := make([]SpinResult, len(reply.Results))
+	for i, result := range reply.Results {
+		results[i] = fromPBResult(result)
+	}
+	return historyResponse{Results: results}, nil
+}
+
+func toPBResult(r SpinResult) *pb.SpinResult {
+	return &pb.SpinResult{
+		Id:             int64(r.Id),
+		Time:           timestamppb.New(r.Time),
+		ParticipantIds: toInt64s(r.ParticipantIds),
+		WinnerId:       int64(r.WinnerId),
+		WinnerTickets:  int64(r.WinnerTickets),
+	}
+}
+
+func fromPBResult(r *pb.SpinResult) SpinResult {
+	return SpinResult{
+		Id:             int(r.GetId()),
+		Time:           r.GetTime().AsTime(),
+		ParticipantIds: toInts(r.GetParticipantIds()),
+		WinnerId:       int(r.GetWinnerId()),
+		WinnerTickets:  int(r.GetWinnerTickets()),
+	}
+}
+
+func toInt64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
+
+func toInts(ids []int64) []int {
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
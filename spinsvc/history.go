@@ -0,0 +1,115 @@
+package spinsvc
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/events"
+)
+
+// History is the record of past spins: the instance's own, and the other
+// instances' kept by RecordHistory from the events they publish.
+type History struct {
+	mtx   sync.RWMutex
+	spins []recordedSpin // by id
+}
+
+type recordedSpin struct {
+	SpinResult
+	winnerKeeps int
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// RecordHistory keeps h from the spins completed and voided on bus. Each
+// instance of spinsvc keeps its own history, so every instance gets every
+// event.
+func RecordHistory(bus events.Bus, h *History) error {
+	return bus.Subscribe(eventsSpins, "", func(_ context.Context, e events.Event) error {
+		switch e.Type {
+		case EventSpinCompleted:
+			var completed SpinCompleted
+			if err := e.Decode(&completed); err != nil {
+				return err
+			}
+			h.add(completed.Spin, completed.WinnerKeeps)
+		case EventSpinVoided:
+			var voided SpinVoided
+			if err := e.Decode(&voided); err != nil {
+				return err
+			}
+			h.void(voided.Spin.Id)
+		}
+		return nil
+	})
+}
+
+// find returns the index of the spin with the given id, or where it would
+// go. It must be called with the lock held.
+func (h *History) find(id int) (int, bool) {
+	i := sort.Search(len(h.spins), func(i int) bool { return h.spins[i].Id >= id })
+	return i, i < len(h.spins) && h.spins[i].Id == id
+}
+
+func (h *History) add(spin SpinResult, winnerKeeps int) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	i, found := h.find(spin.Id)
+	if found {
+		return
+	}
+	h.spins = append(h.spins, recordedSpin{})
+	copy(h.spins[i+1:], h.spins[i:])
+	h.spins[i] = recordedSpin{SpinResult: spin, winnerKeeps: winnerKeeps}
+}
+
+func (h *History) void(id int) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if i, found := h.find(id); found {
+		h.spins[i].Voided = true
+	}
+}
+
+func (h *History) get(id int) (SpinResult, int, error) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	i, found := h.find(id)
+	if !found {
+		return SpinResult{}, 0, apierror.WithDetails(ErrSpinNotFound, map[string]string{"id": strconv.Itoa(id)})
+	}
+	return h.spins[i].SpinResult, h.spins[i].winnerKeeps, nil
+}
+
+func (h *History) last() (SpinResult, error) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	if len(h.spins) == 0 {
+		return SpinResult{}, ErrNoSpin
+	}
+	return h.spins[len(h.spins)-1].SpinResult, nil
+}
+
+func (h *History) all(participantIds ...int) []SpinResult {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	history := []SpinResult{}
+	for _, spin := range h.spins {
+		if len(participantIds) == 0 {
+			history = append(history, spin.SpinResult)
+			continue
+		}
+		for _, id := range participantIds {
+			if spin.HasParticipant(id) {
+				history = append(history, spin.SpinResult)
+				break
+			}
+		}
+	}
+	return history
+}
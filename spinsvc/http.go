@@ -4,35 +4,84 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+)
+
+var (
+	ErrParsingIds = apierror.New("spins.parsing_ids", "error parsing ids, should be ints")
 )
 
-func MakeHTTPHandler(e EndpointSet, logger log.Logger) http.Handler {
+func MakeHTTPHandler(e EndpointSet, authn auth.Authenticator, crossOrigin cors.Policy, limits limit.Settings, logger log.Logger) http.Handler {
+	return cors.Handler(crossOrigin, limit.Handler(limits, authn, openapi.Serve(spec, makeRouter(e, authn, logger))))
+}
+
+// makeRouter routes the endpoints. Every route must be described by the
+// OpenAPI document, which the tests check.
+func makeRouter(e EndpointSet, authn auth.Authenticator, logger log.Logger) *mux.Router {
 	r := mux.NewRouter()
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.HTTPToContext(), auth.HTTPToContext(authn)),
+		httptransport.ServerFinalizer(tracing.ServerFinalizer),
 	}
 
-	r.Methods("POST").Path("/spin").Handler(httptransport.NewServer(
-		e.SpinEndpoint,
+	spin := httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.SpinEndpoint),
 		decodeSpinRequest,
 		encodeResponse,
 		options...,
-	))
-	r.Methods("PUT").Path("/get-last-spin").Handler(httptransport.NewServer(
-		e.GetLastEndpoint,
+	)
+	getLast := httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetLastEndpoint),
 		decodeGetLastRequest,
 		encodeResponse,
 		options...,
+	)
+
+	r.Methods("POST").Path("/spins").Handler(spin)
+	r.Methods("GET").Path("/spins/last").Handler(getLast)
+	r.Methods("GET").Path("/spins/{id:[0-9]+}").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetEndpoint),
+		decodeGetRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/spins").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetHistoryEndpoint),
+		decodeGetHistoryRequest,
+		encodeResponse,
+		options...,
 	))
+	r.Methods("POST").Path("/spins/{id:[0-9]+}/void").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.VoidEndpoint),
+		decodeVoidRequest,
+		encodeResponse,
+		options...,
+	))
+
+	// the routes from before /spins, kept for one release so that older
+	// clients and UIs keep working while they move over
+	r.Methods("POST").Path("/spin").Handler(deprecated("spins", spin))
+	r.Methods("PUT").Path("/get-last-spin").Handler(deprecated("spins/last", getLast))
 	return r
 }
 
@@ -40,15 +89,54 @@ func MakeHTTPHandler(e EndpointSet, logger log.Logger) http.Handler {
 func decodeSpinRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req spinRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+		return nil, limit.BodyError(err)
 	}
 	return req, nil
 }
 
+func decodeGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"id": mux.Vars(r)["id"]})
+	}
+	return getRequest{Id: id}, nil
+}
+
+func decodeVoidRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"id": mux.Vars(r)["id"]})
+	}
+	return voidRequest{Id: id}, nil
+}
+
 func decodeGetLastRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	return getLastRequest{}, nil
 }
 
+func decodeGetHistoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	if !q.Has("participantIds") {
+		return getHistoryRequest{}, nil
+	}
+	ids, err := decodeIdsQueryString(q.Get("participantIds"))
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"participantIds": q.Get("participantIds")})
+	}
+	return getHistoryRequest{ParticipantIds: ids}, nil
+}
+
+// deprecated marks the responses of a route that will be removed, pointing
+// at the one replacing it. successor is relative to the old route, so it also
+// resolves behind the gateway's /api prefix.
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
+
 // errorer is implemented by all concrete response types that may contain
 // errors. It allows us to change the HTTP response code without needing to
 // trigger an endpoint (transport-level) error. For more information, read the
@@ -76,24 +164,61 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(codeFrom(err))
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+	apierror.Encode(w, codeFrom(err), err)
+}
+
+func decodeIdsQueryString(idStr string) (ids []int, err error) {
+	strs := strings.Split(idStr, ",")
+	ids = make([]int, len(strs))
+	for i, v := range strs {
+		ids[i], err = strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func encodeIdsQueryString(ids []int) (idStr string) {
+	strs := make([]string, len(ids))
+	for i, v := range ids {
+		strs[i] = fmt.Sprint(v)
+	}
+	return strings.Join(strs, ",")
 }
 
 func codeFrom(err error) int {
-	switch err {
-	case ErrNoSpin, ErrNoTickets:
-		return http.StatusInternalServerError
+	switch apierror.Sentinel(err) {
+	case auth.ErrUnauthenticated, auth.ErrInvalidCredentials:
+		return http.StatusUnauthorized
+	case auth.ErrForbidden, auth.ErrWrongStore:
+		return http.StatusForbidden
+	case ErrParsingIds, ErrNoParticipants:
+		return http.StatusBadRequest
+	case ErrNoSpin, ErrSpinNotFound:
+		return http.StatusNotFound
+	case ErrNoTickets:
+		return http.StatusUnprocessableEntity
+	case ErrSpinVoided:
+		return http.StatusConflict
+	case ErrShuttingDown:
+		return http.StatusServiceUnavailable
+	case auth.ErrSessionsUnknown, upstream.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case upstream.ErrBadGateway:
+		return http.StatusBadGateway
+	case limit.ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
 	default:
-		return http.StatusInternalServerError
+		return apierror.StatusCode(err, http.StatusInternalServerError)
 	}
 }
 
 /** client encode/decode **/
 func decodeResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
 	var response response
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
@@ -101,13 +226,47 @@ func decodeResponse(ctx context.Context, resp *http.Response) (interface{}, erro
 	return response, nil
 }
 
+func decodeHistoryResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response historyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func encodeSpinRequest(ctx context.Context, req *http.Request, request interface{}) error {
-	req.URL.Path = "/spin"
+	req.URL.Path = "/spins"
 	return encodeRequest(ctx, req, request)
 }
 
+func encodeGetRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getRequest)
+	req.URL.Path = "/spins/" + strconv.Itoa(r.Id)
+	return nil
+}
+
+func encodeVoidRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(voidRequest)
+	req.URL.Path = "/spins/" + strconv.Itoa(r.Id) + "/void"
+	return nil
+}
+
 func encodeGetLastRequest(ctx context.Context, req *http.Request, request interface{}) error {
-	req.URL.Path = "/get-last-spin"
+	req.URL.Path = "/spins/last"
+	return nil
+}
+
+func encodeGetHistoryRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getHistoryRequest)
+	req.URL.Path = "/spins"
+	if len(r.ParticipantIds) > 0 {
+		q := req.URL.Query()
+		q.Set("participantIds", encodeIdsQueryString(r.ParticipantIds))
+		req.URL.RawQuery = q.Encode()
+	}
 	return nil
 }
 
@@ -120,6 +279,7 @@ func encodeRequest(_ context.Context, req *http.Request, request interface{}) er
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Body = io.NopCloser(&buf)
 	return nil
 }
@@ -0,0 +1,66 @@
+package spinsvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+	next           Service
+}
+
+// InstrumentingMiddleware records request counts, error counts and latency
+// per method, labelled "method". Spins themselves are counted by CountSpins.
+func InstrumentingMiddleware(requestCount, errorCount metrics.Counter, requestLatency metrics.Histogram) ServiceMiddleware {
+	return func(next Service) Service {
+		return &instrumentingMiddleware{
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+			next:           next,
+		}
+	}
+}
+
+func (mw *instrumentingMiddleware) Spin(ctx context.Context, participantIds []int) (res SpinResult, err error) {
+	defer mw.observe("Spin", time.Now(), &err)
+	return mw.next.Spin(ctx, participantIds)
+}
+
+func (mw *instrumentingMiddleware) SpinUnweighted(ctx context.Context, participantIds []int) (res SpinResult, err error) {
+	defer mw.observe("SpinUnweighted", time.Now(), &err)
+	return mw.next.SpinUnweighted(ctx, participantIds)
+}
+
+func (mw *instrumentingMiddleware) Get(ctx context.Context, id int) (res SpinResult, err error) {
+	defer mw.observe("Get", time.Now(), &err)
+	return mw.next.Get(ctx, id)
+}
+
+func (mw *instrumentingMiddleware) GetLast(ctx context.Context) (res SpinResult, err error) {
+	defer mw.observe("GetLast", time.Now(), &err)
+	return mw.next.GetLast(ctx)
+}
+
+func (mw *instrumentingMiddleware) GetHistory(ctx context.Context, participantIds ...int) (res []SpinResult, err error) {
+	defer mw.observe("GetHistory", time.Now(), &err)
+	return mw.next.GetHistory(ctx, participantIds...)
+}
+
+func (mw *instrumentingMiddleware) Void(ctx context.Context, id int) (res SpinResult, err error) {
+	defer mw.observe("Void", time.Now(), &err)
+	return mw.next.Void(ctx, id)
+}
+
+func (mw *instrumentingMiddleware) observe(method string, begin time.Time, err *error) {
+	mw.requestCount.With("method", method).Add(1)
+	if *err != nil {
+		mw.errorCount.With("method", method).Add(1)
+	}
+	mw.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
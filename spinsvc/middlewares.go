@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
 )
 
 type ServiceMiddleware func(Service) Service
@@ -27,21 +29,42 @@ func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
 
 func (mw *loggingMiddleware) Spin(ctx context.Context, participantIds []int) (res SpinResult, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Spin", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Spin", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.Spin(ctx, participantIds)
 }
 
 func (mw *loggingMiddleware) SpinUnweighted(ctx context.Context, participantIds []int) (res SpinResult, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "SpinUnweighted", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "SpinUnweighted", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.SpinUnweighted(ctx, participantIds)
 }
 
+func (mw *loggingMiddleware) Get(ctx context.Context, id int) (res SpinResult, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Get", "id", id, "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Get(ctx, id)
+}
+
 func (mw *loggingMiddleware) GetLast(ctx context.Context) (res SpinResult, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "GetLast", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetLast", "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.GetLast(ctx)
 }
+
+func (mw *loggingMiddleware) GetHistory(ctx context.Context, participantIds ...int) (res []SpinResult, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetHistory", "participantIds", fmt.Sprintf("%v", participantIds), "results", len(res), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetHistory(ctx, participantIds...)
+}
+
+func (mw *loggingMiddleware) Void(ctx context.Context, id int) (res SpinResult, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Void", "id", id, "result", fmt.Sprintf("%v", res), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Void(ctx, id)
+}
@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: spinsvc.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SpinsClient is the client API for Spins service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SpinsClient interface {
+	Spin(ctx context.Context, in *SpinRequest, opts ...grpc.CallOption) (*SpinReply, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*SpinReply, error)
+	GetLast(ctx context.Context, in *GetLastRequest, opts ...grpc.CallOption) (*SpinReply, error)
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error)
+}
+
+type spinsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSpinsClient(cc grpc.ClientConnInterface) SpinsClient {
+	return &spinsClient{cc}
+}
+
+func (c *spinsClient) Spin(ctx context.Context, in *SpinRequest, opts ...grpc.CallOption) (*SpinReply, error) {
+	out := new(SpinReply)
+	err := c.cc.Invoke(ctx, "/spinsvc.Spins/Spin", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spinsClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*SpinReply, error) {
+	out := new(SpinReply)
+	err := c.cc.Invoke(ctx, "/spinsvc.Spins/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spinsClient) GetLast(ctx context.Context, in *GetLastRequest, opts ...grpc.CallOption) (*SpinReply, error) {
+	out := new(SpinReply)
+	err := c.cc.Invoke(ctx, "/spinsvc.Spins/GetLast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *spinsClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error) {
+	out := new(HistoryReply)
+	err := c.cc.Invoke(ctx, "/spinsvc.Spins/GetHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SpinsServer is the server API for Spins service.
+// All implementations must embed UnimplementedSpinsServer
+// for forward compatibility
+type SpinsServer interface {
+	Spin(context.Context, *SpinRequest) (*SpinReply, error)
+	Get(context.Context, *GetRequest) (*SpinReply, error)
+	GetLast(context.Context, *GetLastRequest) (*SpinReply, error)
+	GetHistory(context.Context, *GetHistoryRequest) (*HistoryReply, error)
+	mustEmbedUnimplementedSpinsServer()
+}
+
+// UnimplementedSpinsServer must be embedded to have forward compatible implementations.
+type UnimplementedSpinsServer struct {
+}
+
+func (UnimplementedSpinsServer) Spin(context.Context, *SpinRequest) (*SpinReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Spin not implemented")
+}
+func (UnimplementedSpinsServer) Get(context.Context, *GetRequest) (*SpinReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedSpinsServer) GetLast(context.Context, *GetLastRequest) (*SpinReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLast not implemented")
+}
+func (UnimplementedSpinsServer) GetHistory(context.Context, *GetHistoryRequest) (*HistoryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (UnimplementedSpinsServer) mustEmbedUnimplementedSpinsServer() {}
+
+// UnsafeSpinsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SpinsServer will
+// result in compilation errors.
+type UnsafeSpinsServer interface {
+	mustEmbedUnimplementedSpinsServer()
+}
+
+func RegisterSpinsServer(s grpc.ServiceRegistrar, srv SpinsServer) {
+	s.RegisterService(&Spins_ServiceDesc, srv)
+}
+
+func _Spins_Spin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SpinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpinsServer).Spin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spinsvc.Spins/Spin",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpinsServer).Spin(ctx, req.(*SpinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Spins_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpinsServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spinsvc.Spins/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpinsServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Spins_GetLast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpinsServer).GetLast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spinsvc.Spins/GetLast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpinsServer).GetLast(ctx, req.(*GetLastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Spins_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SpinsServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/spinsvc.Spins/GetHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SpinsServer).GetHistory(ctx, req.(*GetHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Spins_ServiceDesc is the grpc.ServiceDesc for Spins service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Spins_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spinsvc.Spins",
+	HandlerType: (*SpinsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Spin",
+			Handler:    _Spins_Spin_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Spins_Get_Handler,
+		},
+		{
+			MethodName: "GetLast",
+			Handler:    _Spins_GetLast_Handler,
+		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _Spins_GetHistory_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "spinsvc.proto",
+}
@@ -5,55 +5,110 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/events"
 	"github.com/jlthompson3259/matspinner/ticketsvc"
 )
 
 var (
-	ErrNoParticipants = errors.New("no participants")
-	ErrNoTickets      = errors.New("none of the participants have tickets")
-	ErrNoSpin         = errors.New("no spin yet to return")
+	ErrNoParticipants = apierror.New("spins.no_participants", "no participants")
+	ErrNoTickets      = apierror.New("spins.no_tickets", "none of the participants have tickets")
+	ErrNoSpin         = apierror.New("spins.no_spin", "no spin yet to return")
+	ErrSpinNotFound   = apierror.New("spins.not_found", "spin does not exist")
+	ErrSpinVoided     = apierror.New("spins.voided", "spin has already been voided")
+
+	ErrNegativeWinnerTickets = errors.New("winner tickets can't be negative")
 )
 
 type Service interface {
 	Spin(ctx context.Context, participantIds []int) (SpinResult, error)
 	SpinUnweighted(ctx context.Context, particantIds []int) (SpinResult, error)
+	Get(ctx context.Context, id int) (SpinResult, error)
 	GetLast(ctx context.Context) (SpinResult, error)
+	GetHistory(ctx context.Context, participantIds ...int) ([]SpinResult, error)
+	Void(ctx context.Context, id int) (SpinResult, error)
 }
 
 type SpinResult struct {
-	ParticipantIds []int `json:"participantIds"`
-	WinnerId       int   `json:"winnerId"`
+	Id             int       `json:"id"`
+	Time           time.Time `json:"time"`
+	ParticipantIds []int     `json:"participantIds"`
+	WinnerId       int       `json:"winnerId"`
+	WinnerTickets  int       `json:"winnerTickets"`
+	Voided         bool      `json:"voided,omitempty"`
 }
 
 func (t SpinResult) String() string {
-	return fmt.Sprintf("{participants: %v, winner: %v}", t.ParticipantIds, t.WinnerId)
+	return fmt.Sprintf("{id: %v, participants: %v, winner: %v}", t.Id, t.ParticipantIds, t.WinnerId)
+}
+
+// HasParticipant reports whether the player with the given id took part in
+// the spin.
+func (t SpinResult) HasParticipant(id int) bool {
+	for _, p := range t.ParticipantIds {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy controls what a spin does to the participants' tickets.
+type Policy struct {
+	// WinnerTickets is what the winner's tickets are reset to after a spin.
+	WinnerTickets int `yaml:"winnerTickets" env:"SPIN_WINNER_TICKETS" flag:"spin-winner-tickets" help:"tickets the winner is left with after a spin"`
+}
+
+func DefaultPolicy() Policy {
+	return Policy{WinnerTickets: 0}
+}
+
+func (p *Policy) Validate() error {
+	if p.WinnerTickets < 0 {
+		return ErrNegativeWinnerTickets
+	}
+	return nil
 }
 
 type spinService struct {
+	mtx           sync.Mutex
 	logger        log.Logger
 	ticketService ticketsvc.Service
-	lastSpin      *SpinResult
+	policy        Policy
+	bus           events.Publisher
+	history       *History
+	voiding       map[int]bool
 }
 
-func NewService(logger log.Logger, ticketService ticketsvc.Service) Service {
+// NewService makes a Service that draws winners using ticketService's
+// tickets and keeps its spins in history. Each spin and void is published
+// on bus once its tickets have changed, for RecordHistory to share it with
+// the other instances and for metrics.
+func NewService(logger log.Logger, ticketService ticketsvc.Service, policy Policy, bus events.Publisher, history *History) Service {
 	return &spinService{
 		logger:        logger,
 		ticketService: ticketService,
+		policy:        policy,
+		bus:           bus,
+		history:       history,
+		voiding:       map[int]bool{},
 	}
 }
 
 func (s *spinService) Spin(ctx context.Context, participantIds []int) (SpinResult, error) {
-	return s.spinUsingTicketFunction(ctx, participantIds, func(tickets []ticketsvc.Tickets) []ticketsvc.Tickets {
+	return s.spinUsingTicketFunction(ctx, participantIds, true, func(tickets []ticketsvc.Tickets) []ticketsvc.Tickets {
 		return tickets
 	})
 }
 
 func (s *spinService) SpinUnweighted(ctx context.Context, participantIds []int) (SpinResult, error) {
-	return s.spinUsingTicketFunction(ctx, participantIds, func(tickets []ticketsvc.Tickets) []ticketsvc.Tickets {
+	return s.spinUsingTicketFunction(ctx, participantIds, false, func(tickets []ticketsvc.Tickets) []ticketsvc.Tickets {
 		// give each participant only 1 ticket for the spin
 		ret := make([]ticketsvc.Tickets, len(tickets))
 		for i, v := range tickets {
@@ -63,17 +118,58 @@ func (s *spinService) SpinUnweighted(ctx context.Context, participantIds []int)
 	})
 }
 
+func (s *spinService) Get(ctx context.Context, id int) (SpinResult, error) {
+	spin, _, err := s.history.get(id)
+	return spin, err
+}
+
 func (s *spinService) GetLast(ctx context.Context) (SpinResult, error) {
-	if s.lastSpin == nil {
-		return SpinResult{}, ErrNoSpin
+	return s.history.last()
+}
+
+// GetHistory returns every spin, oldest first. If participantIds are given,
+// only spins that at least one of them took part in are returned.
+func (s *spinService) GetHistory(ctx context.Context, participantIds ...int) ([]SpinResult, error) {
+	return s.history.all(participantIds...), nil
+}
+
+// Void marks a spin as not counted, giving its winner back the tickets the
+// spin took from them. Participants keep the ticket they got for taking
+// part.
+func (s *spinService) Void(ctx context.Context, id int) (SpinResult, error) {
+	spin, winnerKeeps, err := s.history.get(id)
+	if err != nil {
+		return SpinResult{}, err
 	}
 
-	return *s.lastSpin, nil
+	s.mtx.Lock()
+	if spin.Voided || s.voiding[id] {
+		s.mtx.Unlock()
+		return SpinResult{}, apierror.WithDetails(ErrSpinVoided, map[string]string{"id": strconv.Itoa(id)})
+	}
+	s.voiding[id] = true
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.voiding, id)
+		s.mtx.Unlock()
+	}()
+
+	if taken := spin.WinnerTickets - winnerKeeps; taken != 0 {
+		if _, err := s.ticketService.Adjust(ctx, spin.WinnerId, taken); err != nil {
+			return SpinResult{}, err
+		}
+	}
+	s.history.void(id)
+	spin.Voided = true
+	events.PublishOrLog(ctx, s.bus, s.logger, EventSpinVoided, SpinVoided{Spin: spin, WinnerKeeps: winnerKeeps})
+	return spin, nil
 }
 
 func (s *spinService) spinUsingTicketFunction(
 	ctx context.Context,
 	participantIds []int,
+	weighted bool,
 	ticketFunc func(tickets []ticketsvc.Tickets) []ticketsvc.Tickets,
 ) (SpinResult, error) {
 
@@ -81,11 +177,20 @@ func (s *spinService) spinUsingTicketFunction(
 		return SpinResult{}, ErrNoParticipants
 	}
 
-	tickets, err := s.ticketService.Increment(ctx, participantIds...)
+	tickets, err := s.ticketService.Get(ctx, participantIds...)
 	if err != nil {
 		return SpinResult{}, err
 	}
 
+	// each participant gets a ticket for taking part, which counts in this
+	// spin; held is the winner's real ticket count, before ticketFunc
+	// changes the odds
+	held := make(map[int]int, len(tickets))
+	for i := range tickets {
+		tickets[i].Tickets++
+		held[tickets[i].Id] = tickets[i].Tickets
+	}
+
 	tickets = ticketFunc(tickets)
 
 	winner, err := chooseRandomWinner(tickets)
@@ -93,17 +198,28 @@ func (s *spinService) spinUsingTicketFunction(
 		return SpinResult{}, err
 	}
 
-	result := SpinResult{
-		ParticipantIds: participantIds,
-		WinnerId:       winner,
+	// the participants' tickets and the winner's reset are recorded
+	// together, so a spin either changes all of them or fails; the log entry
+	// numbers the spin, which keeps ids unique across instances
+	changes := make([]ticketsvc.Change, 0, len(participantIds)+1)
+	for _, id := range participantIds {
+		changes = append(changes, ticketsvc.Change{Kind: ticketsvc.ChangeIncremented, Id: id, Amount: 1})
 	}
-	s.lastSpin = &result
-
-	_, err = s.ticketService.Set(ctx, ticketsvc.Tickets{Id: winner, Tickets: 0})
+	changes = append(changes, ticketsvc.Change{Kind: ticketsvc.ChangeSet, Id: winner, Amount: s.policy.WinnerTickets})
+	recorded, err := s.ticketService.Record(ctx, changes...)
 	if err != nil {
-		return result, err
+		return SpinResult{}, err
 	}
 
+	result := SpinResult{
+		Id:             int(recorded.Seq),
+		Time:           time.Now().UTC(),
+		ParticipantIds: participantIds,
+		WinnerId:       winner,
+		WinnerTickets:  held[winner],
+	}
+	s.history.add(result, s.policy.WinnerTickets)
+	events.PublishOrLog(ctx, s.bus, s.logger, EventSpinCompleted, SpinCompleted{Spin: result, Weighted: weighted, WinnerKeeps: s.policy.WinnerTickets})
 	return result, nil
 }
 
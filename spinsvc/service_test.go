@@ -0,0 +1,152 @@
+package spinsvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/events"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+// newInstance returns a spinsvc instance on bus, with its own history, as a
+// separate replica would have.
+func newInstance(t *testing.T, bus events.Bus, tickets ticketsvc.Service) (Service, *History) {
+	t.Helper()
+	history := NewHistory()
+	if err := RecordHistory(bus, history); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(log.NewNopLogger(), tickets, DefaultPolicy(), bus, history), history
+}
+
+func newTickets(t *testing.T) ticketsvc.Service {
+	t.Helper()
+	ledger, err := ticketsvc.OpenLedger(ticketsvc.DefaultStorage(), log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticketsvc.NewService(log.NewNopLogger(), ledger)
+}
+
+func TestSpinChangesTicketsBeforeAnswering(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewLocal(log.NewNopLogger())
+	defer bus.Close(ctx)
+	tickets := newTickets(t)
+	s, _ := newInstance(t, bus, tickets)
+	if _, err := tickets.Set(ctx, ticketsvc.Tickets{Id: 1, Tickets: 4}, ticketsvc.Tickets{Id: 2, Tickets: 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	spin, err := s.SpinUnweighted(ctx, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tickets.Get(ctx, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each took a ticket for taking part, and the winner was then reset
+	held := map[int]int{1: 5, 2: 1}
+	want := map[int]int{1: 5, 2: 1}
+	want[spin.WinnerId] = DefaultPolicy().WinnerTickets
+	for _, tk := range got {
+		if tk.Tickets != want[tk.Id] {
+			t.Errorf("player %d has %d tickets after the spin, want %d", tk.Id, tk.Tickets, want[tk.Id])
+		}
+	}
+	if spin.WinnerTickets != held[spin.WinnerId] {
+		t.Errorf("winner tickets = %d, want %d", spin.WinnerTickets, held[spin.WinnerId])
+	}
+
+	// voiding gives the tickets back before answering too
+	if _, err := s.Void(ctx, spin.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = tickets.Get(ctx, spin.WinnerId); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Tickets != spin.WinnerTickets {
+		t.Errorf("winner has %d tickets after the void, want %d", got[0].Tickets, spin.WinnerTickets)
+	}
+	if _, err := s.Void(ctx, spin.Id); !errors.Is(err, ErrSpinVoided) {
+		t.Errorf("Void twice err = %v, want %v", err, ErrSpinVoided)
+	}
+}
+
+func TestSpinFailsWhenTicketsCantChange(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewLocal(log.NewNopLogger())
+	defer bus.Close(ctx)
+	s, history := newInstance(t, bus, failingRecord{newTickets(t)})
+
+	if _, err := s.Spin(ctx, []int{1}); !errors.Is(err, errRecord) {
+		t.Errorf("Spin err = %v, want %v", err, errRecord)
+	}
+	if spins := history.all(); len(spins) != 0 {
+		t.Errorf("history = %v, want the failed spin left out", spins)
+	}
+}
+
+var errRecord = errors.New("ticketsvc is down")
+
+type failingRecord struct {
+	ticketsvc.Service
+}
+
+func (failingRecord) Record(context.Context, ...ticketsvc.Change) (ticketsvc.Recorded, error) {
+	return ticketsvc.Recorded{}, errRecord
+}
+
+func TestInstancesSharingABus(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewLocal(log.NewNopLogger())
+	defer bus.Close(ctx)
+	tickets := newTickets(t)
+	a, historyA := newInstance(t, bus, tickets)
+	b, historyB := newInstance(t, bus, tickets)
+
+	// both instances spin before either has seen the other's event
+	const n = 10
+	ids := map[int]bool{}
+	for i := 0; i < n; i++ {
+		for _, s := range []Service{a, b} {
+			spin, err := s.Spin(ctx, []int{1, 2, 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids[spin.Id] {
+				t.Fatalf("spin id %d given twice", spin.Id)
+			}
+			ids[spin.Id] = true
+		}
+	}
+
+	// and each ends up with every spin, its own and the other's
+	for name, h := range map[string]*History{"a": historyA, "b": historyB} {
+		deadline := time.Now().Add(2 * time.Second)
+		for len(h.all()) < 2*n && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := len(h.all()); got != 2*n {
+			t.Errorf("history of %s has %d spins, want %d", name, got, 2*n)
+		}
+	}
+
+	// a spin made on one instance can be voided on the other
+	last, err := a.GetLast(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	voided, err := b.Void(ctx, last.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !voided.Voided {
+		t.Errorf("Void = %v, want it voided", voided)
+	}
+}
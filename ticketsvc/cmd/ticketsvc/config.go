@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	Sessions       config.Sessions   `yaml:"sessions"`
+	GRPC           config.GRPC       `yaml:"grpc"`
+	Storage        ticketsvc.Storage `yaml:"storage"`
+}
+
+func (s *settings) Validate() error {
+	return s.Sessions.Required(s.Auth)
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:  config.DefaultService(":8085"),
+		Sessions: config.DefaultSessions(),
+		GRPC:     config.GRPC{Addr: ":9085"},
+		Storage:  ticketsvc.DefaultStorage(),
+	}
+}
@@ -1,64 +1,145 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
 	"github.com/jlthompson3259/matspinner/ticketsvc"
-)
-
-const (
-	defaultHttpPort = "8085"
+	"github.com/jlthompson3259/matspinner/ticketsvc/pb"
+	"github.com/jlthompson3259/matspinner/usersvc"
 )
 
 func main() {
-	var (
-		httpAddr = net.JoinHostPort("0.0.0.0", envString("HTTP_PORT", defaultHttpPort))
-	)
+	if len(os.Args) > 1 && os.Args[1] == "rebuild" {
+		if err := runRebuild(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "rebuild:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	var logger log.Logger
-	{
-		logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-		logger = level.NewFilter(logger, level.AllowInfo(), level.SquelchNoLevel(true))
-		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+	cfg := defaultSettings()
+	if err := config.Load("ticketsvc", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("ticketsvc", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	sessions, err := usersvc.MakeClientEndpoints(cfg.Sessions.URL, cfg.Auth.Forward())
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, stopSessions := cfg.Sessions.Watch(cfg.Auth, authenticator, sessions.Ended, logger)
+	bus, err := cfg.Events.NewBus("ticketsvc", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	ledger, err := ticketsvc.OpenLedger(cfg.Storage, log.With(logger, "component", "ledger"))
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
 	}
 
-	var service ticketsvc.Service
+	var (
+		service   ticketsvc.Service
+		readiness []health.Check
+	)
 	{
-		service = ticketsvc.NewService(log.With(logger, "component", "service"))
+		fieldKeys := []string{"method"}
+		requestCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "ticketsvc",
+			Name:      "requests_total",
+			Help:      "Number of requests received.",
+		}, fieldKeys)
+		errorCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "matspinner",
+			Subsystem: "ticketsvc",
+			Name:      "errors_total",
+			Help:      "Number of requests that failed.",
+		}, fieldKeys)
+		requestLatency := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+			Namespace: "matspinner",
+			Subsystem: "ticketsvc",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling requests, in seconds.",
+			Buckets:   stdprometheus.DefBuckets,
+		}, fieldKeys)
+		outstanding := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+			Namespace: "matspinner",
+			Subsystem: "ticketsvc",
+			Name:      "tickets_outstanding",
+			Help:      "Total number of tickets held by all players.",
+		}, []string{})
+
+		base := ticketsvc.NewService(log.With(logger, "component", "service"), ledger)
+		readiness = append(readiness, health.Check{Name: "storage", Check: func(ctx context.Context) error {
+			_, err := base.GetAll(ctx)
+			return err
+		}})
+		if err := ticketsvc.CountOutstanding(bus, base, outstanding); err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		service = ticketsvc.EventsMiddleware(bus, log.With(logger, "component", "eventsMiddleware"))(base)
+		service = ticketsvc.InstrumentingMiddleware(requestCount, errorCount, requestLatency)(service)
 		service = ticketsvc.LoggingMiddleware(log.With(logger, "component", "loggingMiddleware"))(service)
 	}
 
 	var (
 		endpoints   = ticketsvc.MakeServerEndpoints(service)
-		httpHandler = ticketsvc.MakeHTTPHandler(endpoints, log.With(logger, "component", "http"))
+		httpHandler = ticketsvc.MakeHTTPHandler(endpoints, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
 	)
 
-	errs := make(chan error)
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		errs <- fmt.Errorf("%s", <-c)
-	}()
-
-	go func() {
-		level.Info(logger).Log("transport", "HTTP", "addr", httpAddr)
-		errs <- http.ListenAndServe(httpAddr, httpHandler)
-	}()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/", httpHandler)
 
-	level.Error(logger).Log("exit", <-errs)
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func envString(env, fallback string) string {
-	e := os.Getenv(env)
-	if e == "" {
-		return fallback
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnClose:         []server.Hook{stopSessions, bus.Close, ledger.Close, shutdownTracing},
+	}
+	if cfg.GRPC.Addr != "" {
+		srv.GRPC, srv.GRPCAddr = server.NewGRPC(), cfg.GRPC.Addr
+		pb.RegisterTicketsServer(srv.GRPC, ticketsvc.MakeGRPCServer(endpoints, authenticator, log.With(logger, "component", "grpc")))
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
 	}
-	return e
+	level.Info(logger).Log("exit", "stopped")
 }
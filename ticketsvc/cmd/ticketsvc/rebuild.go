@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/ticketsvc"
+)
+
+// rebuildSettings are the service's settings that rebuild uses. The rest
+// are accepted from a file shared with the service, but not checked, so
+// rebuilding doesn't depend on keys and sessions it has no use for.
+type rebuildSettings struct {
+	Log     config.Log        `yaml:"log"`
+	Storage ticketsvc.Storage `yaml:"storage"`
+	Rest    config.Rest       `yaml:",inline"`
+}
+
+// runRebuild implements `ticketsvc rebuild`, which replays the whole ticket
+// log in the configured storage directory and replaces the snapshot with the
+// result. It takes the service's storage and log settings, and should be run
+// while the service is stopped. The players whose tickets the snapshot had
+// wrong are written to stdout.
+func runRebuild(args []string) error {
+	defaults := defaultSettings()
+	cfg := rebuildSettings{Log: defaults.Log, Storage: defaults.Storage}
+	if err := config.Load("ticketsvc rebuild", &cfg, args); err != nil {
+		return err
+	}
+	if cfg.Storage.Dir == "" {
+		return errors.New("no ticket log to rebuild, set -tickets-dir or TICKETS_DIR")
+	}
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	ledger, err := ticketsvc.OpenLedger(cfg.Storage, log.With(logger, "component", "ledger"))
+	if err != nil {
+		return err
+	}
+	changed, err := ledger.Rebuild()
+	if cerr := ledger.Close(context.Background()); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "rebuilt tickets from log", "dir", cfg.Storage.Dir, "players", len(ledger.All()), "changed", len(changed))
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(changed)
+}
@@ -2,28 +2,46 @@ package ticketsvc
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
 	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 )
 
 type EndpointSet struct {
 	GetEndpoint       endpoint.Endpoint
+	GetAllEndpoint    endpoint.Endpoint
 	SetEndpoint       endpoint.Endpoint
 	IncrementEndpoint endpoint.Endpoint
+	AdjustEndpoint    endpoint.Endpoint
+	ResetEndpoint     endpoint.Endpoint
+	GetLogEndpoint    endpoint.Endpoint
+	CorrectEndpoint   endpoint.Endpoint
+	RecordEndpoint    endpoint.Endpoint
 }
 
 func MakeServerEndpoints(svc Service) EndpointSet {
 	return EndpointSet{
 		GetEndpoint:       MakeGetEndpoint(svc),
+		GetAllEndpoint:    MakeGetAllEndpoint(svc),
 		SetEndpoint:       MakeSetEndpoint(svc),
 		IncrementEndpoint: MakeIncrementEndpoint(svc),
+		AdjustEndpoint:    MakeAdjustEndpoint(svc),
+		ResetEndpoint:     MakeResetEndpoint(svc),
+		GetLogEndpoint:    MakeGetLogEndpoint(svc),
+		CorrectEndpoint:   MakeCorrectEndpoint(svc),
+		RecordEndpoint:    MakeRecordEndpoint(svc),
 	}
 }
 
-func MakeClientEndpoints(instance string) (EndpointSet, error) {
+func MakeClientEndpoints(instance string, options ...httptransport.ClientOption) (EndpointSet, error) {
 	if !strings.HasPrefix(instance, "http") {
 		instance = "http://" + instance
 	}
@@ -33,16 +51,56 @@ func MakeClientEndpoints(instance string) (EndpointSet, error) {
 	}
 
 	tgt.Path = ""
-
-	options := []httptransport.ClientOption{}
+	options = append([]httptransport.ClientOption{httptransport.ClientBefore(tracing.ContextToHTTP)}, options...)
 
 	return EndpointSet{
-		GetEndpoint:       httptransport.NewClient("GET", tgt, encodeGetRequest, decodeResponse, options...).Endpoint(),
-		SetEndpoint:       httptransport.NewClient("PUT", tgt, encodeSetRequest, decodeResponse, options...).Endpoint(),
-		IncrementEndpoint: httptransport.NewClient("POST", tgt, encodeIncrementRequest, decodeResponse, options...).Endpoint(),
+		GetEndpoint:       tracing.TraceClient("ticketsvc.Get")(httptransport.NewClient("GET", tgt, encodeGetRequest, decodeResponse, options...).Endpoint()),
+		GetAllEndpoint:    tracing.TraceClient("ticketsvc.GetAll")(httptransport.NewClient("GET", tgt, encodeGetAllRequest, decodeResponse, options...).Endpoint()),
+		SetEndpoint:       tracing.TraceClient("ticketsvc.Set")(httptransport.NewClient("PUT", tgt, encodeSetRequest, decodeResponse, options...).Endpoint()),
+		IncrementEndpoint: tracing.TraceClient("ticketsvc.Increment")(httptransport.NewClient("POST", tgt, encodeIncrementRequest, decodeResponse, options...).Endpoint()),
+		AdjustEndpoint:    tracing.TraceClient("ticketsvc.Adjust")(httptransport.NewClient("POST", tgt, encodeAdjustRequest, decodeResponse, options...).Endpoint()),
+		ResetEndpoint:     tracing.TraceClient("ticketsvc.Reset")(httptransport.NewClient("POST", tgt, encodeResetRequest, decodeResponse, options...).Endpoint()),
+		GetLogEndpoint:    tracing.TraceClient("ticketsvc.GetLog")(httptransport.NewClient("GET", tgt, encodeGetLogRequest, decodeLogResponse, options...).Endpoint()),
+		CorrectEndpoint:   tracing.TraceClient("ticketsvc.Correct")(httptransport.NewClient("POST", tgt, encodeCorrectRequest, decodeResponse, options...).Endpoint()),
+		RecordEndpoint:    tracing.TraceClient("ticketsvc.Record")(httptransport.NewClient("POST", tgt, encodeRecordRequest, decodeRecordResponse, options...).Endpoint()),
 	}, nil
 }
 
+// MakeBalancedClientEndpoints makes client endpoints that spread calls over
+// u's instances. Setting, resetting and correcting tickets are safe to
+// retry, incrementing and adjusting them and recording changes aren't.
+func MakeBalancedClientEndpoints(u *upstream.Upstream, options ...httptransport.ClientOption) EndpointSet {
+	return balance(u, func(instance string) (EndpointSet, io.Closer, error) {
+		e, err := MakeClientEndpoints(instance, options...)
+		return e, nil, err
+	})
+}
+
+// balance makes each endpoint of the set that dial returns for an instance
+// spread its calls over u's instances.
+func balance(u *upstream.Upstream, dial func(instance string) (EndpointSet, io.Closer, error)) EndpointSet {
+	factory := func(method func(EndpointSet) endpoint.Endpoint) sd.Factory {
+		return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+			e, closer, err := dial(instance)
+			if err != nil {
+				return nil, nil, err
+			}
+			return method(e), closer, nil
+		}
+	}
+	return EndpointSet{
+		GetEndpoint:       u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetEndpoint }), true),
+		GetAllEndpoint:    u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetAllEndpoint }), true),
+		SetEndpoint:       u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.SetEndpoint }), true),
+		IncrementEndpoint: u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.IncrementEndpoint }), false),
+		AdjustEndpoint:    u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.AdjustEndpoint }), false),
+		ResetEndpoint:     u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.ResetEndpoint }), true),
+		GetLogEndpoint:    u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetLogEndpoint }), true),
+		CorrectEndpoint:   u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.CorrectEndpoint }), true),
+		RecordEndpoint:    u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.RecordEndpoint }), false),
+	}
+}
+
 func (e *EndpointSet) Get(ctx context.Context, ids ...int) ([]Tickets, error) {
 	request := getRequest{Ids: ids}
 	r, err := e.GetEndpoint(ctx, request)
@@ -53,6 +111,16 @@ func (e *EndpointSet) Get(ctx context.Context, ids ...int) ([]Tickets, error) {
 	return resp.Tickets, nil
 }
 
+func (e *EndpointSet) GetAll(ctx context.Context) ([]Tickets, error) {
+	request := getAllRequest{}
+	r, err := e.GetAllEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(response)
+	return resp.Tickets, nil
+}
+
 func (e *EndpointSet) Set(ctx context.Context, tickets ...Tickets) ([]Tickets, error) {
 	request := setRequest{Tickets: tickets}
 	r, err := e.SetEndpoint(ctx, request)
@@ -73,6 +141,59 @@ func (e *EndpointSet) Increment(ctx context.Context, ids ...int) ([]Tickets, err
 	return resp.Tickets, nil
 }
 
+func (e *EndpointSet) Adjust(ctx context.Context, id, delta int) (Tickets, error) {
+	request := adjustRequest{Id: id, Delta: delta}
+	r, err := e.AdjustEndpoint(ctx, request)
+	if err != nil {
+		return Tickets{}, err
+	}
+	resp := r.(response)
+	if len(resp.Tickets) != 1 {
+		return Tickets{}, fmt.Errorf("adjust returned %d players' tickets, want 1", len(resp.Tickets))
+	}
+	return resp.Tickets[0], nil
+}
+
+func (e *EndpointSet) Reset(ctx context.Context) ([]Tickets, error) {
+	request := resetRequest{}
+	r, err := e.ResetEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(response)
+	return resp.Tickets, nil
+}
+
+func (e *EndpointSet) GetLog(ctx context.Context, ids ...int) ([]Entry, error) {
+	request := getLogRequest{Ids: ids}
+	r, err := e.GetLogEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(logResponse)
+	return resp.Entries, nil
+}
+
+func (e *EndpointSet) Correct(ctx context.Context, seq int64, replacement *Change) ([]Tickets, error) {
+	request := correctRequest{Seq: seq, Replacement: replacement}
+	r, err := e.CorrectEndpoint(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(response)
+	return resp.Tickets, nil
+}
+
+func (e *EndpointSet) Record(ctx context.Context, changes ...Change) (Recorded, error) {
+	request := recordRequest{Changes: changes}
+	r, err := e.RecordEndpoint(ctx, request)
+	if err != nil {
+		return Recorded{}, err
+	}
+	resp := r.(recordResponse)
+	return resp.Recorded, nil
+}
+
 func MakeGetEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(getRequest)
@@ -81,6 +202,13 @@ func MakeGetEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+func MakeGetAllEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		tickets, err := svc.GetAll(ctx)
+		return response{tickets, err}, nil
+	}
+}
+
 func MakeSetEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(setRequest)
@@ -97,21 +225,102 @@ func MakeIncrementEndpoint(svc Service) endpoint.Endpoint {
 	}
 }
 
+func MakeAdjustEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(adjustRequest)
+		t, err := svc.Adjust(ctx, req.Id, req.Delta)
+		if err != nil {
+			return response{Err: err}, nil
+		}
+		return response{Tickets: []Tickets{t}}, nil
+	}
+}
+
+func MakeResetEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		tickets, err := svc.Reset(ctx)
+		return response{tickets, err}, nil
+	}
+}
+
+func MakeGetLogEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(getLogRequest)
+		entries, err := svc.GetLog(ctx, req.Ids...)
+		return logResponse{entries, err}, nil
+	}
+}
+
+func MakeCorrectEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(correctRequest)
+		tickets, err := svc.Correct(ctx, req.Seq, req.Replacement)
+		return response{tickets, err}, nil
+	}
+}
+
+func MakeRecordEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(recordRequest)
+		recorded, err := svc.Record(ctx, req.Changes...)
+		return recordResponse{recorded, err}, nil
+	}
+}
+
 type getRequest struct {
 	Ids []int
 }
 
+type getAllRequest struct {
+}
+
 type incrementRequest struct {
-	Ids []int
+	Ids []int `json:"ids"`
 }
 
 type setRequest struct {
-	Tickets []Tickets
+	Tickets []Tickets `json:"tickets"`
+}
+
+type adjustRequest struct {
+	Id    int `json:"-"`
+	Delta int `json:"delta"`
+}
+
+type resetRequest struct {
+}
+
+type getLogRequest struct {
+	Ids []int
+}
+
+// correctRequest voids the entry numbered Seq when there's no replacement.
+type correctRequest struct {
+	Seq         int64   `json:"-"`
+	Replacement *Change `json:"replacement,omitempty"`
+}
+
+type recordRequest struct {
+	Changes []Change `json:"changes"`
 }
 
 type response struct {
 	Tickets []Tickets `json:"tickets,omitempty"`
-	Err     error     `json:"err,omitempty"`
+	Err     error     `json:"-"`
 }
 
 func (r response) error() error { return r.Err }
+
+type logResponse struct {
+	Entries []Entry `json:"entries"`
+	Err     error   `json:"-"`
+}
+
+func (r logResponse) error() error { return r.Err }
+
+type recordResponse struct {
+	Recorded
+	Err error `json:"-"`
+}
+
+func (r recordResponse) error() error { return r.Err }
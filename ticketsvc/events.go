@@ -0,0 +1,136 @@
+package ticketsvc
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/events"
+)
+
+// EventTicketsChanged is published with a TicketsChanged whenever players'
+// tickets change: when they're set, incremented, adjusted or reset, and when
+// a correction to the ticket log leaves them different, and when changes are
+// recorded together.
+const EventTicketsChanged = "tickets.changed"
+
+// TicketsChanged holds the new tickets of the players whose tickets changed.
+type TicketsChanged struct {
+	Tickets []Tickets `json:"tickets"`
+}
+
+type eventsMiddleware struct {
+	next   Service
+	bus    events.Publisher
+	logger log.Logger
+}
+
+// EventsMiddleware publishes the service's events on bus after each change.
+func EventsMiddleware(bus events.Publisher, logger log.Logger) ServiceMiddleware {
+	return func(next Service) Service {
+		return &eventsMiddleware{next: next, bus: bus, logger: logger}
+	}
+}
+
+func (mw *eventsMiddleware) Get(ctx context.Context, ids ...int) ([]Tickets, error) {
+	return mw.next.Get(ctx, ids...)
+}
+
+func (mw *eventsMiddleware) GetAll(ctx context.Context) ([]Tickets, error) {
+	return mw.next.GetAll(ctx)
+}
+
+func (mw *eventsMiddleware) Set(ctx context.Context, tickets ...Tickets) ([]Tickets, error) {
+	t, err := mw.next.Set(ctx, tickets...)
+	if err == nil {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: t})
+	}
+	return t, err
+}
+
+func (mw *eventsMiddleware) Increment(ctx context.Context, ids ...int) ([]Tickets, error) {
+	t, err := mw.next.Increment(ctx, ids...)
+	if err == nil {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: t})
+	}
+	return t, err
+}
+
+func (mw *eventsMiddleware) Adjust(ctx context.Context, id, delta int) (Tickets, error) {
+	t, err := mw.next.Adjust(ctx, id, delta)
+	if err == nil {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: []Tickets{t}})
+	}
+	return t, err
+}
+
+func (mw *eventsMiddleware) Reset(ctx context.Context) ([]Tickets, error) {
+	t, err := mw.next.Reset(ctx)
+	if err == nil && len(t) > 0 {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: t})
+	}
+	return t, err
+}
+
+func (mw *eventsMiddleware) GetLog(ctx context.Context, ids ...int) ([]Entry, error) {
+	return mw.next.GetLog(ctx, ids...)
+}
+
+func (mw *eventsMiddleware) Correct(ctx context.Context, seq int64, replacement *Change) ([]Tickets, error) {
+	t, err := mw.next.Correct(ctx, seq, replacement)
+	if err == nil && len(t) > 0 {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: t})
+	}
+	return t, err
+}
+
+func (mw *eventsMiddleware) Record(ctx context.Context, changes ...Change) (Recorded, error) {
+	r, err := mw.next.Record(ctx, changes...)
+	if err == nil && len(r.Tickets) > 0 {
+		events.PublishOrLog(ctx, mw.bus, mw.logger, EventTicketsChanged, TicketsChanged{Tickets: r.Tickets})
+	}
+	return r, err
+}
+
+// CountOutstanding keeps outstanding set to the total number of tickets
+// held. The total is read from svc once and then kept up to date from the
+// counts each TicketsChanged carries. Every instance counts every change, so
+// each reports the whole total.
+func CountOutstanding(bus events.Bus, svc Service, outstanding metrics.Gauge) error {
+	tickets, err := svc.GetAll(context.Background())
+	if err != nil {
+		return err
+	}
+	c := &outstandingCounter{gauge: outstanding, held: make(map[int]int)}
+	c.record(tickets)
+	return bus.Subscribe(EventTicketsChanged, "", func(_ context.Context, e events.Event) error {
+		var changed TicketsChanged
+		if err := e.Decode(&changed); err != nil {
+			return err
+		}
+		c.record(changed.Tickets)
+		return nil
+	})
+}
+
+type outstandingCounter struct {
+	gauge metrics.Gauge
+	held  map[int]int
+	total int
+}
+
+// record keeps the counts a change left players with. Counts are kept per
+// player rather than summed as deltas, so a count that arrives out of order
+// from racing changes is put right by the next change to that player.
+func (c *outstandingCounter) record(tickets []Tickets) {
+	for _, t := range tickets {
+		c.total += t.Tickets - c.held[t.Id]
+		if t.Tickets == 0 {
+			delete(c.held, t.Id)
+		} else {
+			c.held[t.Id] = t.Tickets
+		}
+	}
+	c.gauge.Set(float64(c.total))
+}
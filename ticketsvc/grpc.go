@@ -0,0 +1,381 @@
+package ticketsvc
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-kit/kit/transport"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+	"github.com/jlthompson3259/matspinner/ticketsvc/pb"
+)
+
+const grpcService = "ticketsvc.Tickets"
+
+type grpcServer struct {
+	pb.UnimplementedTicketsServer
+	get       grpctransport.Handler
+	getAll    grpctransport.Handler
+	set       grpctransport.Handler
+	increment grpctransport.Handler
+	adjust    grpctransport.Handler
+	reset     grpctransport.Handler
+	getLog    grpctransport.Handler
+	correct   grpctransport.Handler
+	record    grpctransport.Handler
+}
+
+// MakeGRPCServer serves the endpoints over gRPC, with the same roles
+// required as over HTTP.
+func MakeGRPCServer(e EndpointSet, authn auth.Authenticator, logger log.Logger) pb.TicketsServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		grpctransport.ServerBefore(auth.GRPCToContext(authn)),
+	}
+	return &grpcServer{
+		get:       grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetEndpoint), decodeGRPCGetRequest, encodeGRPCResponse, options...),
+		getAll:    grpctransport.NewServer(auth.Require(auth.RoleDisplay)(e.GetAllEndpoint), decodeGRPCGetAllRequest, encodeGRPCResponse, options...),
+		set:       grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.SetEndpoint), decodeGRPCSetRequest, encodeGRPCResponse, options...),
+		increment: grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.IncrementEndpoint), decodeGRPCIncrementRequest, encodeGRPCResponse, options...),
+		adjust:    grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.AdjustEndpoint), decodeGRPCAdjustRequest, encodeGRPCResponse, options...),
+		reset:     grpctransport.NewServer(auth.Require(auth.RoleAdmin)(e.ResetEndpoint), decodeGRPCResetRequest, encodeGRPCResponse, options...),
+		getLog:    grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.GetLogEndpoint), decodeGRPCGetLogRequest, encodeGRPCLogResponse, options...),
+		correct:   grpctransport.NewServer(auth.Require(auth.RoleAdmin)(e.CorrectEndpoint), decodeGRPCCorrectRequest, encodeGRPCResponse, options...),
+		record:    grpctransport.NewServer(auth.Require(auth.RoleStaff)(e.RecordEndpoint), decodeGRPCRecordRequest, encodeGRPCRecordResponse, options...),
+	}
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.get.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) GetAll(ctx context.Context, req *pb.GetAllRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.getAll.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) Set(ctx context.Context, req *pb.SetRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.set.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) Increment(ctx context.Context, req *pb.IncrementRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.increment.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) Adjust(ctx context.Context, req *pb.AdjustRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.adjust.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) Reset(ctx context.Context, req *pb.ResetRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.reset.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) GetLog(ctx context.Context, req *pb.GetLogRequest) (*pb.LogReply, error) {
+	_, rep, err := s.getLog.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.LogReply), nil
+}
+
+func (s *grpcServer) Correct(ctx context.Context, req *pb.CorrectRequest) (*pb.TicketsReply, error) {
+	_, rep, err := s.correct.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.TicketsReply), nil
+}
+
+func (s *grpcServer) Record(ctx context.Context, req *pb.RecordRequest) (*pb.RecordReply, error) {
+	_, rep, err := s.record.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.RecordReply), nil
+}
+
+// MakeGRPCClientEndpoints makes client endpoints that call the service over
+// conn.
+func MakeGRPCClientEndpoints(conn *grpc.ClientConn, options ...grpctransport.ClientOption) EndpointSet {
+	options = append([]grpctransport.ClientOption{grpctransport.ClientBefore(tracing.ContextToGRPC)}, options...)
+
+	return EndpointSet{
+		GetEndpoint:       tracing.TraceClient("ticketsvc.Get")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Get", encodeGRPCGetRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		GetAllEndpoint:    tracing.TraceClient("ticketsvc.GetAll")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetAll", encodeGRPCGetAllRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		SetEndpoint:       tracing.TraceClient("ticketsvc.Set")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Set", encodeGRPCSetRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		IncrementEndpoint: tracing.TraceClient("ticketsvc.Increment")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Increment", encodeGRPCIncrementRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		AdjustEndpoint:    tracing.TraceClient("ticketsvc.Adjust")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Adjust", encodeGRPCAdjustRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		ResetEndpoint:     tracing.TraceClient("ticketsvc.Reset")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Reset", encodeGRPCResetRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		GetLogEndpoint:    tracing.TraceClient("ticketsvc.GetLog")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "GetLog", encodeGRPCGetLogRequest, decodeGRPCLogResponse, pb.LogReply{}, options...).Endpoint())),
+		CorrectEndpoint:   tracing.TraceClient("ticketsvc.Correct")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Correct", encodeGRPCCorrectRequest, decodeGRPCResponse, pb.TicketsReply{}, options...).Endpoint())),
+		RecordEndpoint:    tracing.TraceClient("ticketsvc.Record")(apierror.GRPCClient(grpctransport.NewClient(conn, grpcService, "Record", encodeGRPCRecordRequest, decodeGRPCRecordResponse, pb.RecordReply{}, options...).Endpoint())),
+	}
+}
+
+// MakeBalancedGRPCClientEndpoints is MakeBalancedClientEndpoints for
+// instances serving gRPC, given as host:port.
+func MakeBalancedGRPCClientEndpoints(u *upstream.Upstream, options ...grpctransport.ClientOption) EndpointSet {
+	return balance(u, func(instance string) (EndpointSet, io.Closer, error) {
+		conn, err := grpc.Dial(instance, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return EndpointSet{}, nil, err
+		}
+		return MakeGRPCClientEndpoints(conn, options...), conn, nil
+	})
+}
+
+// grpcError turns err into a gRPC status with the code matching the HTTP
+// status the error is answered with.
+func grpcError(err error) error {
+	return apierror.GRPCStatus(codeFrom(err), err)
+}
+
+/** server decode/encode **/
+func decodeGRPCGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetRequest)
+	if len(req.Ids) == 0 {
+		return nil, ErrMissingIds
+	}
+	return getRequest{Ids: toInts(req.Ids)}, nil
+}
+
+func decodeGRPCGetAllRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return getAllRequest{}, nil
+}
+
+func decodeGRPCSetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.SetRequest)
+	return setRequest{Tickets: fromPBTickets(req.Tickets)}, nil
+}
+
+func decodeGRPCIncrementRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.IncrementRequest)
+	return incrementRequest{Ids: toInts(req.Ids)}, nil
+}
+
+func decodeGRPCAdjustRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.AdjustRequest)
+	return adjustRequest{Id: int(req.Id), Delta: int(req.Delta)}, nil
+}
+
+func decodeGRPCResetRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return resetRequest{}, nil
+}
+
+func decodeGRPCGetLogRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetLogRequest)
+	return getLogRequest{Ids: toInts(req.Ids)}, nil
+}
+
+func decodeGRPCCorrectRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CorrectRequest)
+	return correctRequest{Seq: req.Seq, Replacement: fromPBChange(req.Replacement)}, nil
+}
+
+func decodeGRPCRecordRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RecordRequest)
+	changes := make([]Change, len(req.Changes))
+	for i, c := range req.Changes {
+		changes[i] = *fromPBChange(c)
+	}
+	return recordRequest{Changes: changes}, nil
+}
+
+// encodeGRPCResponse returns a business-logic error as the call's error,
+// where the HTTP transport would have encoded it in the response.
+func encodeGRPCResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(response)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.TicketsReply{Tickets: toPBTickets(r.Tickets)}, nil
+}
+
+func encodeGRPCLogResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(logResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	entries := make([]*pb.LogEntry, len(r.Entries))
+	for i, e := range r.Entries {
+		entries[i] = toPBEntry(e)
+	}
+	return &pb.LogReply{Entries: entries}, nil
+}
+
+func encodeGRPCRecordResponse(_ context.Context, resp interface{}) (interface{}, error) {
+	r := resp.(recordResponse)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &pb.RecordReply{Seq: r.Seq, Tickets: toPBTickets(r.Tickets)}, nil
+}
+
+/** client encode/decode **/
+func encodeGRPCGetRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getRequest)
+	return &pb.GetRequest{Ids: toInt64s(req.Ids)}, nil
+}
+
+func encodeGRPCGetAllRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.GetAllRequest{}, nil
+}
+
+func encodeGRPCSetRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(setRequest)
+	return &pb.SetRequest{Tickets: toPBTickets(req.Tickets)}, nil
+}
+
+func encodeGRPCIncrementRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(incrementRequest)
+	return &pb.IncrementRequest{Ids: toInt64s(req.Ids)}, nil
+}
+
+func encodeGRPCAdjustRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(adjustRequest)
+	return &pb.AdjustRequest{Id: int64(req.Id), Delta: int64(req.Delta)}, nil
+}
+
+func encodeGRPCResetRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return &pb.ResetRequest{}, nil
+}
+
+func encodeGRPCGetLogRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(getLogRequest)
+	return &pb.GetLogRequest{Ids: toInt64s(req.Ids)}, nil
+}
+
+func encodeGRPCCorrectRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(correctRequest)
+	return &pb.CorrectRequest{Seq: req.Seq, Replacement: toPBChange(req.Replacement)}, nil
+}
+
+func encodeGRPCRecordRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(recordRequest)
+	changes := make([]*pb.Change, len(req.Changes))
+	for i := range req.Changes {
+		changes[i] = toPBChange(&req.Changes[i])
+	}
+	return &pb.RecordRequest{Changes: changes}, nil
+}
+
+func decodeGRPCRecordResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.RecordReply)
+	return recordResponse{Recorded: Recorded{Seq: reply.Seq, Tickets: fromPBTickets(reply.Tickets)}}, nil
+}
+
+func decodeGRPCLogResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.LogReply)
+	entries := make([]Entry, len(reply.Entries))
+	for i, e := range reply.Entries {
+		entries[i] = fromPBEntry(e)
+	}
+	return logResponse{Entries: entries}, nil
+}
+
+func decodeGRPCResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.TicketsReply)
+	return response{Tickets: fromPBTickets(reply.Tickets)}, nil
+}
+
+func toPBTickets(tickets []Tickets) []*pb.PlayerTickets {
+	out := make([]*pb.PlayerTickets, len(tickets))
+	for i, t := range tickets {
+		out[i] = &pb.PlayerTickets{Id: int64(t.Id), Tickets: int64(t.Tickets)}
+	}
+	return out
+}
+
+func fromPBTickets(tickets []*pb.PlayerTickets) []Tickets {
+	out := make([]Tickets, len(tickets))
+	for i, t := range tickets {
+		out[i] = Tickets{Id: int(t.GetId()), Tickets: int(t.GetTickets())}
+	}
+	return out
+}
+
+func toPBEntry(e Entry) *pb.LogEntry {
+	return &pb.LogEntry{
+		Seq:         e.Seq,
+		Time:        timestamppb.New(e.Time),
+		Change:      &pb.Change{Kind: string(e.Kind), Id: int64(e.Id), Amount: int64(e.Amount)},
+		Corrects:    e.Corrects,
+		Replacement: toPBChange(e.Replacement),
+	}
+}
+
+func fromPBEntry(e *pb.LogEntry) Entry {
+	c := e.GetChange()
+	return Entry{
+		Seq:  e.GetSeq(),
+		Time: e.GetTime().AsTime(),
+		Change: Change{
+			Kind:        ChangeKind(c.GetKind()),
+			Id:          int(c.GetId()),
+			Amount:      int(c.GetAmount()),
+			Corrects:    e.GetCorrects(),
+			Replacement: fromPBChange(e.GetReplacement()),
+		},
+	}
+}
+
+func toPBChange(c *Change) *pb.Change {
+	if c == nil {
+		return nil
+	}
+	return &pb.Change{Kind: string(c.Kind), Id: int64(c.Id), Amount: int64(c.Amount)}
+}
+
+func fromPBChange(c *pb.Change) *Change {
+	if c == nil {
+		return nil
+	}
+	return &Change{Kind: ChangeKind(c.Kind), Id: int(c.Id), Amount: int(c.Amount)}
+}
+
+func toInt64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
+
+func toInts(ids []int64) []int {
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
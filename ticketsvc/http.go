@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 
@@ -17,38 +15,91 @@ import (
 	"github.com/go-kit/kit/transport"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
 )
 
 var (
-	ErrMissingIds = errors.New("missing ids")
-	ErrParsingIds = errors.New("error parsing ids, should be ints")
+	ErrMissingIds = apierror.New("tickets.missing_ids", "missing ids")
+	ErrParsingIds = apierror.New("tickets.parsing_ids", "error parsing ids, should be ints")
+	ErrParsingSeq = apierror.New("tickets.parsing_seq", "error parsing log entry number, should be an int")
 )
 
-func MakeHTTPHandler(e EndpointSet, logger log.Logger) http.Handler {
+func MakeHTTPHandler(e EndpointSet, authn auth.Authenticator, crossOrigin cors.Policy, limits limit.Settings, logger log.Logger) http.Handler {
+	return cors.Handler(crossOrigin, limit.Handler(limits, authn, openapi.Serve(spec, makeRouter(e, authn, logger))))
+}
+
+// makeRouter routes the endpoints. Every route must be described by the
+// OpenAPI document, which the tests check.
+func makeRouter(e EndpointSet, authn auth.Authenticator, logger log.Logger) *mux.Router {
 	r := mux.NewRouter()
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.HTTPToContext(), auth.HTTPToContext(authn)),
+		httptransport.ServerFinalizer(tracing.ServerFinalizer),
 	}
 
-	r.Methods("GET").Path("/tickets").Handler(httptransport.NewServer(
-		e.GetEndpoint,
+	r.Methods("GET").Path("/tickets").Queries("ids", "{ids}").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetEndpoint),
 		decodeGetRequest,
 		encodeResponse,
 		options...,
 	))
+	r.Methods("GET").Path("/tickets").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.GetAllEndpoint),
+		decodeGetAllRequest,
+		encodeResponse,
+		options...,
+	))
 	r.Methods("PUT").Path("/tickets").Handler(httptransport.NewServer(
-		e.SetEndpoint,
+		auth.Require(auth.RoleStaff)(e.SetEndpoint),
 		decodeSetRequest,
 		encodeResponse,
 		options...,
 	))
 	r.Methods("POST").Path("/tickets/increment").Handler(httptransport.NewServer(
-		e.IncrementEndpoint,
+		auth.Require(auth.RoleStaff)(e.IncrementEndpoint),
 		decodeIncrementRequest,
 		encodeResponse,
 		options...,
 	))
+	r.Methods("POST").Path("/tickets/reset").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.ResetEndpoint),
+		decodeResetRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/tickets/{id}/adjust").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.AdjustEndpoint),
+		decodeAdjustRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/tickets/log").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.GetLogEndpoint),
+		decodeGetLogRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/tickets/log").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.RecordEndpoint),
+		decodeRecordRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/tickets/log/{seq}/correct").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.CorrectEndpoint),
+		decodeCorrectRequest,
+		encodeResponse,
+		options...,
+	))
 	return r
 }
 
@@ -60,15 +111,19 @@ func decodeGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	}
 	ids, err := decodeIdsQueryString(q.Get("ids"))
 	if err != nil {
-		return nil, ErrParsingIds
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"ids": q.Get("ids")})
 	}
 	return getRequest{Ids: ids}, nil
 }
 
+func decodeGetAllRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return getAllRequest{}, nil
+}
+
 func decodeSetRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request setRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, limit.BodyError(err)
 	}
 	return request, nil
 }
@@ -76,7 +131,60 @@ func decodeSetRequest(_ context.Context, r *http.Request) (interface{}, error) {
 func decodeIncrementRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var request incrementRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, err
+		return nil, limit.BodyError(err)
+	}
+	return request, nil
+}
+
+func decodeResetRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return resetRequest{}, nil
+}
+
+func decodeAdjustRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"ids": idStr})
+	}
+	var request adjustRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	request.Id = id
+	return request, nil
+}
+
+// decodeGetLogRequest returns the whole log when ids isn't given.
+func decodeGetLogRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	if !q.Has("ids") {
+		return getLogRequest{}, nil
+	}
+	ids, err := decodeIdsQueryString(q.Get("ids"))
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingIds, map[string]string{"ids": q.Get("ids")})
+	}
+	return getLogRequest{Ids: ids}, nil
+}
+
+func decodeCorrectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	seqStr := mux.Vars(r)["seq"]
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil {
+		return nil, apierror.WithDetails(ErrParsingSeq, map[string]string{"seq": seqStr})
+	}
+	var request correctRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	request.Seq = seq
+	return request, nil
+}
+
+func decodeRecordRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request recordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
 	}
 	return request, nil
 }
@@ -108,11 +216,7 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(codeFrom(err))
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": err.Error(),
-	})
+	apierror.Encode(w, codeFrom(err), err)
 }
 
 func decodeIdsQueryString(idStr string) (ids []int, err error) {
@@ -128,16 +232,31 @@ func decodeIdsQueryString(idStr string) (ids []int, err error) {
 }
 
 func codeFrom(err error) int {
-	switch err {
-	case ErrMissingIds, ErrParsingIds:
+	switch apierror.Sentinel(err) {
+	case auth.ErrUnauthenticated, auth.ErrInvalidCredentials:
+		return http.StatusUnauthorized
+	case auth.ErrForbidden, auth.ErrWrongStore:
+		return http.StatusForbidden
+	case ErrMissingIds, ErrParsingIds, ErrParsingSeq, ErrInvalidCorrection, ErrInvalidChange, ErrNoChanges:
 		return http.StatusBadRequest
+	case ErrEntryNotFound:
+		return http.StatusNotFound
+	case auth.ErrSessionsUnknown, upstream.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case upstream.ErrBadGateway:
+		return http.StatusBadGateway
+	case limit.ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
 	default:
-		return http.StatusInternalServerError
+		return apierror.StatusCode(err, http.StatusInternalServerError)
 	}
 }
 
 /** client encode/decode **/
 func decodeResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
 	var response response
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
@@ -145,15 +264,39 @@ func decodeResponse(ctx context.Context, resp *http.Response) (interface{}, erro
 	return response, nil
 }
 
+func decodeLogResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response logResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeRecordResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response recordResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
 func encodeGetRequest(ctx context.Context, req *http.Request, request interface{}) error {
 	r := request.(getRequest)
-	var qStr string
-	{
-		qStr = encodeIdsQueryString(r.Ids)
-		qStr = url.QueryEscape(qStr)
-	}
+	q := req.URL.Query()
+	q.Set("ids", encodeIdsQueryString(r.Ids))
+	req.URL.Path = "/tickets"
+	req.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func encodeGetAllRequest(ctx context.Context, req *http.Request, request interface{}) error {
 	req.URL.Path = "/tickets"
-	req.URL.Query().Add("ids", qStr)
 	return nil
 }
 
@@ -167,6 +310,39 @@ func encodeIncrementRequest(ctx context.Context, req *http.Request, request inte
 	return encodeRequest(ctx, req, request)
 }
 
+func encodeAdjustRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(adjustRequest)
+	req.URL.Path = "/tickets/" + strconv.Itoa(r.Id) + "/adjust"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeResetRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/tickets/reset"
+	return nil
+}
+
+func encodeGetLogRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(getLogRequest)
+	req.URL.Path = "/tickets/log"
+	if len(r.Ids) > 0 {
+		q := req.URL.Query()
+		q.Set("ids", encodeIdsQueryString(r.Ids))
+		req.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+func encodeCorrectRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(correctRequest)
+	req.URL.Path = "/tickets/log/" + strconv.FormatInt(r.Seq, 10) + "/correct"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeRecordRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/tickets/log"
+	return encodeRequest(ctx, req, request)
+}
+
 // encodeRequest likewise JSON-encodes the request to the HTTP request body.
 // Don't use it directly as a transport/http.Client EncodeRequestFunc:
 // profilesvc endpoints require mutating the HTTP method and request path.
@@ -176,6 +352,7 @@ func encodeRequest(_ context.Context, req *http.Request, request interface{}) er
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Body = io.NopCloser(&buf)
 	return nil
 }
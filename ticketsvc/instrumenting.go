@@ -0,0 +1,82 @@
+package ticketsvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	errorCount     metrics.Counter
+	requestLatency metrics.Histogram
+	next           Service
+}
+
+// InstrumentingMiddleware records request counts, error counts and latency
+// per method, labelled "method". The tickets held are counted by
+// CountOutstanding.
+func InstrumentingMiddleware(requestCount, errorCount metrics.Counter, requestLatency metrics.Histogram) ServiceMiddleware {
+	return func(next Service) Service {
+		return &instrumentingMiddleware{
+			requestCount:   requestCount,
+			errorCount:     errorCount,
+			requestLatency: requestLatency,
+			next:           next,
+		}
+	}
+}
+
+func (mw *instrumentingMiddleware) Get(ctx context.Context, ids ...int) (t []Tickets, err error) {
+	defer mw.observe("Get", time.Now(), &err)
+	return mw.next.Get(ctx, ids...)
+}
+
+func (mw *instrumentingMiddleware) GetAll(ctx context.Context) (t []Tickets, err error) {
+	defer mw.observe("GetAll", time.Now(), &err)
+	return mw.next.GetAll(ctx)
+}
+
+func (mw *instrumentingMiddleware) Set(ctx context.Context, tickets ...Tickets) (t []Tickets, err error) {
+	defer mw.observe("Set", time.Now(), &err)
+	return mw.next.Set(ctx, tickets...)
+}
+
+func (mw *instrumentingMiddleware) Increment(ctx context.Context, ids ...int) (t []Tickets, err error) {
+	defer mw.observe("Increment", time.Now(), &err)
+	return mw.next.Increment(ctx, ids...)
+}
+
+func (mw *instrumentingMiddleware) Adjust(ctx context.Context, id, delta int) (t Tickets, err error) {
+	defer mw.observe("Adjust", time.Now(), &err)
+	return mw.next.Adjust(ctx, id, delta)
+}
+
+func (mw *instrumentingMiddleware) Reset(ctx context.Context) (t []Tickets, err error) {
+	defer mw.observe("Reset", time.Now(), &err)
+	return mw.next.Reset(ctx)
+}
+
+func (mw *instrumentingMiddleware) GetLog(ctx context.Context, ids ...int) (e []Entry, err error) {
+	defer mw.observe("GetLog", time.Now(), &err)
+	return mw.next.GetLog(ctx, ids...)
+}
+
+func (mw *instrumentingMiddleware) Correct(ctx context.Context, seq int64, replacement *Change) (t []Tickets, err error) {
+	defer mw.observe("Correct", time.Now(), &err)
+	return mw.next.Correct(ctx, seq, replacement)
+}
+
+func (mw *instrumentingMiddleware) Record(ctx context.Context, changes ...Change) (r Recorded, err error) {
+	defer mw.observe("Record", time.Now(), &err)
+	return mw.next.Record(ctx, changes...)
+}
+
+func (mw *instrumentingMiddleware) observe(method string, begin time.Time, err *error) {
+	mw.requestCount.With("method", method).Add(1)
+	if *err != nil {
+		mw.errorCount.With("method", method).Add(1)
+	}
+	mw.requestLatency.With("method", method).Observe(time.Since(begin).Seconds())
+}
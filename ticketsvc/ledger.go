@@ -0,0 +1,636 @@
+package ticketsvc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+)
+
+var (
+	ErrEntryNotFound     = apierror.New("tickets.entry_not_found", "log entry does not exist")
+	ErrInvalidCorrection = apierror.New("tickets.invalid_correction", "only changes to tickets can be corrected, and only by another change to tickets")
+	ErrInvalidChange     = apierror.New("tickets.invalid_change", "only increments, sets and adjustments can be recorded together, resets and corrections have their own routes")
+	ErrNoChanges         = apierror.New("tickets.no_changes", "no changes to record")
+
+	ErrSnapshotEvery = errors.New("snapshot interval must be at least 1 entry")
+)
+
+const (
+	logFile      = "tickets.log"
+	snapshotFile = "tickets.snapshot.json"
+)
+
+// ChangeKind says what a change does to players' tickets.
+type ChangeKind string
+
+const (
+	// ChangeIncremented adds Amount to the player's tickets.
+	ChangeIncremented ChangeKind = "incremented"
+	// ChangeSet replaces the player's tickets with Amount.
+	ChangeSet ChangeKind = "set"
+	// ChangeAdjusted adds Amount, which may be negative, to the player's
+	// tickets, stopping at zero.
+	ChangeAdjusted ChangeKind = "adjusted"
+	// ChangeReset takes every player's tickets away.
+	ChangeReset ChangeKind = "reset"
+	// ChangeCorrected replaces the entry numbered Corrects with Replacement,
+	// or with nothing if there's no replacement, as if it had been logged
+	// that way in the first place.
+	ChangeCorrected ChangeKind = "corrected"
+)
+
+// Change is one change to players' tickets. Id and Amount are unused by
+// resets and corrections.
+type Change struct {
+	Kind        ChangeKind `json:"kind"`
+	Id          int        `json:"id"`
+	Amount      int        `json:"amount"`
+	Corrects    int64      `json:"corrects,omitempty"`
+	Replacement *Change    `json:"replacement,omitempty"`
+}
+
+// Entry is a change as the ledger logged it. Entries are numbered from 1 in
+// the order they were logged.
+type Entry struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	Change
+}
+
+func (c Change) apply(tickets map[int]int) {
+	switch c.Kind {
+	case ChangeIncremented, ChangeAdjusted:
+		setTickets(tickets, c.Id, tickets[c.Id]+c.Amount)
+	case ChangeSet:
+		setTickets(tickets, c.Id, c.Amount)
+	case ChangeReset:
+		for id := range tickets {
+			delete(tickets, id)
+		}
+	}
+}
+
+// touches reports whether the change can move any of ids' tickets.
+func (c Change) touches(ids map[int]bool) bool {
+	return c.Kind == ChangeReset || (c.Kind != ChangeCorrected && ids[c.Id])
+}
+
+// setTickets keeps players without tickets out of the map, so that it only
+// ever holds what GetAll returns.
+func setTickets(tickets map[int]int, id, n int) {
+	if n <= 0 {
+		delete(tickets, id)
+	} else {
+		tickets[id] = n
+	}
+}
+
+// Storage says where the ledger keeps its log.
+type Storage struct {
+	Dir           string `yaml:"dir" env:"TICKETS_DIR" flag:"tickets-dir" help:"directory to keep the ticket log and its snapshots in; empty keeps tickets in memory"`
+	SnapshotEvery int    `yaml:"snapshotEvery" env:"TICKETS_SNAPSHOT_EVERY" flag:"tickets-snapshot-every" help:"log entries between snapshots of everyone's tickets"`
+}
+
+func DefaultStorage() Storage {
+	return Storage{SnapshotEvery: 1000}
+}
+
+func (s *Storage) Validate() error {
+	if s.SnapshotEvery < 1 {
+		return ErrSnapshotEvery
+	}
+	return nil
+}
+
+// Ledger derives players' tickets from an append-only log of changes.
+// Every change is logged before it takes effect, and the tickets can always
+// be rebuilt by replaying the log from the start. With a Storage directory
+// the log is a file of JSON lines, and a snapshot of everyone's tickets is
+// written every so often so that opening the ledger only replays what was
+// logged after it. Without one, the log is kept in memory and lost on exit,
+// and a snapshot instead drops the entries from before the previous one, so
+// that only the last snapshot or two of entries can be listed or corrected.
+type Ledger struct {
+	mtx     sync.RWMutex
+	storage Storage
+	logger  log.Logger
+	tickets map[int]int
+	seq     int64
+
+	// file and size are the log and the bytes of it that hold whole entries,
+	// when it's kept on disk; entries is the log when it's kept in memory.
+	file    *os.File
+	size    int64
+	entries []Entry
+
+	// base is the tickets left by the entries dropped from memory, and
+	// snapTickets and snapSeq are the tickets and last entry at the latest
+	// in-memory snapshot, the point the next one drops entries up to.
+	base        map[int]int
+	snapTickets map[int]int
+	snapSeq     int64
+
+	// unsnapshotted counts the entries logged since the last snapshot.
+	unsnapshotted int
+}
+
+// OpenLedger opens the ledger kept in s. An entry cut short by a crash
+// while it was being logged is discarded; any other entry that can't be
+// read is an error.
+func OpenLedger(s Storage, logger log.Logger) (*Ledger, error) {
+	l := &Ledger{storage: s, logger: logger, tickets: make(map[int]int)}
+	if s.Dir == "" {
+		return l, nil
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.Dir, logFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	if err := l.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("loading %s: %w", f.Name(), err)
+	}
+	return l, nil
+}
+
+// load restores the tickets from the snapshot and the entries logged after
+// it. It replays the whole log instead if there's no usable snapshot, or if
+// one of those entries corrects an entry from before the snapshot.
+func (l *Ledger) load() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	snap, err := l.readSnapshot()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		level.Warn(l.logger).Log("msg", "ignoring unreadable snapshot", "err", err)
+	case snap.Offset > size:
+		level.Warn(l.logger).Log("msg", "ignoring snapshot of a longer log", "seq", snap.Seq)
+	default:
+		tail, end, err := readEntries(io.NewSectionReader(l.file, snap.Offset, size-snap.Offset), snap.Offset)
+		if err != nil {
+			return err
+		}
+		if !correctsBefore(tail, snap.Seq) {
+			for _, t := range snap.Tickets {
+				setTickets(l.tickets, t.Id, t.Tickets)
+			}
+			replay(l.tickets, tail, corrections(tail))
+			l.seq = snap.Seq
+			if len(tail) > 0 {
+				l.seq = tail[len(tail)-1].Seq
+			}
+			l.unsnapshotted = len(tail)
+			return l.truncate(end, size)
+		}
+	}
+
+	entries, end, err := readEntries(io.NewSectionReader(l.file, 0, size), 0)
+	if err != nil {
+		return err
+	}
+	l.tickets = fold(entries)
+	if len(entries) > 0 {
+		l.seq = entries[len(entries)-1].Seq
+	}
+	l.unsnapshotted = len(entries)
+	return l.truncate(end, size)
+}
+
+// truncate cuts a partly written entry off the end of the log.
+func (l *Ledger) truncate(end, size int64) error {
+	l.size = end
+	if end == size {
+		return nil
+	}
+	level.Warn(l.logger).Log("msg", "discarding partly written entry", "bytes", size-end)
+	return l.file.Truncate(end)
+}
+
+// Tickets returns ids' tickets, in the order asked for.
+func (l *Ledger) Tickets(ids ...int) []Tickets {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	tickets := make([]Tickets, len(ids))
+	for i, id := range ids {
+		tickets[i] = Tickets{id, l.tickets[id]}
+	}
+	return tickets
+}
+
+// All returns the tickets of every player holding any, ordered by id.
+func (l *Ledger) All() []Tickets {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return sorted(l.tickets)
+}
+
+// Record logs changes, all or none of them, and then makes them. None may be
+// a correction. It returns each changed player's tickets straight after
+// their change, in order, where a reset contributes every player it took
+// tickets from, with none; and the number of the last entry logged.
+func (l *Ledger) Record(changes ...Change) ([]Tickets, int64, error) {
+	if len(changes) == 0 {
+		return nil, 0, ErrNoChanges
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now().UTC()
+	entries := make([]Entry, len(changes))
+	for i, c := range changes {
+		if !ticketChange(c.Kind) {
+			return nil, 0, apierror.WithDetails(ErrInvalidChange, map[string]string{"kind": string(c.Kind)})
+		}
+		entries[i] = Entry{Seq: l.seq + int64(i) + 1, Time: now, Change: Change{Kind: c.Kind, Id: c.Id, Amount: c.Amount}}
+	}
+	if err := l.append(entries...); err != nil {
+		return nil, 0, err
+	}
+
+	var tickets []Tickets
+	for _, e := range entries {
+		if e.Kind == ChangeReset {
+			for _, t := range sorted(l.tickets) {
+				tickets = append(tickets, Tickets{t.Id, 0})
+			}
+		}
+		e.apply(l.tickets)
+		if e.Kind != ChangeReset {
+			tickets = append(tickets, Tickets{e.Id, l.tickets[e.Id]})
+		}
+	}
+	if l.unsnapshotted >= l.storage.SnapshotEvery {
+		l.snapshot()
+	}
+	return tickets, l.seq, nil
+}
+
+// Entries returns the log, or what's kept of it in memory, in order. Given
+// ids, it leaves out entries that can't have moved any of those players'
+// tickets, keeping the corrections of the entries it returns.
+func (l *Ledger) Entries(ids ...int) ([]Entry, error) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	entries, err := l.readAll()
+	if err != nil || len(ids) == 0 {
+		return entries, err
+	}
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	kept := make(map[int64]bool)
+	filtered := []Entry{}
+	for _, e := range entries {
+		keep := e.touches(want)
+		if e.Kind == ChangeCorrected {
+			keep = kept[e.Corrects] || (e.Replacement != nil && e.Replacement.touches(want))
+		}
+		if keep {
+			kept[e.Seq] = true
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+// Correct logs a correction of the entry numbered seq, replacing it with
+// replacement, or voiding it if replacement is nil, and replays the log so
+// that every later entry builds on the corrected one. It returns the tickets
+// of the players the correction left with different tickets, ordered by id.
+// Corrections can't themselves be corrected; correcting the same entry
+// again replaces the earlier correction.
+func (l *Ledger) Correct(seq int64, replacement *Change) ([]Tickets, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if seq < 1 || seq > l.seq {
+		return nil, ErrEntryNotFound
+	}
+	if replacement != nil {
+		if !ticketChange(replacement.Kind) {
+			return nil, ErrInvalidCorrection
+		}
+		replacement = &Change{Kind: replacement.Kind, Id: replacement.Id, Amount: replacement.Amount}
+	}
+	entries, err := l.readAll()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Seq >= seq })
+	if i == len(entries) || entries[i].Seq != seq {
+		return nil, ErrEntryNotFound
+	}
+	if entries[i].Kind == ChangeCorrected {
+		return nil, ErrInvalidCorrection
+	}
+
+	e := Entry{Seq: l.seq + 1, Time: time.Now().UTC(), Change: Change{Kind: ChangeCorrected, Corrects: seq, Replacement: replacement}}
+	if err := l.append(e); err != nil {
+		return nil, err
+	}
+	tickets := l.fold(append(entries, e))
+	changed := diff(l.tickets, tickets)
+	l.tickets = tickets
+	l.snapshot()
+	return changed, nil
+}
+
+// Rebuild replays the whole log, replacing the tickets restored from the
+// snapshot, and snapshots the result. It returns the tickets of the players
+// the replay left with different tickets, ordered by id, which is none
+// unless the snapshot was out of step with the log.
+func (l *Ledger) Rebuild() ([]Tickets, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	entries, err := l.readAll()
+	if err != nil {
+		return nil, err
+	}
+	tickets := l.fold(entries)
+	changed := diff(l.tickets, tickets)
+	l.tickets = tickets
+	if l.file != nil {
+		if err := l.writeSnapshot(); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// Close snapshots the tickets, if anything was logged since the last
+// snapshot, and closes the log.
+func (l *Ledger) Close(context.Context) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	if l.unsnapshotted > 0 {
+		l.snapshot()
+	}
+	return l.file.Close()
+}
+
+// append logs entries, all or none of them.
+func (l *Ledger) append(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if l.file == nil {
+		l.entries = append(l.entries, entries...)
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		_, err := l.file.Write(buf.Bytes())
+		if err == nil {
+			err = l.file.Sync()
+		}
+		if err != nil {
+			// Whatever part was written mustn't be read back as logged.
+			if terr := l.file.Truncate(l.size); terr != nil {
+				level.Error(l.logger).Log("msg", "can't discard failed append", "err", terr)
+			}
+			return err
+		}
+		l.size += int64(buf.Len())
+	}
+	l.seq = entries[len(entries)-1].Seq
+	l.unsnapshotted += len(entries)
+	return nil
+}
+
+func (l *Ledger) readAll() ([]Entry, error) {
+	if l.file == nil {
+		return append([]Entry{}, l.entries...), nil
+	}
+	entries, _, err := readEntries(io.NewSectionReader(l.file, 0, l.size), 0)
+	return entries, err
+}
+
+// snapshot writes a snapshot, logging rather than returning a failure: the
+// log still holds every change, so the next start just replays more of it.
+func (l *Ledger) snapshot() {
+	if l.file == nil {
+		l.compact()
+		l.unsnapshotted = 0
+		return
+	}
+	if err := l.writeSnapshot(); err != nil {
+		level.Warn(l.logger).Log("msg", "can't write snapshot", "err", err)
+	}
+}
+
+// compact drops the in-memory entries up to the previous snapshot, folding
+// them into base, and remembers the current tickets as the next place to
+// cut. It keeps them all this time if a later entry corrects one of them,
+// since the correction couldn't be replayed without it.
+func (l *Ledger) compact() {
+	if l.snapTickets != nil {
+		i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].Seq > l.snapSeq })
+		if kept := l.entries[i:]; !correctsBefore(kept, l.snapSeq) {
+			l.base = l.snapTickets
+			l.entries = append([]Entry{}, kept...)
+		}
+	}
+	l.snapTickets = make(map[int]int, len(l.tickets))
+	for id, t := range l.tickets {
+		l.snapTickets[id] = t
+	}
+	l.snapSeq = l.seq
+}
+
+type snapshot struct {
+	Seq     int64     `json:"seq"`
+	Offset  int64     `json:"offset"`
+	Time    time.Time `json:"time"`
+	Tickets []Tickets `json:"tickets"`
+}
+
+// writeSnapshot replaces the snapshot with the current tickets, through a
+// temporary file so that a crash leaves either the old snapshot or the new
+// one.
+func (l *Ledger) writeSnapshot() error {
+	data, err := json.Marshal(snapshot{Seq: l.seq, Offset: l.size, Time: time.Now().UTC(), Tickets: sorted(l.tickets)})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.storage.Dir, snapshotFile)
+	tmp, err := os.CreateTemp(l.storage.Dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	l.unsnapshotted = 0
+	return nil
+}
+
+func (l *Ledger) readSnapshot() (snapshot, error) {
+	var snap snapshot
+	data, err := os.ReadFile(filepath.Join(l.storage.Dir, snapshotFile))
+	if err != nil {
+		return snap, err
+	}
+	err = json.Unmarshal(data, &snap)
+	return snap, err
+}
+
+// readEntries reads the entries in r, which starts at offset in the log. It
+// returns the offset just past the last whole entry; anything after it was
+// cut short while being written.
+func readEntries(r io.Reader, offset int64) ([]Entry, int64, error) {
+	entries := []Entry{}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return entries, offset, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, 0, fmt.Errorf("entry at byte %d: %w", offset, err)
+		}
+		entries = append(entries, e)
+		offset += int64(len(line))
+	}
+}
+
+// corrections maps the entries corrected in entries to their latest
+// replacements; a nil replacement voids the entry.
+func corrections(entries []Entry) map[int64]*Change {
+	c := make(map[int64]*Change)
+	for _, e := range entries {
+		if e.Kind == ChangeCorrected {
+			c[e.Corrects] = e.Replacement
+		}
+	}
+	return c
+}
+
+// correctsBefore reports whether any of entries corrects an entry numbered
+// seq or lower.
+func correctsBefore(entries []Entry, seq int64) bool {
+	for _, e := range entries {
+		if e.Kind == ChangeCorrected && e.Corrects <= seq {
+			return true
+		}
+	}
+	return false
+}
+
+// replay makes the changes in entries, in order, with the corrected ones
+// swapped for their replacements.
+func replay(tickets map[int]int, entries []Entry, corrections map[int64]*Change) {
+	for _, e := range entries {
+		c := e.Change
+		if r, corrected := corrections[e.Seq]; corrected {
+			if r == nil {
+				continue
+			}
+			c = *r
+		}
+		c.apply(tickets)
+	}
+}
+
+// fold replays entries, the whole log kept, from the tickets the entries
+// dropped before them left.
+func (l *Ledger) fold(entries []Entry) map[int]int {
+	tickets := make(map[int]int, len(l.base))
+	for id, t := range l.base {
+		tickets[id] = t
+	}
+	replay(tickets, entries, corrections(entries))
+	return tickets
+}
+
+// fold replays entries, a whole log, from nothing.
+func fold(entries []Entry) map[int]int {
+	tickets := make(map[int]int)
+	replay(tickets, entries, corrections(entries))
+	return tickets
+}
+
+// ticketChange reports whether k is a kind of change made to tickets, as
+// opposed to a correction.
+func ticketChange(k ChangeKind) bool {
+	switch k {
+	case ChangeIncremented, ChangeSet, ChangeAdjusted, ChangeReset:
+		return true
+	}
+	return false
+}
+
+// diff returns the tickets in after of every player whose tickets aren't the
+// same as in before, ordered by id.
+func diff(before, after map[int]int) []Tickets {
+	changed := []Tickets{}
+	for id, t := range after {
+		if before[id] != t {
+			changed = append(changed, Tickets{id, t})
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			changed = append(changed, Tickets{id, 0})
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Id < changed[j].Id })
+	return changed
+}
+
+func sorted(tickets map[int]int) []Tickets {
+	out := make([]Tickets, 0, len(tickets))
+	for id, t := range tickets {
+		out = append(out, Tickets{id, t})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Id < out[j].Id })
+	return out
+}
@@ -0,0 +1,391 @@
+package ticketsvc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func openLedger(t *testing.T, s Storage) *Ledger {
+	t.Helper()
+	l, err := OpenLedger(s, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func record(t *testing.T, l *Ledger, changes ...Change) {
+	t.Helper()
+	if _, _, err := l.Record(changes...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantTickets(t *testing.T, l *Ledger, want ...Tickets) {
+	t.Helper()
+	if want == nil {
+		want = []Tickets{}
+	}
+	if got := l.All(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tickets = %v, want %v", got, want)
+	}
+}
+
+func readSnapshot(t *testing.T, dir string) snapshot {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func writeSnapshot(t *testing.T, dir string, snap snapshot) {
+	t.Helper()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func set(id, n int) Change { return Change{Kind: ChangeSet, Id: id, Amount: n} }
+func inc(id int) Change    { return Change{Kind: ChangeIncremented, Id: id, Amount: 1} }
+
+func TestLedgerReplaysAfterSnapshot(t *testing.T) {
+	s := Storage{Dir: t.TempDir(), SnapshotEvery: 2}
+	l := openLedger(t, s)
+	record(t, l, set(1, 5), inc(1))
+	record(t, l, inc(1))
+	snap := readSnapshot(t, s.Dir)
+	if snap.Seq != 2 {
+		t.Fatalf("snapshot seq = %d, want 2", snap.Seq)
+	}
+
+	// opening without closing, as after a crash, must restore from the
+	// snapshot and only replay what came after it: marking the snapshot
+	// shows which was used
+	snap.Tickets = append(snap.Tickets, Tickets{Id: 9, Tickets: 4})
+	writeSnapshot(t, s.Dir, snap)
+	reopened := openLedger(t, s)
+	defer reopened.Close(context.Background())
+	wantTickets(t, reopened, Tickets{1, 7}, Tickets{9, 4})
+
+	// and go on numbering after the last entry
+	record(t, reopened, inc(2))
+	entries, err := reopened.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; len(entries) != 4 || last.Seq != 4 {
+		t.Errorf("entries = %v, want the new one numbered 4", entries)
+	}
+}
+
+func TestLedgerCorrectionBeforeSnapshotReplaysAll(t *testing.T) {
+	s := Storage{Dir: t.TempDir(), SnapshotEvery: 2}
+	l := openLedger(t, s)
+	record(t, l, set(1, 10), inc(1))
+	stale := readSnapshot(t, s.Dir)
+	if _, err := l.Correct(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	wantTickets(t, l, Tickets{1, 1})
+
+	// a crash after logging the correction but before snapshotting it
+	// leaves a snapshot that the correction makes wrong, so it must not be
+	// used; the marker shows whether it was
+	stale.Tickets = append(stale.Tickets, Tickets{Id: 9, Tickets: 4})
+	writeSnapshot(t, s.Dir, stale)
+	reopened := openLedger(t, s)
+	defer reopened.Close(context.Background())
+	wantTickets(t, reopened, Tickets{1, 1})
+}
+
+func TestLedgerCorrect(t *testing.T) {
+	for _, s := range []Storage{
+		{SnapshotEvery: 1000},
+		{Dir: t.TempDir(), SnapshotEvery: 1000},
+	} {
+		name := "memory"
+		if s.Dir != "" {
+			name = "file"
+		}
+		t.Run(name, func(t *testing.T) {
+			l := openLedger(t, s)
+			defer l.Close(context.Background())
+			record(t, l, set(1, 10), inc(1), inc(2), Change{Kind: ChangeAdjusted, Id: 1, Amount: -3})
+			wantTickets(t, l, Tickets{1, 8}, Tickets{2, 1})
+
+			// voiding the set leaves the later changes building on nothing
+			changed, err := l.Correct(1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []Tickets{{1, 0}}; !reflect.DeepEqual(changed, want) {
+				t.Errorf("void changed = %v, want %v", changed, want)
+			}
+			wantTickets(t, l, Tickets{2, 1})
+
+			// correcting it again replaces the void
+			replacement := set(1, 4)
+			if changed, err = l.Correct(1, &replacement); err != nil {
+				t.Fatal(err)
+			}
+			if want := []Tickets{{1, 2}}; !reflect.DeepEqual(changed, want) {
+				t.Errorf("replace changed = %v, want %v", changed, want)
+			}
+			wantTickets(t, l, Tickets{1, 2}, Tickets{2, 1})
+
+			// a replacement may move someone else's tickets instead
+			replacement = set(3, 4)
+			if changed, err = l.Correct(1, &replacement); err != nil {
+				t.Fatal(err)
+			}
+			if want := []Tickets{{1, 0}, {3, 4}}; !reflect.DeepEqual(changed, want) {
+				t.Errorf("replace with another player changed = %v, want %v", changed, want)
+			}
+
+			if _, err := l.Correct(5, nil); !errors.Is(err, ErrInvalidCorrection) {
+				t.Errorf("correcting a correction err = %v, want %v", err, ErrInvalidCorrection)
+			}
+			if _, err := l.Correct(99, nil); !errors.Is(err, ErrEntryNotFound) {
+				t.Errorf("correcting a missing entry err = %v, want %v", err, ErrEntryNotFound)
+			}
+			correction := Change{Kind: ChangeCorrected, Corrects: 2}
+			if _, err := l.Correct(2, &correction); !errors.Is(err, ErrInvalidCorrection) {
+				t.Errorf("correcting with a correction err = %v, want %v", err, ErrInvalidCorrection)
+			}
+
+			// the entries for a player keep the corrections of theirs
+			entries, err := l.Entries(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Seq != 3 {
+				t.Errorf("Entries(2) = %v, want only the increment", entries)
+			}
+			if entries, err = l.Entries(3); err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Seq != 7 {
+				t.Errorf("Entries(3) = %v, want the correction that moved their tickets", entries)
+			}
+		})
+	}
+}
+
+func TestLedgerDiscardsTornEntry(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		removeSnapshot bool
+	}{
+		{"after the snapshot", false},
+		{"without a snapshot", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := Storage{Dir: t.TempDir(), SnapshotEvery: 1000}
+			l := openLedger(t, s)
+			record(t, l, set(1, 3), inc(1))
+			if err := l.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if tc.removeSnapshot {
+				os.Remove(filepath.Join(s.Dir, snapshotFile))
+			}
+			f, err := os.OpenFile(filepath.Join(s.Dir, logFile), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(`{"seq":3,"time":"2026-`)
+			f.Close()
+
+			l = openLedger(t, s)
+			wantTickets(t, l, Tickets{1, 4})
+			record(t, l, inc(1))
+			if err := l.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// the torn entry is gone and the next one took its place
+			l = openLedger(t, s)
+			defer l.Close(context.Background())
+			entries, err := l.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 3 || entries[2].Seq != 3 || entries[2].Kind != ChangeIncremented {
+				t.Errorf("entries = %v, want the new increment numbered 3", entries)
+			}
+			wantTickets(t, l, Tickets{1, 5})
+		})
+	}
+}
+
+func TestLedgerRefusesCorruptEntry(t *testing.T) {
+	s := Storage{Dir: t.TempDir(), SnapshotEvery: 1000}
+	l := openLedger(t, s)
+	record(t, l, set(1, 3))
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(s.Dir, snapshotFile))
+
+	// a whole line that can't be read wasn't cut short by a crash, so it
+	// mustn't be dropped silently
+	f, err := os.OpenFile(filepath.Join(s.Dir, logFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not an entry\n")
+	f.Close()
+	if _, err := OpenLedger(s, log.NewNopLogger()); err == nil {
+		t.Error("OpenLedger with a corrupt entry succeeded, want an error")
+	}
+}
+
+func TestLedgerRebuild(t *testing.T) {
+	s := Storage{Dir: t.TempDir(), SnapshotEvery: 3}
+	l := openLedger(t, s)
+	record(t, l, set(1, 6), set(2, 2), inc(3))
+	record(t, l, Change{Kind: ChangeReset})
+	record(t, l, inc(1), inc(2), Change{Kind: ChangeAdjusted, Id: 2, Amount: 4})
+	replacement := set(4, 9)
+	if _, err := l.Correct(4, &replacement); err != nil {
+		t.Fatal(err)
+	}
+	record(t, l, inc(4), inc(3))
+	live := l.All()
+
+	changed, err := l.Rebuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("Rebuild of a ledger in step with its log changed %v, want nothing", changed)
+	}
+	wantTickets(t, l, live...)
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a snapshot out of step with the log is what Rebuild is for
+	snap := readSnapshot(t, s.Dir)
+	snap.Tickets = []Tickets{{1, 50}, {8, 1}}
+	writeSnapshot(t, s.Dir, snap)
+	l = openLedger(t, s)
+	defer l.Close(context.Background())
+	if changed, err = l.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) == 0 {
+		t.Error("Rebuild of a ledger with a wrong snapshot changed nothing")
+	}
+	wantTickets(t, l, live...)
+
+	// and the rebuilt snapshot is used from then on
+	reopened := openLedger(t, s)
+	defer reopened.Close(context.Background())
+	wantTickets(t, reopened, live...)
+}
+
+func TestLedgerRecordNumbersTheBatch(t *testing.T) {
+	l := openLedger(t, Storage{SnapshotEvery: 1000})
+	record(t, l, set(1, 2))
+	tickets, seq, err := l.Record(inc(1), inc(2), set(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 4 {
+		t.Errorf("seq = %d, want the last of the batch, 4", seq)
+	}
+	if want := []Tickets{{1, 3}, {2, 1}, {1, 0}}; !reflect.DeepEqual(tickets, want) {
+		t.Errorf("tickets = %v, want %v", tickets, want)
+	}
+
+	// a batch with a change that can't be recorded logs none of it
+	correction := Change{Kind: ChangeCorrected, Corrects: 2}
+	if _, _, err := l.Record(inc(1), correction); !errors.Is(err, ErrInvalidChange) {
+		t.Errorf("Record with a correction err = %v, want %v", err, ErrInvalidChange)
+	}
+	if _, _, err := l.Record(); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Record of nothing err = %v, want %v", err, ErrNoChanges)
+	}
+	wantTickets(t, l, Tickets{2, 1})
+	if entries, _ := l.Entries(); len(entries) != 4 {
+		t.Errorf("entries = %v, want only the 4 recorded", entries)
+	}
+}
+
+func seqs(t *testing.T, l *Ledger) []int64 {
+	t.Helper()
+	entries, err := l.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs := make([]int64, len(entries))
+	for i, e := range entries {
+		seqs[i] = e.Seq
+	}
+	return seqs
+}
+
+func TestLedgerInMemoryCompacts(t *testing.T) {
+	l := openLedger(t, Storage{SnapshotEvery: 2})
+	for i := 0; i < 10; i++ {
+		record(t, l, inc(i%3))
+	}
+	// the snapshot at 10 drops the entries up to the one at 8
+	if got, want := seqs(t, l), []int64{9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	wantTickets(t, l, Tickets{0, 4}, Tickets{1, 3}, Tickets{2, 3})
+	if _, err := l.Correct(3, nil); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Correct of a dropped entry err = %v, want %v", err, ErrEntryNotFound)
+	}
+
+	// corrections of kept entries replay from the dropped ones' tickets
+	if _, err := l.Correct(9, &Change{Kind: ChangeSet, Id: 2, Amount: 7}); err != nil {
+		t.Fatal(err)
+	}
+	wantTickets(t, l, Tickets{0, 4}, Tickets{1, 3}, Tickets{2, 7})
+	if changed, err := l.Rebuild(); err != nil || len(changed) != 0 {
+		t.Errorf("Rebuild = %v, %v, want no changes", changed, err)
+	}
+}
+
+func TestLedgerInMemoryKeepsCorrectedEntries(t *testing.T) {
+	l := openLedger(t, Storage{SnapshotEvery: 2})
+	record(t, l, set(1, 10), inc(1))
+	record(t, l, inc(2))
+	// correcting 1 snapshots, but dropping 1 and 2 would lose what the
+	// correction replaces
+	if _, err := l.Correct(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := seqs(t, l), []int64{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	// once the correction is itself dropped, so can they be
+	record(t, l, inc(1), inc(2))
+	if got, want := seqs(t, l), []int64{5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	wantTickets(t, l, Tickets{1, 2}, Tickets{2, 2})
+	if changed, err := l.Rebuild(); err != nil || len(changed) != 0 {
+		t.Errorf("Rebuild = %v, %v, want no changes", changed, err)
+	}
+}
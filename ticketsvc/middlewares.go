@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
 )
 
 type ServiceMiddleware func(Service) Service
@@ -27,21 +29,63 @@ func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
 
 func (mw *loggingMiddleware) Get(ctx context.Context, ids ...int) (t []Tickets, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Get", "ids", fmt.Sprintf("%v", ids), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Get", "ids", fmt.Sprintf("%v", ids), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.Get(ctx, ids...)
 }
 
+func (mw *loggingMiddleware) GetAll(ctx context.Context) (t []Tickets, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetAll", "players", len(t), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetAll(ctx)
+}
+
 func (mw *loggingMiddleware) Set(ctx context.Context, tickets ...Tickets) (t []Tickets, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Set", "data", fmt.Sprintf("%v", tickets), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Set", "data", fmt.Sprintf("%v", tickets), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.Set(ctx, tickets...)
 }
 
 func (mw *loggingMiddleware) Increment(ctx context.Context, ids ...int) (t []Tickets, err error) {
 	defer func(begin time.Time) {
-		level.Info(mw.logger).Log("method", "Increment", "ids", fmt.Sprintf("%v", ids), "duration", time.Since(begin), "err", err)
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Increment", "ids", fmt.Sprintf("%v", ids), "duration", time.Since(begin), "err", err)
 	}(time.Now())
 	return mw.next.Increment(ctx, ids...)
 }
+
+func (mw *loggingMiddleware) Adjust(ctx context.Context, id, delta int) (t Tickets, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Adjust", "id", id, "delta", delta, "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Adjust(ctx, id, delta)
+}
+
+func (mw *loggingMiddleware) Reset(ctx context.Context) (t []Tickets, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Reset", "players", len(t), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Reset(ctx)
+}
+
+func (mw *loggingMiddleware) GetLog(ctx context.Context, ids ...int) (e []Entry, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetLog", "ids", fmt.Sprintf("%v", ids), "entries", len(e), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetLog(ctx, ids...)
+}
+
+func (mw *loggingMiddleware) Correct(ctx context.Context, seq int64, replacement *Change) (t []Tickets, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Correct", "seq", seq, "replacement", fmt.Sprintf("%+v", replacement), "changed", fmt.Sprintf("%v", t), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Correct(ctx, seq, replacement)
+}
+
+func (mw *loggingMiddleware) Record(ctx context.Context, changes ...Change) (r Recorded, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Record", "changes", fmt.Sprintf("%+v", changes), "seq", r.Seq, "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Record(ctx, changes...)
+}
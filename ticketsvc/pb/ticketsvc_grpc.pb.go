@@ -0,0 +1,393 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: ticketsvc.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// TicketsClient is the client API for Tickets service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TicketsClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	Adjust(ctx context.Context, in *AdjustRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	GetLog(ctx context.Context, in *GetLogRequest, opts ...grpc.CallOption) (*LogReply, error)
+	Correct(ctx context.Context, in *CorrectRequest, opts ...grpc.CallOption) (*TicketsReply, error)
+	Record(ctx context.Context, in *RecordRequest, opts ...grpc.CallOption) (*RecordReply, error)
+}
+
+type ticketsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTicketsClient(cc grpc.ClientConnInterface) TicketsClient {
+	return &ticketsClient{cc}
+}
+
+func (c *ticketsClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/GetAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Increment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Adjust(ctx context.Context, in *AdjustRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Adjust", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Reset", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) GetLog(ctx context.Context, in *GetLogRequest, opts ...grpc.CallOption) (*LogReply, error) {
+	out := new(LogReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/GetLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Correct(ctx context.Context, in *CorrectRequest, opts ...grpc.CallOption) (*TicketsReply, error) {
+	out := new(TicketsReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Correct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ticketsClient) Record(ctx context.Context, in *RecordRequest, opts ...grpc.CallOption) (*RecordReply, error) {
+	out := new(RecordReply)
+	err := c.cc.Invoke(ctx, "/ticketsvc.Tickets/Record", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TicketsServer is the server API for Tickets service.
+// All implementations must embed UnimplementedTicketsServer
+// for forward compatibility
+type TicketsServer interface {
+	Get(context.Context, *GetRequest) (*TicketsReply, error)
+	GetAll(context.Context, *GetAllRequest) (*TicketsReply, error)
+	Set(context.Context, *SetRequest) (*TicketsReply, error)
+	Increment(context.Context, *IncrementRequest) (*TicketsReply, error)
+	Adjust(context.Context, *AdjustRequest) (*TicketsReply, error)
+	Reset(context.Context, *ResetRequest) (*TicketsReply, error)
+	GetLog(context.Context, *GetLogRequest) (*LogReply, error)
+	Correct(context.Context, *CorrectRequest) (*TicketsReply, error)
+	Record(context.Context, *RecordRequest) (*RecordReply, error)
+	mustEmbedUnimplementedTicketsServer()
+}
+
+// UnimplementedTicketsServer must be embedded to have forward compatible implementations.
+type UnimplementedTicketsServer struct {
+}
+
+func (UnimplementedTicketsServer) Get(context.Context, *GetRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedTicketsServer) GetAll(context.Context, *GetAllRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAll not implemented")
+}
+func (UnimplementedTicketsServer) Set(context.Context, *SetRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedTicketsServer) Increment(context.Context, *IncrementRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedTicketsServer) Adjust(context.Context, *AdjustRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Adjust not implemented")
+}
+func (UnimplementedTicketsServer) Reset(context.Context, *ResetRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reset not implemented")
+}
+func (UnimplementedTicketsServer) GetLog(context.Context, *GetLogRequest) (*LogReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLog not implemented")
+}
+func (UnimplementedTicketsServer) Correct(context.Context, *CorrectRequest) (*TicketsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Correct not implemented")
+}
+func (UnimplementedTicketsServer) Record(context.Context, *RecordRequest) (*RecordReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Record not implemented")
+}
+func (UnimplementedTicketsServer) mustEmbedUnimplementedTicketsServer() {}
+
+// UnsafeTicketsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TicketsServer will
+// result in compilation errors.
+type UnsafeTicketsServer interface {
+	mustEmbedUnimplementedTicketsServer()
+}
+
+func RegisterTicketsServer(s grpc.ServiceRegistrar, srv TicketsServer) {
+	s.RegisterService(&Tickets_ServiceDesc, srv)
+}
+
+func _Tickets_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_GetAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).GetAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/GetAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).GetAll(ctx, req.(*GetAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Increment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Adjust_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdjustRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Adjust(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Adjust",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Adjust(ctx, req.(*AdjustRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Reset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Reset",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Reset(ctx, req.(*ResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_GetLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).GetLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/GetLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).GetLog(ctx, req.(*GetLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Correct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CorrectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Correct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Correct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Correct(ctx, req.(*CorrectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Tickets_Record_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TicketsServer).Record(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ticketsvc.Tickets/Record",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TicketsServer).Record(ctx, req.(*RecordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Tickets_ServiceDesc is the grpc.ServiceDesc for Tickets service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Tickets_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ticketsvc.Tickets",
+	HandlerType: (*TicketsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Tickets_Get_Handler,
+		},
+		{
+			MethodName: "GetAll",
+			Handler:    _Tickets_GetAll_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Tickets_Set_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _Tickets_Increment_Handler,
+		},
+		{
+			MethodName: "Adjust",
+			Handler:    _Tickets_Adjust_Handler,
+		},
+		{
+			MethodName: "Reset",
+			Handler:    _Tickets_Reset_Handler,
+		},
+		{
+			MethodName: "GetLog",
+			Handler:    _Tickets_GetLog_Handler,
+		},
+		{
+			MethodName: "Correct",
+			Handler:    _Tickets_Correct_Handler,
+		},
+		{
+			MethodName: "Record",
+			Handler:    _Tickets_Record_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "ticketsvc.proto",
+}
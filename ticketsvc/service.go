@@ -6,12 +6,20 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
 )
 
 type Service interface {
 	Get(ctx context.Context, ids ...int) ([]Tickets, error)
+	GetAll(ctx context.Context) ([]Tickets, error)
 	Set(ctx context.Context, tickets ...Tickets) ([]Tickets, error)
 	Increment(ctx context.Context, ids ...int) ([]Tickets, error)
+	Adjust(ctx context.Context, id, delta int) (Tickets, error)
+	Reset(ctx context.Context) ([]Tickets, error)
+	GetLog(ctx context.Context, ids ...int) ([]Entry, error)
+	Correct(ctx context.Context, seq int64, replacement *Change) ([]Tickets, error)
+	Record(ctx context.Context, changes ...Change) (Recorded, error)
 }
 
 type Tickets struct {
@@ -23,51 +31,119 @@ func (t Tickets) String() string {
 	return fmt.Sprintf("{id: %v, tickets: %v}", t.Id, t.Tickets)
 }
 
+// Recorded is what recording changes did: Seq numbers the last entry
+// logged, and Tickets holds each changed player's tickets straight after
+// their change, in order.
+type Recorded struct {
+	Seq     int64     `json:"seq"`
+	Tickets []Tickets `json:"tickets"`
+}
+
 type ticketService struct {
-	tickets map[int]int
-	logger  log.Logger
+	ledger *Ledger
+	logger log.Logger
 }
 
-func NewService(logger log.Logger) Service {
+// NewService makes a Service whose tickets are kept by ledger.
+func NewService(logger log.Logger, ledger *Ledger) Service {
 	return &ticketService{
-		logger:  logger,
-		tickets: make(map[int]int),
+		logger: logger,
+		ledger: ledger,
 	}
 }
 
 // Get implements Service
 func (svc *ticketService) Get(ctx context.Context, ids ...int) ([]Tickets, error) {
-	tickets := make([]Tickets, len(ids))
-	for idx, id := range ids {
-		t := svc.tickets[id]
-		tickets[idx] = Tickets{id, t}
-		level.Debug(svc.logger).Log("debug", "get tickets", "id", id, "tickets", t)
+	tickets := svc.ledger.Tickets(ids...)
+	for _, t := range tickets {
+		level.Debug(svc.logger).Log("debug", "get tickets", "id", t.Id, "tickets", t.Tickets)
 	}
 	return tickets, nil
 }
 
+// GetAll implements Service. Only players holding tickets are returned,
+// ordered by id.
+func (svc *ticketService) GetAll(ctx context.Context) ([]Tickets, error) {
+	return svc.ledger.All(), nil
+}
+
 // Increment implements Service
 func (svc *ticketService) Increment(ctx context.Context, ids ...int) ([]Tickets, error) {
-	tickets := make([]Tickets, len(ids))
-	for idx, id := range ids {
-		svc.tickets[id] += 1
-		t := svc.tickets[id]
-		tickets[idx] = Tickets{id, t}
-		level.Debug(svc.logger).Log("debug", "increment tickets", "id", id, "tickets", t)
+	changes := make([]Change, len(ids))
+	for i, id := range ids {
+		changes[i] = Change{Kind: ChangeIncremented, Id: id, Amount: 1}
 	}
-	return tickets, nil
+	return svc.record("increment tickets", changes...)
 }
 
-// Set implements Service
+// Set implements Service. Tickets below zero are set to zero.
 func (svc *ticketService) Set(ctx context.Context, tickets ...Tickets) ([]Tickets, error) {
+	changes := make([]Change, len(tickets))
+	for i, t := range tickets {
+		changes[i] = Change{Kind: ChangeSet, Id: t.Id, Amount: t.Tickets}
+	}
+	return svc.record("set tickets", changes...)
+}
+
+// Adjust implements Service. Taking away more tickets than the player holds
+// leaves them with none.
+func (svc *ticketService) Adjust(ctx context.Context, id, delta int) (Tickets, error) {
+	tickets, err := svc.record("adjust tickets", Change{Kind: ChangeAdjusted, Id: id, Amount: delta})
+	if err != nil {
+		return Tickets{}, err
+	}
+	return tickets[0], nil
+}
+
+// Reset implements Service
+func (svc *ticketService) Reset(ctx context.Context) ([]Tickets, error) {
+	return svc.record("reset tickets", Change{Kind: ChangeReset})
+}
+
+// GetLog implements Service
+func (svc *ticketService) GetLog(ctx context.Context, ids ...int) ([]Entry, error) {
+	return svc.ledger.Entries(ids...)
+}
+
+// Correct implements Service
+func (svc *ticketService) Correct(ctx context.Context, seq int64, replacement *Change) ([]Tickets, error) {
+	tickets, err := svc.ledger.Correct(seq, replacement)
+	if err != nil {
+		return nil, err
+	}
 	for _, t := range tickets {
-		ticks := t.Tickets
-		if ticks <= 0 {
-			delete(svc.tickets, t.Id)
-		} else {
-			svc.tickets[t.Id] = t.Tickets
+		level.Debug(svc.logger).Log("debug", "correct tickets", "seq", seq, "id", t.Id, "tickets", t.Tickets)
+	}
+	return tickets, nil
+}
+
+// Record implements Service. The changes are logged as consecutive entries,
+// all or none of them, so a caller making several changes at once, such as
+// a spin's, never leaves only some of them made. Resets aren't taken, as
+// they need the admin role that Reset checks for.
+func (svc *ticketService) Record(ctx context.Context, changes ...Change) (Recorded, error) {
+	for _, c := range changes {
+		if c.Kind == ChangeReset {
+			return Recorded{}, apierror.WithDetails(ErrInvalidChange, map[string]string{"kind": string(c.Kind)})
 		}
-		level.Debug(svc.logger).Log("debug", "set tickets", "id", t.Id, "tickets", t.Tickets)
+	}
+	tickets, seq, err := svc.ledger.Record(changes...)
+	if err != nil {
+		return Recorded{}, err
+	}
+	for _, t := range tickets {
+		level.Debug(svc.logger).Log("debug", "record changes", "seq", seq, "id", t.Id, "tickets", t.Tickets)
+	}
+	return Recorded{Seq: seq, Tickets: tickets}, nil
+}
+
+func (svc *ticketService) record(msg string, changes ...Change) ([]Tickets, error) {
+	tickets, _, err := svc.ledger.Record(changes...)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tickets {
+		level.Debug(svc.logger).Log("debug", msg, "id", t.Id, "tickets", t.Tickets)
 	}
 	return tickets, nil
 }
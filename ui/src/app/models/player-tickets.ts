@@ -3,3 +3,8 @@ export interface PlayerWithTickets {
   name: string;
   tickets: number;
 }
+
+export interface PlayersWithTicketsResponse {
+  players: PlayerWithTickets[];
+  error: string;
+}
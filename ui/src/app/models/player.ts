@@ -1,6 +1,9 @@
 export interface Player {
   id: number;
   name: string;
+  externalId?: string;
+  inactive?: boolean;
+  created?: string;
 }
 
 export interface PlayerResponse {
@@ -1,3 +1,5 @@
+import { Player } from './player';
+
 export interface SpinResult {
     participantIds: number[],
     winnerId: number
@@ -6,4 +8,10 @@ export interface SpinResult {
 export interface SpinResultResponse {
     result: SpinResult,
     error: string
-}
\ No newline at end of file
+}
+
+export interface SpinWithWinner {
+    result: SpinResult,
+    winner: Player,
+    error: string
+}
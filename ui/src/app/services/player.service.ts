@@ -1,7 +1,9 @@
 import { Injectable } from '@angular/core';
 import { HttpClient } from '@angular/common/http';
 import { map, Observable } from 'rxjs';
+import { environment } from '../../environments/environment';
 import { Player, PlayerResponse, PlayersResponse } from '../models/player';
+import { PlayerWithTickets, PlayersWithTicketsResponse } from '../models/player-tickets';
 
 @Injectable({
   providedIn: 'root',
@@ -11,13 +13,19 @@ export class PlayerService {
 
   public getAllPlayers(): Observable<Player[]> {
     return this.http
-      .get<PlayersResponse>('/players')
+      .get<PlayersResponse>(`${environment.apiUrl}/players`)
+      .pipe(map((response) => response.players));
+  }
+
+  public getAllPlayersWithTickets(): Observable<PlayerWithTickets[]> {
+    return this.http
+      .get<PlayersWithTicketsResponse>(`${environment.apiUrl}/players/with-tickets`)
       .pipe(map((response) => response.players));
   }
 
   public addNewPlayer(name: string): Observable<Player> {
     return this.http
-      .post<PlayerResponse>('/players', {
+      .post<PlayerResponse>(`${environment.apiUrl}/players`, {
         name: name
       })
       .pipe(map((response) => response.player));
@@ -25,7 +33,7 @@ export class PlayerService {
 
   public updatePlayer(player: Player): Observable<Player> {
     return this.http
-      .put<PlayerResponse>('/players', {
+      .put<PlayerResponse>(`${environment.apiUrl}/players`, {
         player: player
       })
       .pipe(map((response) => response.player));
@@ -1,7 +1,8 @@
 import { HttpClient } from '@angular/common/http';
 import { Injectable } from '@angular/core';
 import { map, Observable } from 'rxjs';
-import { SpinResult, SpinResultResponse } from '../models/spin';
+import { environment } from '../../environments/environment';
+import { SpinResult, SpinResultResponse, SpinWithWinner } from '../models/spin';
 
 @Injectable({
   providedIn: 'root',
@@ -11,15 +12,21 @@ export class SpinService {
 
   public spin(participantIds: number[]): Observable<SpinResult> {
     return this.http
-      .post<SpinResultResponse>('/spin', {
+      .post<SpinResultResponse>(`${environment.apiUrl}/spins`, {
         participantIds: participantIds,
       })
       .pipe(map((response) => response.result));
   }
 
+  public spinWithWinner(participantIds: number[]): Observable<SpinWithWinner> {
+    return this.http.post<SpinWithWinner>(`${environment.apiUrl}/spin/winner`, {
+      participantIds: participantIds,
+    });
+  }
+
   public getLastSpin(): Observable<SpinResult> {
     return this.http
-      .get<SpinResultResponse>('/get-last-spin')
+      .get<SpinResultResponse>(`${environment.apiUrl}/spins/last`)
       .pipe(map((response) => response.result));
   }
 }
@@ -2,6 +2,7 @@ import { Injectable } from '@angular/core';
 import { HttpClient, HttpParams } from '@angular/common/http';
 import { Tickets, TicketsResponse } from '../models/tickets';
 import { map, Observable } from 'rxjs';
+import { environment } from '../../environments/environment';
 
 @Injectable({
   providedIn: 'root',
@@ -11,7 +12,7 @@ export class TicketsService {
 
   public getTickets(ids: number[]): Observable<Tickets[]> {
     return this.http
-      .get<TicketsResponse>('/tickets', {
+      .get<TicketsResponse>(`${environment.apiUrl}/tickets`, {
         params: new HttpParams().append('ids', ids.join(',')),
       })
       .pipe(map((response) => response.tickets));
@@ -19,15 +20,15 @@ export class TicketsService {
 
   public incrementTickets(ids: number[]): Observable<Tickets[]> {
     return this.http
-      .post<TicketsResponse>('/tickets/increment', {
-        ids: ids.join(','),
+      .post<TicketsResponse>(`${environment.apiUrl}/tickets/increment`, {
+        ids: ids,
       })
       .pipe(map((response) => response.tickets));
   }
 
   public setTickets(tickets: Tickets[]): Observable<Tickets[]> {
     return this.http
-      .put<TicketsResponse>('/tickets/increment', {
+      .put<TicketsResponse>(`${environment.apiUrl}/tickets`, {
         tickets: tickets,
       })
       .pipe(map((response) => response.tickets));
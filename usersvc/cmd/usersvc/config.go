@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+var ErrMissingSecret = errors.New("auth secret is required to sign session tokens")
+
+type settings struct {
+	config.Service `yaml:",inline"`
+	SessionTTL     time.Duration   `yaml:"sessionTtl" env:"SESSION_TTL" flag:"session-ttl" help:"how long a login session lasts"`
+	Storage        usersvc.Storage `yaml:"storage"`
+	Admin          admin           `yaml:"admin"`
+}
+
+// admin is the account created at startup, so there is someone to create
+// the others.
+type admin struct {
+	Username string `yaml:"username" env:"ADMIN_USERNAME" flag:"admin-username" help:"admin account to create at startup if it doesn't exist"`
+	Password string `yaml:"password" env:"ADMIN_PASSWORD" flag:"admin-password" help:"password for the admin account" secret:"true"`
+}
+
+func (s *settings) Validate() error {
+	if s.Auth.Secret == "" {
+		return ErrMissingSecret
+	}
+	return nil
+}
+
+func defaultSettings() settings {
+	return settings{
+		Service:    config.DefaultService(":8088"),
+		SessionTTL: 12 * time.Hour,
+	}
+}
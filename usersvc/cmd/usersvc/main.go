@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/config"
+	"github.com/jlthompson3259/matspinner/common/health"
+	"github.com/jlthompson3259/matspinner/common/server"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/usersvc"
+)
+
+func main() {
+	cfg := defaultSettings()
+	if err := config.Load("usersvc", &cfg, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger := cfg.Log.NewLogger(os.Stderr)
+
+	shutdownTracing, err := tracing.Setup("usersvc", cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	authenticator, err := cfg.Auth.NewAuthenticator()
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+	bus, err := cfg.Events.NewBus("usersvc", logger)
+	if err != nil {
+		level.Error(logger).Log("error", err)
+		os.Exit(1)
+	}
+
+	var (
+		service   usersvc.Service
+		readiness []health.Check
+	)
+	{
+		base, err := usersvc.NewService(log.With(logger, "component", "service"), auth.NewSigner(cfg.Auth.Secret), cfg.SessionTTL, cfg.Storage)
+		if err != nil {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+		readiness = append(readiness, health.Check{Name: "storage", Check: func(ctx context.Context) error {
+			_, err := base.GetAll(ctx)
+			return err
+		}})
+		service = base
+		authenticator = auth.Revocable(usersvc.SessionEnded(base), authenticator)
+		service = usersvc.LoggingMiddleware(log.With(logger, "component", "loggingMiddleware"))(service)
+	}
+
+	// the first admin account has to come from somewhere
+	if username := cfg.Admin.Username; username != "" {
+		_, err := service.Create(context.Background(), username, cfg.Admin.Password, map[string]auth.Role{usersvc.AllStores: auth.RoleAdmin})
+		if err != nil && !errors.Is(err, usersvc.ErrUserExists) {
+			level.Error(logger).Log("error", err)
+			os.Exit(1)
+		}
+	}
+
+	var (
+		endpoints   = usersvc.MakeServerEndpoints(service)
+		httpHandler = usersvc.MakeHTTPHandler(endpoints, authenticator, cfg.CORS.Policy(), cfg.RateLimits(), log.With(logger, "component", "http"))
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", health.Ready(health.DefaultTimeout, readiness...))
+	mux.Handle("/", httpHandler)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.Server{
+		HTTP:            &http.Server{Addr: cfg.HTTPAddr, Handler: mux},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Logger:          logger,
+		OnClose:         []server.Hook{bus.Close, shutdownTracing},
+	}
+	if err := srv.Run(ctx); err != nil {
+		level.Error(logger).Log("exit", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("exit", "stopped")
+}
@@ -0,0 +1,297 @@
+package usersvc
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+)
+
+type EndpointSet struct {
+	LoginEndpoint         endpoint.Endpoint
+	LogoutEndpoint        endpoint.Endpoint
+	MeEndpoint            endpoint.Endpoint
+	GetAllEndpoint        endpoint.Endpoint
+	CreateEndpoint        endpoint.Endpoint
+	ResetPasswordEndpoint endpoint.Endpoint
+	SetRolesEndpoint      endpoint.Endpoint
+	EndedEndpoint         endpoint.Endpoint
+}
+
+func MakeServerEndpoints(svc Service) EndpointSet {
+	return EndpointSet{
+		LoginEndpoint:         MakeLoginEndpoint(svc),
+		LogoutEndpoint:        MakeLogoutEndpoint(svc),
+		MeEndpoint:            MakeMeEndpoint(svc),
+		GetAllEndpoint:        MakeGetAllEndpoint(svc),
+		CreateEndpoint:        MakeCreateEndpoint(svc),
+		ResetPasswordEndpoint: MakeResetPasswordEndpoint(svc),
+		SetRolesEndpoint:      MakeSetRolesEndpoint(svc),
+		EndedEndpoint:         MakeEndedEndpoint(svc),
+	}
+}
+
+func MakeClientEndpoints(instance string, options ...httptransport.ClientOption) (EndpointSet, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	tgt, err := url.Parse(instance)
+	if err != nil {
+		return EndpointSet{}, err
+	}
+
+	tgt.Path = ""
+	options = append([]httptransport.ClientOption{httptransport.ClientBefore(tracing.ContextToHTTP)}, options...)
+
+	return EndpointSet{
+		LoginEndpoint:         tracing.TraceClient("usersvc.Login")(httptransport.NewClient("POST", tgt, encodeLoginRequest, decodeSessionResponse, options...).Endpoint()),
+		LogoutEndpoint:        tracing.TraceClient("usersvc.Logout")(httptransport.NewClient("POST", tgt, encodeLogoutRequest, decodeEmptyResponse, options...).Endpoint()),
+		MeEndpoint:            tracing.TraceClient("usersvc.Me")(httptransport.NewClient("GET", tgt, encodeMeRequest, decodeMeResponse, options...).Endpoint()),
+		GetAllEndpoint:        tracing.TraceClient("usersvc.GetAll")(httptransport.NewClient("GET", tgt, encodeGetAllRequest, decodeMultiResponse, options...).Endpoint()),
+		CreateEndpoint:        tracing.TraceClient("usersvc.Create")(httptransport.NewClient("POST", tgt, encodeCreateRequest, decodeSingleResponse, options...).Endpoint()),
+		ResetPasswordEndpoint: tracing.TraceClient("usersvc.ResetPassword")(httptransport.NewClient("PUT", tgt, encodeResetPasswordRequest, decodeEmptyResponse, options...).Endpoint()),
+		SetRolesEndpoint:      tracing.TraceClient("usersvc.SetRoles")(httptransport.NewClient("PUT", tgt, encodeSetRolesRequest, decodeSingleResponse, options...).Endpoint()),
+		EndedEndpoint:         tracing.TraceClient("usersvc.Ended")(httptransport.NewClient("GET", tgt, encodeEndedRequest, decodeEndedResponse, options...).Endpoint()),
+	}, nil
+}
+
+// MakeBalancedClientEndpoints makes client endpoints that spread calls over
+// u's instances. Logging in and creating users aren't safe to retry.
+func MakeBalancedClientEndpoints(u *upstream.Upstream, options ...httptransport.ClientOption) EndpointSet {
+	factory := func(method func(EndpointSet) endpoint.Endpoint) sd.Factory {
+		return func(instance string) (endpoint.Endpoint, io.Closer, error) {
+			e, err := MakeClientEndpoints(instance, options...)
+			if err != nil {
+				return nil, nil, err
+			}
+			return method(e), nil, nil
+		}
+	}
+	return EndpointSet{
+		LoginEndpoint:         u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.LoginEndpoint }), false),
+		LogoutEndpoint:        u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.LogoutEndpoint }), true),
+		MeEndpoint:            u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.MeEndpoint }), true),
+		GetAllEndpoint:        u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.GetAllEndpoint }), true),
+		CreateEndpoint:        u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.CreateEndpoint }), false),
+		ResetPasswordEndpoint: u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.ResetPasswordEndpoint }), true),
+		SetRolesEndpoint:      u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.SetRolesEndpoint }), true),
+		EndedEndpoint:         u.Balance(factory(func(e EndpointSet) endpoint.Endpoint { return e.EndedEndpoint }), true),
+	}
+}
+
+func (e *EndpointSet) Login(ctx context.Context, username, password, store string) (Session, error) {
+	request := loginRequest{Username: username, Password: password, Store: store}
+	r, err := e.LoginEndpoint(ctx, request)
+	if err != nil {
+		return Session{}, err
+	}
+	resp := r.(sessionResponse)
+	return resp.Session, nil
+}
+
+func (e *EndpointSet) Logout(ctx context.Context) error {
+	_, err := e.LogoutEndpoint(ctx, logoutRequest{})
+	return err
+}
+
+func (e *EndpointSet) Me(ctx context.Context) (User, auth.Identity, error) {
+	r, err := e.MeEndpoint(ctx, meRequest{})
+	if err != nil {
+		return User{}, auth.Identity{}, err
+	}
+	resp := r.(meResponse)
+	return resp.User, resp.Identity, nil
+}
+
+func (e *EndpointSet) GetAll(ctx context.Context) ([]User, error) {
+	r, err := e.GetAllEndpoint(ctx, getAllRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(multiResponse)
+	return resp.Users, nil
+}
+
+func (e *EndpointSet) Create(ctx context.Context, username, password string, roles map[string]auth.Role) (User, error) {
+	request := createRequest{Username: username, Password: password, Roles: roles}
+	r, err := e.CreateEndpoint(ctx, request)
+	if err != nil {
+		return User{}, err
+	}
+	resp := r.(singleResponse)
+	return resp.User, nil
+}
+
+func (e *EndpointSet) ResetPassword(ctx context.Context, username, password string) error {
+	request := resetPasswordRequest{Username: username, Password: password}
+	_, err := e.ResetPasswordEndpoint(ctx, request)
+	return err
+}
+
+func (e *EndpointSet) SetRoles(ctx context.Context, username string, roles map[string]auth.Role) (User, error) {
+	if roles == nil {
+		// no roles at all, which the API takes as {} but not null
+		roles = map[string]auth.Role{}
+	}
+	request := setRolesRequest{Username: username, Roles: roles}
+	r, err := e.SetRolesEndpoint(ctx, request)
+	if err != nil {
+		return User{}, err
+	}
+	resp := r.(singleResponse)
+	return resp.User, nil
+}
+
+func (e *EndpointSet) Ended(ctx context.Context) (map[string]time.Time, error) {
+	r, err := e.EndedEndpoint(ctx, endedRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resp := r.(endedResponse)
+	return resp.Sessions, nil
+}
+
+func MakeLoginEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(loginRequest)
+		session, err := svc.Login(ctx, req.Username, req.Password, req.Store)
+		return sessionResponse{session, err}, nil
+	}
+}
+
+func MakeLogoutEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		err := svc.Logout(ctx)
+		return emptyResponse{err}, nil
+	}
+}
+
+func MakeMeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		user, id, err := svc.Me(ctx)
+		return meResponse{user, id, err}, nil
+	}
+}
+
+func MakeGetAllEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		users, err := svc.GetAll(ctx)
+		return multiResponse{users, err}, nil
+	}
+}
+
+func MakeCreateEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(createRequest)
+		user, err := svc.Create(ctx, req.Username, req.Password, req.Roles)
+		return singleResponse{user, err}, nil
+	}
+}
+
+func MakeResetPasswordEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(resetPasswordRequest)
+		err := svc.ResetPassword(ctx, req.Username, req.Password)
+		return emptyResponse{err}, nil
+	}
+}
+
+func MakeSetRolesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(setRolesRequest)
+		user, err := svc.SetRoles(ctx, req.Username, req.Roles)
+		return singleResponse{user, err}, nil
+	}
+}
+
+func MakeEndedEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		sessions, err := svc.Ended(ctx)
+		return endedResponse{sessions, err}, nil
+	}
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Store    string `json:"store,omitempty"`
+}
+
+type logoutRequest struct {
+}
+
+type meRequest struct {
+}
+
+type getAllRequest struct {
+}
+
+type createRequest struct {
+	Username string               `json:"username"`
+	Password string               `json:"password"`
+	Roles    map[string]auth.Role `json:"roles,omitempty"`
+}
+
+type resetPasswordRequest struct {
+	Username string `json:"-"`
+	Password string `json:"password"`
+}
+
+type setRolesRequest struct {
+	Username string               `json:"-"`
+	Roles    map[string]auth.Role `json:"roles"`
+}
+
+type endedRequest struct {
+}
+
+type sessionResponse struct {
+	Session Session `json:"session"`
+	Err     error   `json:"-"`
+}
+
+func (r sessionResponse) error() error { return r.Err }
+
+type meResponse struct {
+	User     User          `json:"user"`
+	Identity auth.Identity `json:"identity"`
+	Err      error         `json:"-"`
+}
+
+func (r meResponse) error() error { return r.Err }
+
+type singleResponse struct {
+	User User  `json:"user"`
+	Err  error `json:"-"`
+}
+
+func (r singleResponse) error() error { return r.Err }
+
+type multiResponse struct {
+	Users []User `json:"users"`
+	Err   error  `json:"-"`
+}
+
+func (r multiResponse) error() error { return r.Err }
+
+type emptyResponse struct {
+	Err error `json:"-"`
+}
+
+func (r emptyResponse) error() error { return r.Err }
+
+type endedResponse struct {
+	Sessions map[string]time.Time `json:"sessions"`
+	Err      error                `json:"-"`
+}
+
+func (r endedResponse) error() error { return r.Err }
@@ -0,0 +1,317 @@
+package usersvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/go-kit/kit/transport"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-kit/log"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+	"github.com/jlthompson3259/matspinner/common/cors"
+	"github.com/jlthompson3259/matspinner/common/limit"
+	"github.com/jlthompson3259/matspinner/common/openapi"
+	"github.com/jlthompson3259/matspinner/common/tracing"
+	"github.com/jlthompson3259/matspinner/common/upstream"
+)
+
+func MakeHTTPHandler(e EndpointSet, authn auth.Authenticator, crossOrigin cors.Policy, limits limit.Settings, logger log.Logger) http.Handler {
+	return cors.Handler(crossOrigin, limit.Handler(limits, authn, openapi.Serve(spec, makeRouter(e, authn, logger))))
+}
+
+// makeRouter routes the endpoints. Every route must be described by the
+// OpenAPI document, which the tests check.
+func makeRouter(e EndpointSet, authn auth.Authenticator, logger log.Logger) *mux.Router {
+	r := mux.NewRouter()
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorHandler(transport.NewLogErrorHandler(logger)),
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(tracing.HTTPToContext(), auth.HTTPToContext(authn)),
+		httptransport.ServerFinalizer(tracing.ServerFinalizer),
+	}
+
+	// logging in is the one route that needs no credential
+	r.Methods("POST").Path("/login").Handler(httptransport.NewServer(
+		e.LoginEndpoint,
+		decodeLoginRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/logout").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.LogoutEndpoint),
+		decodeLogoutRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/me").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleDisplay)(e.MeEndpoint),
+		decodeMeRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/users").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.GetAllEndpoint),
+		decodeGetAllRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("POST").Path("/users").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.CreateEndpoint),
+		decodeCreateRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/users/{username}/password").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.ResetPasswordEndpoint),
+		decodeResetPasswordRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("PUT").Path("/users/{username}/roles").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleAdmin)(e.SetRolesEndpoint),
+		decodeSetRolesRequest,
+		encodeResponse,
+		options...,
+	))
+	r.Methods("GET").Path("/sessions/ended").Handler(httptransport.NewServer(
+		auth.Require(auth.RoleStaff)(e.EndedEndpoint),
+		decodeEndedRequest,
+		encodeResponse,
+		options...,
+	))
+	return r
+}
+
+/** server decode/encode **/
+func decodeLoginRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	return request, nil
+}
+
+func decodeLogoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return logoutRequest{}, nil
+}
+
+func decodeMeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return meRequest{}, nil
+}
+
+func decodeGetAllRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return getAllRequest{}, nil
+}
+
+func decodeCreateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request createRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	return request, nil
+}
+
+func decodeResetPasswordRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	request.Username = mux.Vars(r)["username"]
+	return request, nil
+}
+
+func decodeSetRolesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var request setRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, limit.BodyError(err)
+	}
+	request.Username = mux.Vars(r)["username"]
+	return request, nil
+}
+
+func decodeEndedRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endedRequest{}, nil
+}
+
+// errorer is implemented by all concrete response types that may contain
+// errors. It allows us to change the HTTP response code without needing to
+// trigger an endpoint (transport-level) error.
+type errorer interface {
+	error() error
+}
+
+// encodeResponse is the common method to encode all response types to the
+// client.
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	if e, ok := response.(errorer); ok && e.error() != nil {
+		// Not a Go kit transport error, but a business-logic error.
+		// Provide those as HTTP errors.
+		encodeError(ctx, e.error(), w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	if err == nil {
+		panic("encodeError with nil error")
+	}
+	apierror.Encode(w, codeFrom(err), err)
+}
+
+func codeFrom(err error) int {
+	switch apierror.Sentinel(err) {
+	case auth.ErrUnauthenticated, auth.ErrInvalidCredentials, ErrInvalidLogin, ErrNotLoggedIn, ErrSessionNotFound:
+		return http.StatusUnauthorized
+	case auth.ErrForbidden, auth.ErrWrongStore, ErrNoRoleForStore:
+		return http.StatusForbidden
+	case ErrUserDoesNotExist:
+		return http.StatusNotFound
+	case ErrUserExists:
+		return http.StatusConflict
+	case ErrMissingUsername, ErrMissingPassword, ErrPasswordTooShort, ErrStoreRequired, auth.ErrUnknownRole:
+		return http.StatusBadRequest
+	case auth.ErrSessionsUnknown, upstream.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case upstream.ErrBadGateway:
+		return http.StatusBadGateway
+	case limit.ErrBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return apierror.StatusCode(err, http.StatusInternalServerError)
+	}
+}
+
+/** client encode/decode **/
+func decodeSessionResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response sessionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeMeResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response meResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeSingleResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response singleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeMultiResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response multiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeEmptyResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response emptyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func decodeEndedResponse(ctx context.Context, resp *http.Response) (interface{}, error) {
+	if err := apierror.Decode(resp); err != nil {
+		return nil, err
+	}
+	var response endedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func encodeLoginRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/login"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeLogoutRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/logout"
+	return nil
+}
+
+func encodeMeRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/me"
+	return nil
+}
+
+func encodeGetAllRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/users"
+	return nil
+}
+
+func encodeCreateRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/users"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeResetPasswordRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(resetPasswordRequest)
+	req.URL.Path = "/users/" + r.Username + "/password"
+	req.URL.RawPath = "/users/" + url.PathEscape(r.Username) + "/password"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeSetRolesRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	r := request.(setRolesRequest)
+	req.URL.Path = "/users/" + r.Username + "/roles"
+	req.URL.RawPath = "/users/" + url.PathEscape(r.Username) + "/roles"
+	return encodeRequest(ctx, req, request)
+}
+
+func encodeEndedRequest(ctx context.Context, req *http.Request, request interface{}) error {
+	req.URL.Path = "/sessions/ended"
+	return nil
+}
+
+// encodeRequest likewise JSON-encodes the request to the HTTP request body.
+// Don't use it directly as a transport/http.Client EncodeRequestFunc:
+// usersvc endpoints require mutating the HTTP method and request path.
+func encodeRequest(_ context.Context, req *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(request)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Body = io.NopCloser(&buf)
+	return nil
+}
@@ -0,0 +1,85 @@
+package usersvc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/jlthompson3259/matspinner/common/auth"
+)
+
+type ServiceMiddleware func(Service) Service
+
+type loggingMiddleware struct {
+	next   Service
+	logger log.Logger
+}
+
+func LoggingMiddleware(logger log.Logger) ServiceMiddleware {
+	return func(service Service) Service {
+		return &loggingMiddleware{
+			next:   service,
+			logger: logger,
+		}
+	}
+}
+
+func (mw *loggingMiddleware) Login(ctx context.Context, username, password, store string) (s Session, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", username, "method", "Login", "store", store, "session", fmt.Sprintf("%v", s), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Login(ctx, username, password, store)
+}
+
+func (mw *loggingMiddleware) Logout(ctx context.Context) (err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Logout", "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Logout(ctx)
+}
+
+func (mw *loggingMiddleware) Me(ctx context.Context) (u User, id auth.Identity, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Me", "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Me(ctx)
+}
+
+func (mw *loggingMiddleware) GetAll(ctx context.Context) (u []User, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "GetAll", "users", len(u), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.GetAll(ctx)
+}
+
+func (mw *loggingMiddleware) Create(ctx context.Context, username, password string, roles map[string]auth.Role) (u User, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "Create", "created", fmt.Sprintf("%v", u), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Create(ctx, username, password, roles)
+}
+
+func (mw *loggingMiddleware) ResetPassword(ctx context.Context, username, password string) (err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "ResetPassword", "username", username, "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.ResetPassword(ctx, username, password)
+}
+
+func (mw *loggingMiddleware) SetRoles(ctx context.Context, username string, roles map[string]auth.Role) (u User, err error) {
+	defer func(begin time.Time) {
+		level.Info(mw.logger).Log("user", auth.Subject(ctx), "method", "SetRoles", "username", username, "roles", fmt.Sprintf("%v", roles), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.SetRoles(ctx, username, roles)
+}
+
+// Ended is polled by every service, so it is only logged at debug.
+func (mw *loggingMiddleware) Ended(ctx context.Context) (sessions map[string]time.Time, err error) {
+	defer func(begin time.Time) {
+		level.Debug(mw.logger).Log("user", auth.Subject(ctx), "method", "Ended", "sessions", len(sessions), "duration", time.Since(begin), "err", err)
+	}(time.Now())
+	return mw.next.Ended(ctx)
+}
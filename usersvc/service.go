@@ -0,0 +1,416 @@
+package usersvc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/jlthompson3259/matspinner/common/apierror"
+	"github.com/jlthompson3259/matspinner/common/auth"
+)
+
+var (
+	ErrUserExists       = apierror.New("users.exists", "user already exists")
+	ErrUserDoesNotExist = apierror.New("users.not_found", "user does not exist")
+	ErrInvalidLogin     = apierror.New("users.invalid_login", "invalid username or password")
+	ErrNoRoleForStore   = apierror.New("users.no_role_for_store", "user has no role at this store")
+	ErrStoreRequired    = apierror.New("users.store_required", "user works at several stores, store is required")
+	ErrPasswordTooShort = apierror.New("users.password_too_short", "password must be at least 8 characters")
+	ErrMissingUsername  = apierror.New("users.missing_username", "missing username")
+	ErrSessionNotFound  = apierror.New("users.session_not_found", "session has ended")
+	ErrNotLoggedIn      = apierror.New("users.not_logged_in", "not logged in to a session")
+	ErrMissingPassword  = apierror.New("users.missing_password", "missing password")
+)
+
+const (
+	// AllStores assigns a role at every store.
+	AllStores = "*"
+
+	minPasswordLength = 8
+)
+
+type Service interface {
+	Login(ctx context.Context, username, password, store string) (Session, error)
+	Logout(ctx context.Context) error
+	Me(ctx context.Context) (User, auth.Identity, error)
+	GetAll(ctx context.Context) ([]User, error)
+	Create(ctx context.Context, username, password string, roles map[string]auth.Role) (User, error)
+	ResetPassword(ctx context.Context, username, password string) error
+	SetRoles(ctx context.Context, username string, roles map[string]auth.Role) (User, error)
+	Ended(ctx context.Context) (map[string]time.Time, error)
+}
+
+// User is a staff account. Roles maps a store id, or AllStores, to the role
+// the user has there.
+type User struct {
+	Username string               `json:"username"`
+	Roles    map[string]auth.Role `json:"roles"`
+	Created  time.Time            `json:"created"`
+
+	passwordHash []byte
+}
+
+func (u User) String() string {
+	return fmt.Sprintf("{username: %v, roles: %v}", u.Username, u.Roles)
+}
+
+// roleAt returns the role the user has at store, and the store the role
+// applies to when the user didn't name one.
+func (u User) roleAt(store string) (auth.Role, string, error) {
+	if store == "" {
+		if role, ok := u.Roles[AllStores]; ok {
+			return role, "", nil
+		}
+		if len(u.Roles) == 1 {
+			for s, role := range u.Roles {
+				return role, s, nil
+			}
+		}
+		if len(u.Roles) == 0 {
+			return "", "", ErrNoRoleForStore
+		}
+		return "", "", ErrStoreRequired
+	}
+	if role, ok := u.Roles[store]; ok {
+		return role, store, nil
+	}
+	if role, ok := u.Roles[AllStores]; ok {
+		return role, store, nil
+	}
+	return "", "", ErrNoRoleForStore
+}
+
+// Session is the result of a successful login. Token is a signed token that
+// every service accepts until it expires or the session ends, which the
+// other services learn of by fetching Ended.
+type Session struct {
+	Token   string        `json:"token"`
+	Expires time.Time     `json:"expires"`
+	User    User          `json:"user"`
+	As      auth.Identity `json:"identity"`
+}
+
+func (s Session) String() string {
+	return fmt.Sprintf("{user: %v, identity: %v, expires: %v}", s.User.Username, s.As, s.Expires)
+}
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+type userService struct {
+	mtx      sync.RWMutex
+	users    map[string]User
+	sessions map[string]session
+	// ended maps sessions that ended before their token expired to when it
+	// expires, after which it needn't be refused any more
+	ended   map[string]time.Time
+	signer  *auth.Signer
+	ttl     time.Duration
+	storage Storage
+	logger  log.Logger
+}
+
+// NewService returns a user service issuing session tokens signed by signer
+// that last for ttl, keeping its accounts and sessions in storage.
+func NewService(logger log.Logger, signer *auth.Signer, ttl time.Duration, storage Storage) (Service, error) {
+	s := &userService{
+		users:    make(map[string]User),
+		sessions: make(map[string]session),
+		ended:    make(map[string]time.Time),
+		signer:   signer,
+		ttl:      ttl,
+		storage:  storage,
+		logger:   logger,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *userService) Login(ctx context.Context, username, password, store string) (Session, error) {
+	s.mtx.RLock()
+	user, ok := s.users[normalize(username)]
+	s.mtx.RUnlock()
+
+	// bcrypt is slow on purpose, so compare without holding the lock
+	if !ok {
+		// compare anyway so that unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return Session{}, ErrInvalidLogin
+	}
+	if err := bcrypt.CompareHashAndPassword(user.passwordHash, []byte(password)); err != nil {
+		return Session{}, ErrInvalidLogin
+	}
+
+	role, store, err := user.roleAt(strings.TrimSpace(store))
+	if err != nil {
+		return Session{}, err
+	}
+
+	sid, err := newSessionId()
+	if err != nil {
+		return Session{}, err
+	}
+	id := auth.Identity{Subject: user.Username, Role: role, Store: store, Session: sid}
+	token, err := s.signer.Sign(id, s.ttl)
+	if err != nil {
+		return Session{}, err
+	}
+
+	expires := time.Now().Add(s.ttl).UTC()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	// the password or roles may have changed while comparing
+	if current, ok := s.users[normalize(username)]; !ok || !bytes.Equal(current.passwordHash, user.passwordHash) || !sameRoles(current.Roles, user.Roles) {
+		return Session{}, ErrInvalidLogin
+	}
+	s.sessions[sid] = session{username: user.Username, expires: expires}
+	s.pruneSessions()
+	if err := s.save(); err != nil {
+		return Session{}, err
+	}
+	return Session{Token: token, Expires: expires, User: user, As: id}, nil
+}
+
+func (s *userService) Logout(ctx context.Context) error {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.Session == "" {
+		return ErrNotLoggedIn
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.sessions[id.Session]; !ok {
+		return ErrSessionNotFound
+	}
+	s.endSession(id.Session)
+	return s.save()
+}
+
+func (s *userService) Me(ctx context.Context) (User, auth.Identity, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return User{}, auth.Identity{}, ErrNotLoggedIn
+	}
+	if id.Session == "" {
+		// API keys have no account behind them
+		return User{Username: id.Subject}, id, nil
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if _, ok := s.sessions[id.Session]; !ok {
+		return User{}, auth.Identity{}, ErrSessionNotFound
+	}
+	user, ok := s.users[normalize(id.Subject)]
+	if !ok {
+		return User{}, auth.Identity{}, ErrUserDoesNotExist
+	}
+	return user, id, nil
+}
+
+func (s *userService) GetAll(ctx context.Context) ([]User, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *userService) Create(ctx context.Context, username, password string, roles map[string]auth.Role) (User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return User{}, ErrMissingUsername
+	}
+	if err := validateRoles(roles); err != nil {
+		return User{}, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.users[normalize(username)]; ok {
+		return User{}, ErrUserExists
+	}
+	user := User{
+		Username:     username,
+		Roles:        copyRoles(roles),
+		Created:      time.Now().UTC(),
+		passwordHash: hash,
+	}
+	s.users[normalize(username)] = user
+	if err := s.save(); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// ResetPassword sets a new password and ends all of the user's sessions.
+func (s *userService) ResetPassword(ctx context.Context, username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	user, ok := s.users[normalize(username)]
+	if !ok {
+		return ErrUserDoesNotExist
+	}
+	user.passwordHash = hash
+	s.users[normalize(username)] = user
+	s.endSessions(user.Username)
+	return s.save()
+}
+
+// SetRoles replaces the user's role assignments and ends their sessions, so
+// the new roles apply from their next login.
+func (s *userService) SetRoles(ctx context.Context, username string, roles map[string]auth.Role) (User, error) {
+	if err := validateRoles(roles); err != nil {
+		return User{}, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	user, ok := s.users[normalize(username)]
+	if !ok {
+		return User{}, ErrUserDoesNotExist
+	}
+	user.Roles = copyRoles(roles)
+	s.users[normalize(username)] = user
+	s.endSessions(user.Username)
+	if err := s.save(); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// Ended returns the sessions that ended before their tokens expired, with
+// the time each token expires.
+func (s *userService) Ended(ctx context.Context) (map[string]time.Time, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	now := time.Now()
+	ended := make(map[string]time.Time, len(s.ended))
+	for sid, expires := range s.ended {
+		if now.Before(expires) {
+			ended[sid] = expires
+		}
+	}
+	return ended, nil
+}
+
+// SessionEnded checks tokens against svc's ended sessions directly, for
+// binaries that run usersvc in the same process.
+func SessionEnded(svc Service) auth.SessionEnded {
+	return func(ctx context.Context, sid string) (bool, error) {
+		ended, err := svc.Ended(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, ok := ended[sid]
+		return ok, nil
+	}
+}
+
+// endSession must be called with the write lock held.
+func (s *userService) endSession(sid string) {
+	s.ended[sid] = s.sessions[sid].expires
+	delete(s.sessions, sid)
+}
+
+// endSessions must be called with the write lock held.
+func (s *userService) endSessions(username string) {
+	for sid, sess := range s.sessions {
+		if sess.username == username {
+			s.endSession(sid)
+		}
+	}
+}
+
+// pruneSessions must be called with the write lock held.
+func (s *userService) pruneSessions() {
+	now := time.Now()
+	for sid, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, sid)
+		}
+	}
+	for sid, expires := range s.ended {
+		if now.After(expires) {
+			delete(s.ended, sid)
+		}
+	}
+}
+
+func validateRoles(roles map[string]auth.Role) error {
+	for _, role := range roles {
+		if _, err := auth.ParseRole(string(role)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyRoles(roles map[string]auth.Role) map[string]auth.Role {
+	c := make(map[string]auth.Role, len(roles))
+	for store, role := range roles {
+		c[strings.TrimSpace(store)] = role
+	}
+	return c
+}
+
+func sameRoles(a, b map[string]auth.Role) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for store, role := range a {
+		if b[store] != role {
+			return false
+		}
+	}
+	return true
+}
+
+func hashPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrMissingPassword
+	}
+	if len(password) < minPasswordLength {
+		return nil, ErrPasswordTooShort
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+func normalize(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func newSessionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
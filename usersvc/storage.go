@@ -0,0 +1,110 @@
+package usersvc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const usersFile = "users.json"
+
+// Storage says where usersvc keeps accounts and sessions.
+type Storage struct {
+	Dir string `yaml:"dir" env:"USERS_DIR" flag:"users-dir" help:"directory to keep accounts and sessions in; empty keeps them in memory"`
+}
+
+// state is what is kept in the users file. It is small, so it is written
+// whole after every change.
+type state struct {
+	Users    []storedUser             `json:"users"`
+	Sessions map[string]storedSession `json:"sessions"`
+	Ended    map[string]time.Time     `json:"ended"`
+}
+
+type storedUser struct {
+	User
+	PasswordHash []byte `json:"passwordHash"`
+}
+
+type storedSession struct {
+	Username string    `json:"username"`
+	Expires  time.Time `json:"expires"`
+}
+
+// load reads the users file into s, if there is one.
+func (s *userService) load() error {
+	if s.storage.Dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.storage.Dir, usersFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for _, u := range st.Users {
+		u.User.passwordHash = u.PasswordHash
+		s.users[normalize(u.Username)] = u.User
+	}
+	for sid, sess := range st.Sessions {
+		s.sessions[sid] = session{username: sess.Username, expires: sess.Expires}
+	}
+	for sid, expires := range st.Ended {
+		s.ended[sid] = expires
+	}
+	return nil
+}
+
+// save writes s to the users file, replacing it only once the new one is
+// complete. It must be called with the write lock held.
+func (s *userService) save() error {
+	if s.storage.Dir == "" {
+		return nil
+	}
+	st := state{
+		Users:    make([]storedUser, 0, len(s.users)),
+		Sessions: make(map[string]storedSession, len(s.sessions)),
+		Ended:    s.ended,
+	}
+	for _, u := range s.users {
+		st.Users = append(st.Users, storedUser{User: u, PasswordHash: u.passwordHash})
+	}
+	for sid, sess := range s.sessions {
+		st.Sessions[sid] = storedSession{Username: sess.username, Expires: sess.expires}
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.storage.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.storage.Dir, usersFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// the file holds password hashes, so unlike the ticket log it isn't
+	// left readable by everyone
+	return os.Rename(tmp.Name(), filepath.Join(s.storage.Dir, usersFile))
+}